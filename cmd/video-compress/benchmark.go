@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"video-compress/internal/config"
+	"video-compress/internal/ffmpeg"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	benchWidth  = 1920
+	benchHeight = 1080
+	benchFPS    = 30
+)
+
+// runBenchmark 实现 vc benchmark：生成合成测试片段，按预设与并发数依次编码并统计速度
+// 用于在新机器上选择合适的 --workers 与 --threads
+func runBenchmark(args []string) int {
+	fs := pflag.NewFlagSet("benchmark", pflag.ExitOnError)
+	duration := fs.Int("duration", 30, "测试片段时长 (秒)")
+	workerList := fs.IntSlice("workers", []int{1, 2, 4}, "依次测试的并发数")
+	presets := fs.StringSlice("presets", config.BuiltinPresets, "参与测试的预设")
+	threads := fs.Int("threads", 0, "每个 ffmpeg 进程的线程数 (同主命令的 --threads)")
+	tempDir := fs.String("temp-dir", "", "测试文件的存放目录 (默认 $TMPDIR)")
+	_ = fs.Parse(args)
+
+	for _, p := range *presets {
+		if !slices.Contains(config.BuiltinPresets, p) {
+			fmt.Printf("错误: 未知预设 %q\n", p)
+			return 1
+		}
+	}
+	if *duration <= 0 || slices.ContainsFunc(*workerList, func(w int) bool { return w <= 0 }) {
+		fmt.Println("错误: --duration 与 --workers 必须为正数")
+		return 1
+	}
+
+	tmpDir, err := os.MkdirTemp(*tempDir, "vc-benchmark-")
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(tmpDir)
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		fmt.Println("\n\n⚠️ 用户中断，正在清理...")
+		_ = os.RemoveAll(tmpDir)
+		os.Exit(1)
+	}()
+
+	src := filepath.Join(tmpDir, "testsrc.mp4")
+	fmt.Printf("🧪 生成 %d 秒 %dx%d@%d 测试片段...\n", *duration, benchWidth, benchHeight, benchFPS)
+	gen := exec.Command("ffmpeg", "-y", "-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", fmt.Sprintf("testsrc2=size=%dx%d:rate=%d", benchWidth, benchHeight, benchFPS),
+		"-t", strconv.Itoa(*duration), "-pix_fmt", "yuv420p", "-q:v", "2", src)
+	if out, err := gen.CombinedOutput(); err != nil {
+		fmt.Printf("❌ 生成测试片段失败: %v\n%s", err, out)
+		return 1
+	}
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+
+	info := ffmpeg.InputInfo{VideoStream: -1, Width: benchWidth, Height: benchHeight, BitDepth: 8}
+	frames := float64(*duration * benchFPS)
+
+	fmt.Printf("\n%-10s %-8s %10s %8s %12s %10s\n", "预设", "并发", "速度(fps)", "倍速", "输出体积", "体积比")
+	fmt.Println(strings.Repeat("-", 66))
+	for _, preset := range *presets {
+		cfg := config.Config{Preset: preset, FFmpegThreads: *threads}
+		for _, workers := range *workerList {
+			elapsed, size, err := benchmarkRun(src, tmpDir, cfg, info, workers)
+			if err != nil {
+				fmt.Printf("%-10s %-8d ❌ %v\n", preset, workers, err)
+				continue
+			}
+			secs := elapsed.Seconds()
+			fmt.Printf("%-10s %-8d %10.1f %7.2fx %10.1f MB %9.1f%%\n", preset, workers,
+				frames*float64(workers)/secs,
+				float64(*duration*workers)/secs,
+				float64(size)/1024/1024,
+				float64(size)/float64(srcInfo.Size())*100)
+		}
+	}
+	fmt.Println(strings.Repeat("-", 66))
+	fmt.Println("速度与倍速为所有并发进程的合计吞吐量；倍速不再随并发增长时即为合适的 --workers")
+	return 0
+}
+
+// benchmarkRun 同时启动 workers 个编码进程，返回总墙钟时间与单个输出的体积
+func benchmarkRun(src, dir string, cfg config.Config, info ffmpeg.InputInfo, workers int) (time.Duration, int64, error) {
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	outputs := make([]string, workers)
+	start := time.Now()
+	for i := range workers {
+		outputs[i] = filepath.Join(dir, fmt.Sprintf("%s-%d-%d.compressed.mp4", cfg.Preset, workers, i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = ffmpeg.Run(ffmpeg.BuildArgs(src, outputs[i], cfg, info), ffmpeg.RunOptions{})
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	defer func() {
+		for _, o := range outputs {
+			_ = os.Remove(o)
+		}
+	}()
+	for _, err := range errs {
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	fi, err := os.Stat(outputs[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	return elapsed, fi.Size(), nil
+}
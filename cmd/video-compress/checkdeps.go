@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"video-compress/internal/ffmpeg"
+)
+
+// runCheckDeps 实现 vc check-deps：逐项检查外部依赖，全部通过时返回 0
+func runCheckDeps() int {
+	fmt.Println("🔍 检查外部依赖...")
+	allOK := true
+	report := func(ok bool, name, detail string) {
+		mark := "✅"
+		if !ok {
+			mark = "❌"
+			allOK = false
+		}
+		fmt.Printf("%s %-22s %s\n", mark, name, detail)
+	}
+
+	// 1. ffmpeg 及版本
+	ffmpegOK := false
+	if path, err := exec.LookPath("ffmpeg"); err != nil {
+		report(false, "ffmpeg", "未在 PATH 中找到 (macOS: brew install ffmpeg)")
+	} else if v, err := ffmpeg.Version(); err != nil {
+		report(false, "ffmpeg", fmt.Sprintf("%s (无法获取版本: %v)", path, err))
+	} else if !ffmpeg.VersionAtLeast(v, ffmpeg.MinVersion) {
+		report(false, "ffmpeg", fmt.Sprintf("版本 %s 低于最低要求 %s", v, ffmpeg.MinVersion))
+	} else {
+		report(true, "ffmpeg", fmt.Sprintf("版本 %s (%s)", v, path))
+		ffmpegOK = true
+	}
+
+	// 2. ffprobe
+	if path, err := exec.LookPath("ffprobe"); err != nil {
+		report(false, "ffprobe", "未在 PATH 中找到 (通常随 ffmpeg 一起安装)")
+	} else {
+		report(true, "ffprobe", path)
+	}
+
+	// 3. 编码器
+	encoderChecks := []struct{ name, usage string }{
+		{ffmpeg.HardwareEncoder, "--preset standard/low"},
+		{ffmpeg.SoftwareEncoder, "--preset high"},
+	}
+	for _, c := range encoderChecks {
+		if !ffmpegOK {
+			report(false, c.name, "跳过 (ffmpeg 不可用)")
+			continue
+		}
+		ok, err := ffmpeg.HasEncoder(c.name)
+		switch {
+		case err != nil:
+			report(false, c.name, fmt.Sprintf("查询编码器失败: %v", err))
+		case !ok:
+			report(false, c.name, fmt.Sprintf("当前 ffmpeg 未包含该编码器，无法使用 %s", c.usage))
+		default:
+			report(true, c.name, "用于 "+c.usage)
+		}
+	}
+
+	fmt.Println(strings.Repeat("-", 48))
+	if !allOK {
+		fmt.Println("❌ 部分依赖检查未通过")
+		return 1
+	}
+	fmt.Println("✅ 所有依赖检查通过")
+	return 0
+}
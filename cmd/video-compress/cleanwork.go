@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"video-compress/internal/compressor"
+
+	"github.com/spf13/pflag"
+)
+
+// runCleanWork 实现 vc clean-work：删除工作目录
+// 未指定 --dir 时删除 --temp-dir (默认系统临时目录) 下所有由 vc 创建的 vc-* 工作目录；只删除带有标记文件的目录，避免误删
+func runCleanWork(args []string) int {
+	fs := pflag.NewFlagSet("clean-work", pflag.ExitOnError)
+	dir := fs.String("dir", "", "要删除的工作目录 (与运行时的 --working-dir 相同)")
+	tempDir := fs.String("temp-dir", os.TempDir(), "未指定 --dir 时在该目录下查找 vc-* 工作目录 (与运行时的 --temp-dir 相同)")
+	_ = fs.Parse(args)
+
+	var dirs []string
+	if *dir != "" {
+		dirs = []string{*dir}
+	} else {
+		dirs, _ = filepath.Glob(filepath.Join(*tempDir, "vc-*"))
+	}
+
+	removed, failed := 0, 0
+	for _, d := range dirs {
+		if !compressor.IsWorkDir(d) {
+			if *dir != "" {
+				fmt.Printf("❌ %s 不是 vc 的工作目录 (缺少 %s)，未删除\n", d, compressor.WorkDirMarker)
+				failed++
+			}
+			continue
+		}
+		if err := os.RemoveAll(d); err != nil {
+			fmt.Printf("❌ %s: %v\n", d, err)
+			failed++
+			continue
+		}
+		fmt.Printf("🧹 已删除 %s\n", d)
+		removed++
+	}
+	fmt.Printf("已删除 %d 个工作目录\n", removed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"video-compress/internal/config"
+	"video-compress/internal/ffmpeg"
+)
+
+// usableEncoders 是本工具 (内置预设、旧容器迁移、纯音频转码及常见自定义预设) 可以使用的编码器
+var usableEncoders = []struct{ name, usage string }{
+	{ffmpeg.HardwareEncoder, "standard/low 预设"},
+	{ffmpeg.SoftwareEncoder, "high/screen 预设"},
+	{"h264_videotoolbox", "自定义预设 (H.264 硬件编码)"},
+	{"libx264", "自定义预设 (H.264 软件编码)"},
+	{ffmpeg.ArchiveEncoder, "archive 预设 / 自定义预设 (AV1)"},
+	{"libaom-av1", "自定义预设 (AV1)"},
+	{"libvpx-vp9", "自定义预设 (VP9)"},
+	{ffmpeg.AudioOnlyCodec, "纯音频转码"},
+	{ffmpeg.LegacyAudioCodec, "wmv/avi 音频转码"},
+	{"aac_at", "自定义预设音频 (AudioToolbox AAC)"},
+	{"libfdk_aac", "自定义预设音频"},
+	{"libmp3lame", "自定义预设音频"},
+	{"flac", "自定义预设音频"},
+}
+
+// runListEncoders 实现 --list-encoders：列出本机 ffmpeg 中本工具可用的编码器
+func runListEncoders() int {
+	available, err := ffmpeg.Encoders()
+	if err != nil {
+		fmt.Printf("❌ 无法查询 ffmpeg 编码器: %v\n", err)
+		return 1
+	}
+	fmt.Println("🔧 可用编码器:")
+	for _, e := range usableEncoders {
+		flags, ok := available[e.name]
+		if !ok {
+			fmt.Printf("❌ %-20s %-8s %s (当前 ffmpeg 未包含)\n", e.name, "", e.usage)
+			continue
+		}
+		fmt.Printf("✅ %-20s %-8s %s\n", e.name, flags, e.usage)
+	}
+	return 0
+}
+
+// runListPresets 实现 --list-presets：列出内置预设与 --preset-file 中定义的预设
+func runListPresets(presets map[string]config.PresetDefinition) int {
+	fmt.Println("🎛  内置预设:")
+	for _, name := range config.BuiltinPresets {
+		if _, overridden := presets[name]; overridden {
+			fmt.Printf("    %-10s (已被 --preset-file 覆盖)\n", name)
+			continue
+		}
+		cfg := config.Config{Preset: name}
+		q, v := ffmpeg.NativeQuality(cfg)
+		p := ffmpeg.ResolvePreset(cfg)
+		fmt.Printf("    %-10s %-18s -%s %-3d %s\n", name, p.Encoder, q, v, p.Description)
+	}
+	fmt.Printf("    %-10s 按元数据在 screen 与 standard 之间自动选择\n", config.PresetAuto)
+
+	if len(presets) == 0 {
+		return 0
+	}
+	fmt.Println("\n📄 自定义预设 (--preset-file):")
+	for _, name := range slices.Sorted(maps.Keys(presets)) {
+		p := presets[name]
+		q, v := ffmpeg.NativeQuality(config.Config{Preset: name, Presets: presets})
+		parts := []string{p.Codec}
+		if v > 0 {
+			parts = append(parts, fmt.Sprintf("-%s %d", q, v))
+		}
+		if p.Profile != "" {
+			parts = append(parts, "profile "+p.Profile)
+		}
+		if p.AudioCodec != "" {
+			parts = append(parts, strings.TrimSpace("audio "+p.AudioCodec+" "+p.AudioBitrate))
+		}
+		if len(p.ExtraArgs) > 0 {
+			parts = append(parts, strings.Join(p.ExtraArgs, " "))
+		}
+		fmt.Printf("    %-10s %s\n", name, strings.Join(parts, ", "))
+	}
+	return 0
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
 	"strings"
 	"syscall"
 
@@ -12,74 +18,749 @@ import (
 	"time"
 	"video-compress/internal/compressor"
 	"video-compress/internal/config"
+	"video-compress/internal/events"
 	"video-compress/internal/ffmpeg"
+	"video-compress/internal/profile"
+	"video-compress/internal/report"
+	"video-compress/internal/utils"
 
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/pflag"
 )
 
+// audioBitrateRe 匹配 ffmpeg -b:a 可接受的码率写法，如 96k、1.5M
+var audioBitrateRe = regexp.MustCompile(`^\d+(\.\d+)?[kKmM]?$`)
+
 func main() {
+	// 0. 子命令分发
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check-deps":
+			os.Exit(runCheckDeps())
+		case "restore":
+			os.Exit(runRestore(os.Args[2:]))
+		case "report":
+			os.Exit(runReport(os.Args[2:]))
+		case "benchmark":
+			os.Exit(runBenchmark(os.Args[2:]))
+		case "quarantine":
+			os.Exit(runQuarantine(os.Args[2:]))
+		case "clean-work":
+			os.Exit(runCleanWork(os.Args[2:]))
+		case "presets":
+			os.Exit(runPresets(os.Args[2:]))
+		case "split":
+			os.Exit(runSplit(os.Args[2:]))
+		case "profile":
+			// add 需要下面的参数定义来校验，在参数定义之后处理
+			if len(os.Args) < 3 || os.Args[2] != "add" {
+				os.Exit(runProfile(os.Args[2:]))
+			}
+		}
+	}
+
 	// 1. 参数解析
-	var outputDir, presetName string
-	var customQuality, workers int
+	var outputDir, presetName, presetFile, tempDir, hwaccelDevice, order, quarantineDir, reportStyle, workingDir, resolution string
+	var customQuality, workers, videoStream, maxHeight, threads, bufferSize, bitDepth, quarantineAfter, vmafIterations int
+	var listEncoders, listPresets, twoDirCompare, verifyOnly bool
+	var priorityFirst, dedupe, includeAudioOnly, audioIfNoVideo, keepSubtitles, skipSpherical, keepDataStreams, allowCollision, skipExisting, checkInput, scanHidden, reportShowAll, waitForSpace, deleteOriginal, checksumOutput, diagnose, copyAudio, depthPassthrough, banner, dryRun, noSegResume, clearMarks, tonemap, autoRotate, visualCheck, audioChannelsAuto, pinCores, audioSampleRateAuto, groupByDir bool
+	var subtitleFormat, burnSubs, watermark, watermarkPos, audioBitrate, audioCodec, audioFilter, videoFilter, inputFormat string
+	var reportJSON, retryFromReport, renditionSpec, eventsPath, progressPipe, maxOutput, outputModeSpec, ioLimit, diffReport, skipCompressedBy, compressedMaxBitrate, markSource, spaceBudget, sinceReport, hwMaxResolution, moveOriginalsTo, durationTolerance, dateDirs, reportFormat, reportFile, profileName string
+	var watermarkOpacity, reportThreshold, minRatio, maxBitrateAuto, targetVMAF float64
+	var watermarkPadding, progressFD, runsKeep, audioChannels, audioSampleRate int
+	var priorityGlobs, extensions, routeSpecs, metadataKeys []string
+	var splitEvery, segmentResume, rampUp, vmafSample, statsPeriod time.Duration
 
 	pflag.StringVarP(&outputDir, "output", "o", "", "指定输出目录")
-	pflag.StringVarP(&presetName, "preset", "p", config.PresetStandard, "压缩预设: high, standard, low")
+	pflag.StringSliceVar(&extensions, "extensions", nil, "目录扫描时只处理这些扩展名，如 mp4,mov (默认包含 mp4/mkv/mov/m4v/webm/avi/wmv/flv/ts/m2ts/mpg)")
+	pflag.StringVar(&inputFormat, "input-format", "", "强制输入容器格式 (如 mpegts)，对本批所有文件生效；目录扫描时不再按扩展名过滤")
+	pflag.StringVar(&dateDirs, "date-dirs", "", "按源文件拍摄日期 (元数据优先，其次修改时间) 放入子目录，Go 时间格式，如 \"2006/01\"；无法确定日期的放入 undated/")
+	pflag.StringArrayVar(&routeSpecs, "route", nil, "按扩展名分流输出目录，如 \"mov,mp4=/out/camera\" (可重复指定，未匹配的使用 -o)")
+	pflag.StringSliceVar(&metadataKeys, "map-metadata-keys", nil, "只保留这些容器级元数据标签，如 \"creation_time,title,artist\" (默认保留全部元数据)")
+	pflag.StringVarP(&presetName, "preset", "p", config.PresetStandard, "压缩预设: high, standard, low, screen, archive, auto 或 --preset-file 中定义的名称")
+	pflag.StringVar(&presetFile, "preset-file", "", "从 YAML 文件加载自定义预设 (同名时覆盖内置预设)")
 	pflag.IntVarP(&customQuality, "quality", "q", 0, "自定义质量 (1-100)")
-	pflag.IntVarP(&workers, "workers", "w", 2, "并发处理数量")
-	pflag.Parse()
+	pflag.Float64Var(&targetVMAF, "target-vmaf", 0, "按文件自适应质量：在样本上二分查找达到该 VMAF (如 93) 的最低 --quality 再编码 (需要 libvmaf，耗时成倍增加)")
+	pflag.DurationVar(&vmafSample, "vmaf-sample", 20*time.Second, "--target-vmaf 的样本时长，取自文件中段")
+	pflag.IntVar(&vmafIterations, "vmaf-max-iterations", 6, "--target-vmaf 每个文件最多编码并测量的样本次数")
+	pflag.IntVarP(&workers, "workers", "w", 2, "并发处理数量 (--preset archive 时默认为 1)")
+	pflag.IntVar(&threads, "threads", 0, "每个 ffmpeg 进程使用的线程数 (-threads，libx265 同时设置 pools=；0 表示由 ffmpeg 自动决定)")
+	pflag.IntVar(&threads, "encoder-threads", 0, "同 --threads")
+	pflag.BoolVar(&pinCores, "pin-cores", false, "软件编码时将每个 worker 绑定到各自的一组 CPU 核心，减少线程迁移 (仅 Linux 生效，其他平台忽略)")
+	pflag.StringVar(&tempDir, "temp-dir", os.TempDir(), "中间文件目录 (如本地 SSD，默认 $TMPDIR)：输出先在其中的任务子目录编码，完成后再移动到输出位置；VMAF 样本、诊断重跑、分段与工作目录也位于其中")
+	pflag.StringVar(&workingDir, "working-dir", "", "诊断日志等中间文件的存放目录 (默认每次运行在 --temp-dir 下新建 vc-*，全部成功后自动删除；可用 vc clean-work 清理)")
+	pflag.StringVar(&hwaccelDevice, "hwaccel-device", "0", "硬件加速设备序号 (cuda/vaapi 多 GPU 时生效，VideoToolbox 只有一个设备)")
+	pflag.IntVar(&bufferSize, "buffer-size", ffmpeg.DefaultScannerBufferBytes, "解析 ffmpeg 进度输出时单行的最大字节数")
+	pflag.DurationVar(&statsPeriod, "stats-period", time.Second, "ffmpeg 输出进度的间隔 (-stats_period)，并发任务很多时调大可降低 vc 自身的 CPU 占用；0 表示使用 ffmpeg 默认值")
+	pflag.DurationVar(&rampUp, "ramp-up", 500*time.Millisecond, "相邻 worker 启动的间隔，避免同时读取造成磁盘抖动 (0 表示同时启动)")
+	pflag.BoolVar(&priorityFirst, "priority-first", false, "命令行中显式列出的文件优先于目录扫描出的文件处理")
+	pflag.BoolVar(&dedupe, "dedupe", false, "完成后将内容完全相同的输出替换为硬链接以节省空间")
+	pflag.BoolVar(&includeAudioOnly, "include-audio-only", false, "同时处理纯音频文件 (mp3/m4a/flac/wav/aac)，转码为 Opus")
+	pflag.BoolVar(&audioIfNoVideo, "audio-only-if-no-video", false, "无视频流的文件按音频转码为 Opus (默认跳过)")
+	pflag.StringVar(&audioBitrate, "audio-bitrate", "", "音频码率 (如 160k)，指定后音频一律转码；默认流复制，需转码时按声道数选择 (单声道 64k、立体声 128k、5.1 256k)")
+	pflag.StringVar(&audioCodec, "audio-codec", "", "覆盖预设的音频编码器 (如 aac_at、libopus)，视频设置不变")
+	pflag.BoolVar(&copyAudio, "copy-audio", false, "强制流复制音频 (忽略预设与旧容器的音频转码)")
+	pflag.StringVar(&videoFilter, "video-filter", "", "自定义视频滤镜链 (与缩放等滤镜合并为同一个 -vf)，如 \"hflip\"")
+	pflag.Float64Var(&maxBitrateAuto, "max-bitrate-auto", 1.0, "视频码率上限为输入码率的该倍数 (如 0.9)，避免重编码后码率反而升高；1 表示不限制")
+	pflag.BoolVar(&autoRotate, "auto-rotate", false, "按旋转元数据 (手机竖屏视频) 以 transpose 转正画面并清除旋转标记，避免忽略该标记的播放器显示为横躺")
+	pflag.BoolVar(&tonemap, "tonemap", false, "HDR (PQ/HLG) 源输出为 SDR BT.709 (zscale + hable 色调映射，需要 ffmpeg 启用 libzimg)；SDR 源不受影响")
+	pflag.IntVar(&audioChannels, "audio-channels", 0, "输出声道数 (如 2 将 5.1/7.1 降混为立体声)，0 表示保持原声道数")
+	pflag.BoolVar(&audioChannelsAuto, "audio-channels-auto", false, "仅在输入声道多于 --audio-channels (默认 2) 时降混，不升混")
+	pflag.IntVar(&audioSampleRate, "audio-sample-rate", 0, "输出采样率 (如 48000，AAC 建议 44100 或 48000)，指定后音频转码；0 表示保持原采样率")
+	pflag.BoolVar(&audioSampleRateAuto, "audio-sample-rate-auto", false, "视频任务转码音频时统一为 48000 Hz，纯音频任务保持原采样率")
+	pflag.StringVar(&audioFilter, "audio-filter", "", "自定义音频滤镜链 (ffmpeg -af)，如 \"equalizer=f=1000:t=h:width=200:g=3\"")
+	pflag.DurationVar(&splitEvery, "split-every", 0, "按固定时长将输出切分为多个文件 (如 1h、30m)")
+	pflag.DurationVar(&segmentResume, "segment-resume", 0, "按固定时长分段编码后拼接 (如 5m)，中断后重跑只编码未完成的分段")
+	pflag.BoolVar(&noSegResume, "no-segment-resume", false, "忽略已完成的分段，从头重新编码 (配合 --segment-resume)")
+	pflag.BoolVar(&keepSubtitles, "keep-subtitles", false, "保留输入中的字幕流")
+	pflag.StringVar(&subtitleFormat, "copy-subtitle-format", "mov_text", "输出为 MP4/MOV 时字幕转码的目标格式 (配合 --keep-subtitles)")
+	pflag.StringVar(&burnSubs, "burn-subs", "", "将外挂字幕烧录进画面；不带值时查找与输入同名的 .ass/.srt，也可指定字幕文件 (--burn-subs=movie.srt)")
+	pflag.Lookup("burn-subs").NoOptDefVal = config.BurnSubsAuto
+	pflag.StringVar(&watermark, "watermark", "", "在画面角落叠加水印图片")
+	pflag.StringVar(&watermarkPos, "watermark-position", ffmpeg.WatermarkBottomRight, "水印位置: top-left, top-right, bottom-left, bottom-right")
+	pflag.Float64Var(&watermarkOpacity, "watermark-opacity", 1.0, "水印不透明度 (0-1]")
+	pflag.IntVar(&watermarkPadding, "watermark-padding", 20, "水印距画面边缘的像素")
+	pflag.BoolVar(&skipSpherical, "skip-spherical", false, "跳过带 360°/全景元数据的文件")
+	pflag.BoolVar(&keepDataStreams, "keep-data-streams", false, "流复制数据轨 (如 GoPro GPS 遥测)，仅 MP4/MOV 输出支持")
+	pflag.StringVar(&reportJSON, "report-json", "", "将任务报告另存为 JSON 文件")
+	pflag.IntVar(&runsKeep, "runs-keep", 20, "每次运行在 ~/.local/state/vc/runs/ 下保存报告与日志，只保留最近 N 次 (0 表示不保存)")
+	pflag.StringVar(&quarantineDir, "quarantine-dir", "", "连续失败多次的源文件移入该目录，之后的运行不再扫描 (用 vc quarantine list|release 查看或放回)")
+	pflag.IntVar(&quarantineAfter, "quarantine-after", 3, "连续失败多少次后隔离 (配合 --quarantine-dir)")
+	pflag.BoolVar(&dryRun, "dry-run", false, "只扫描并打印将要执行的任务，不进行编码")
+	pflag.StringVar(&diffReport, "diff", "", "与 --dry-run 一起使用：对比之前的 JSON 报告，列出会重新编码 (及原因)、跳过与新增的文件")
+	pflag.StringVar(&eventsPath, "events", "", "以 JSON Lines 格式输出进度事件到文件 (- 表示标准输出)")
+	pflag.IntVar(&progressFD, "progress-fd", -1, "将 JSON Lines 进度事件写入该文件描述符 (如 3)，标准输出/错误保持不变")
+	pflag.StringVar(&progressPipe, "progress-pipe", "", "将 JSON Lines 进度事件写入命名管道 (FIFO) 或文件，供外部监控程序读取")
+	pflag.StringVar(&retryFromReport, "retry-failed-from-report", "", "仅重新处理指定 JSON 报告中失败的文件")
+	pflag.StringVar(&sinceReport, "since-report", "", "增量处理：跳过指定 JSON 报告中已成功处理且大小、修改时间未变的文件")
+	pflag.BoolVar(&scanHidden, "scan-hidden", false, "扫描目录时包含隐藏文件与目录 (默认跳过以 . 开头的项，如 .Trash，以及 __MACOSX)")
+	pflag.BoolVar(&checkInput, "check-input", false, "编码前完整解码一遍输入，跳过损坏或截断的文件 (耗时与解码速度相关)")
+	pflag.BoolVar(&skipExisting, "skip-existing", false, "输出已存在且非空时直接跳过 (不再询问是否覆盖)")
+	pflag.StringVar(&skipCompressedBy, "skip-compressed-by", config.SkipByName, "判断已压缩的依据: name (文件名带 .compressed)、codec (已是目标编码且码率不高于 --compressed-max-bitrate) 或 both")
+	pflag.StringVar(&compressedMaxBitrate, "compressed-max-bitrate", "", "codec 判断时视为已压缩的码率上限 (如 6M)，默认按分辨率自动选择 (1080p 为 8M)")
+	pflag.StringVar(&markSource, "mark-source", "", "编码成功后在源文件上写入该扩展属性 (如 com.vc.compressed，Linux 上自动使用 user. 前缀)，之后的扫描跳过带有该属性的文件")
+	pflag.BoolVar(&clearMarks, "clear-marks", false, "清除输入路径中所有文件上的 --mark-source 属性后退出")
+	pflag.BoolVar(&allowCollision, "allow-collision", false, "不处理输出路径冲突 (同名输出将互相覆盖)")
+	pflag.Float64Var(&reportThreshold, "report-threshold", 0, "报告中只显示体积比 (新/原) 高于该值的文件，如 0.8")
+	pflag.BoolVar(&reportShowAll, "report-show-all", false, "忽略 --report-threshold，显示全部文件")
+	pflag.StringVar(&reportFormat, "report-format", report.FormatText, "最终报告格式: "+strings.Join(report.Formats, ", "))
+	pflag.StringVar(&reportFile, "report-file", "", "将最终报告写入该文件而不是终端 (适用于所有 --report-format)")
+	pflag.StringVar(&reportStyle, "report-style", "", "报告样式: plain (逐文件详细信息), wide (表格，不截断), compact (表格，适应终端宽度)；默认终端为 compact，重定向时为 plain")
+	pflag.IntVar(&videoStream, "video-stream", -1, "指定要编码的视频流序号 (ffprobe 中的 Stream #0:N)，默认自动选择并排除封面图")
+	pflag.IntVar(&maxHeight, "max-height", 0, "输出最大高度 (等比缩放，不放大)")
+	pflag.StringVar(&hwMaxResolution, "hw-max-resolution", config.DefaultHWMaxResolution, "硬件编码器支持的最大输出尺寸 (WxH)，超出时直接改用 libx265；none 表示不检查")
+	pflag.StringVar(&resolution, "resolution", "", "目标分辨率档位: 480p, 720p, 1080p, 1440p, 4k 或 source (限制长边与短边，竖屏与变形宽银幕按显示尺寸计算)")
+	pflag.BoolVar(&depthPassthrough, "color-depth-passthrough", true, "输出位深跟随源文件 (8-bit 源不再强制编码为 10-bit)")
+	pflag.IntVar(&bitDepth, "bit-depth", 0, "显式指定输出位深: 8 或 10 (优先于 --color-depth-passthrough)")
+	pflag.StringVar(&renditionSpec, "renditions", "", "为每个输入生成多个版本，如 \"archive:high:1080,web:standard:720\"")
+	pflag.Float64Var(&minRatio, "min-ratio", 0.05, "输出小于原文件该比例时提醒检查画质 (0 表示不检查)")
+	pflag.StringVar(&maxOutput, "max-output", "", "单个输出超过该体积 (如 2GB) 时终止编码并删除残留文件")
+	pflag.StringVar(&ioLimit, "io-limit", "", "所有并发任务合计的读取速率上限 (如 200M，单位 字节/秒)，按 -readrate 为每个任务限速")
+	pflag.StringVar(&outputModeSpec, "output-mode", "", "压缩成功后将输出文件权限设为该值 (如 0644)，新建的输出目录相应设为 0755")
+	pflag.BoolVar(&waitForSpace, "wait-for-space", false, "输出磁盘写满时暂停并等待空间释放 (默认终止剩余任务)")
+	pflag.BoolVar(&deleteOriginal, "delete-original", false, "压缩成功后将源文件移入废纸篓 (配合 --report-json 可用 vc restore 恢复)")
+	pflag.StringVar(&moveOriginalsTo, "move-originals-to", "", "压缩成功后将源文件移入该目录 (保留相对输入目录的结构)，确认无误后再自行删除")
+	pflag.BoolVar(&diagnose, "diagnose", false, "首个任务失败时以详细日志和软件解码重跑，打印可能的原因")
+	pflag.BoolVar(&visualCheck, "visual-check", false, "编码后抽取 3 帧与源文件比较亮度/色度，输出明显偏色 (如绿屏) 时按失败处理并改用软件编码重试")
+	pflag.BoolVar(&checksumOutput, "checksum-output", false, "压缩成功后在输出旁写入 .sha256 校验文件 (可用 shasum -c 校验)")
+	pflag.BoolVar(&groupByDir, "group-by-dir", false, "按目录连续处理：同一目录的文件一起调度 (目录内仍并行)，每个目录完成时立即提示")
+	pflag.StringVar(&order, "order", config.OrderScan, "同一优先级内的处理顺序: scan (扫描顺序), newest-first / oldest-first (按修改时间), largest-first (最大的文件优先)")
+	pflag.StringVar(&spaceBudget, "space-budget", "", "累计节省达到该体积 (如 100G) 后不再开始新任务，进行中的任务继续完成")
+	pflag.StringArrayVar(&priorityGlobs, "priority", nil, "优先处理匹配该 glob 的文件 (可重复指定)")
+	pflag.BoolVar(&banner, "banner", true, "显示启动信息与命令预览 (--banner=false 时只保留进度条与报告)")
+	pflag.BoolVar(&listEncoders, "list-encoders", false, "列出本机 ffmpeg 中可用的编码器后退出")
+	pflag.StringVar(&durationTolerance, "duration-tolerance", config.DefaultDurationTolerance, "编码后 (及 --two-dir-compare) 核对输出时长允许的偏差: 0.5s、1% 或两者 (取较大者)；none 表示不核对")
+	pflag.BoolVar(&twoDirCompare, "two-dir-compare", false, "核对输出目录: vc --two-dir-compare <源目录> <输出目录>，报告缺失或损坏的输出")
+	pflag.BoolVar(&verifyOnly, "verify-only", false, "不编码，只校验目录中已有的压缩输出 (*.compressed.*) 能否完整解码，结果写入报告")
+	pflag.BoolVar(&listPresets, "list-presets", false, "列出内置与 --preset-file 中定义的预设后退出")
+	pflag.StringVar(&profileName, "profile", "", "先载入 vc profile add 保存的命名配置，命令行中的其他参数覆盖配置中的值")
+
+	if len(os.Args) > 2 && os.Args[1] == "profile" {
+		os.Exit(runProfileAdd(os.Args[3:]))
+	}
+	// --profile: 配置中保存的参数放在命令行参数之前，同名参数以命令行为准
+	args, err := profile.Expand(os.Args[1:])
+	if err != nil {
+		fmt.Printf("错误: --profile: %v\n", err)
+		os.Exit(1)
+	}
+	_ = pflag.CommandLine.Parse(args)
+
+	var presets map[string]config.PresetDefinition
+	if presetFile != "" {
+		var err error
+		if presets, err = config.LoadPresets(presetFile); err != nil {
+			fmt.Printf("错误: 无法加载预设文件: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if listEncoders || listPresets {
+		code := 0
+		if listEncoders {
+			code = runListEncoders()
+		}
+		if listPresets {
+			if listEncoders {
+				fmt.Println()
+			}
+			code = max(code, runListPresets(presets))
+		}
+		os.Exit(code)
+	}
+
+	var tolerance config.DurationTolerance
+	if durationTolerance != "none" {
+		var err error
+		if tolerance, err = config.ParseDurationTolerance(durationTolerance); err != nil {
+			fmt.Printf("错误: --duration-tolerance: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if twoDirCompare {
+		if pflag.NArg() != 2 {
+			fmt.Println("Usage: vc --two-dir-compare <source_dir> <output_dir>")
+			os.Exit(1)
+		}
+		os.Exit(runTreeCompare(pflag.Arg(0), pflag.Arg(1), tolerance))
+	}
+
+	if verifyOnly {
+		if pflag.NArg() == 0 {
+			fmt.Println("Usage: vc --verify-only <output_dir>... [--report-json verify.json]")
+			os.Exit(1)
+		}
+		os.Exit(runVerifyOnly(pflag.Args(), reportJSON))
+	}
+
+	inputs := pflag.Args()
+	if retryFromReport != "" {
+		prev, err := report.LoadJSON(retryFromReport)
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+			os.Exit(1)
+		}
+		inputs = prev.FailedInputs()
+		fmt.Printf("从报告中载入 %d 个失败的文件\n", len(inputs))
+		if len(inputs) == 0 {
+			os.Exit(0)
+		}
+	}
+
+	// SVT-AV1 单进程即可占满所有核心，并发只会争抢 CPU 与内存
+	if strings.EqualFold(presetName, config.PresetArchive) && !pflag.CommandLine.Changed("workers") {
+		workers = 1
+	}
 
-	if len(pflag.Args()) == 0 {
-		fmt.Println("Usage: vc <input_file_or_dir> [flags]")
+	if len(inputs) == 0 {
+		fmt.Println("Usage: vc <input_file_or_dir>... [flags]")
+		fmt.Println("       vc check-deps")
+		fmt.Println("       vc --list-encoders | --list-presets [--preset-file presets.yaml]")
+		fmt.Println("       vc presets show <name> [--preset-file presets.yaml]")
+		fmt.Println("       vc --two-dir-compare <source_dir> <output_dir>")
+		fmt.Println("       vc --verify-only <output_dir>... [--report-json verify.json]")
+		fmt.Println("       vc <input_file_or_dir>... --clear-marks --mark-source <name>")
+		fmt.Println("       vc --profile <name> <input_file_or_dir>... [flags]  (vc profile list|show|add|delete 管理配置)")
+		fmt.Println("       vc split <input> <duration> [--output <dir>] [--split-at-keyframes]")
+		fmt.Println("       vc benchmark [--duration 30] [--workers 1,2,4]")
+		fmt.Println("       vc restore <report.json> [file...] [--remove-outputs]")
+		fmt.Println("       vc report [report.json | --last] [--report-format text|json|csv|markdown]")
+		fmt.Println("       vc report --compare <prev.json> <new.json>")
 		pflag.PrintDefaults()
 		os.Exit(1)
 	}
 
+	if customQuality < 0 || customQuality > 100 {
+		fmt.Printf("错误: --quality 取值范围为 1-100，当前为 %d\n", customQuality)
+		os.Exit(1)
+	}
+
+	if targetVMAF != 0 {
+		if targetVMAF < 0 || targetVMAF > 100 {
+			fmt.Printf("错误: --target-vmaf 取值范围为 0-100，当前为 %g\n", targetVMAF)
+			os.Exit(1)
+		}
+		if customQuality > 0 {
+			fmt.Println("错误: --target-vmaf 会为每个文件自动选择质量，不能与 --quality 同时使用")
+			os.Exit(1)
+		}
+		if vmafSample < time.Second || vmafIterations < 1 {
+			fmt.Println("错误: --vmaf-sample 至少为 1s，--vmaf-max-iterations 至少为 1")
+			os.Exit(1)
+		}
+		if ok, err := ffmpeg.HasFilter("libvmaf"); err == nil && !ok {
+			fmt.Println("错误: 本机 ffmpeg 不包含 libvmaf 滤镜，无法使用 --target-vmaf (macOS 可安装 homebrew-ffmpeg 并启用 --with-libvmaf)")
+			os.Exit(1)
+		}
+	}
+
+	if pinCores && !utils.AffinitySupported {
+		fmt.Println("⚠️ 当前平台不支持绑定 CPU 核心，--pin-cores 将被忽略")
+	}
+
+	if maxBitrateAuto <= 0 {
+		fmt.Printf("错误: --max-bitrate-auto 应大于 0，当前为 %g\n", maxBitrateAuto)
+		os.Exit(1)
+	}
+
+	var hwMax config.Resolution
+	if hwMaxResolution != "none" {
+		var err error
+		if hwMax, err = config.ParseDimensions(hwMaxResolution); err != nil {
+			fmt.Printf("错误: --hw-max-resolution: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if dateDirs != "" {
+		ref := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+		if out := ref.Format(dateDirs); out == dateDirs || filepath.IsAbs(dateDirs) || strings.Contains(out, "..") {
+			fmt.Printf("错误: --date-dirs %q 不是有效的相对日期格式 (Go 时间格式，如 2006/01 或 2006-01-02)\n", dateDirs)
+			os.Exit(1)
+		}
+	}
+
+	if statsPeriod < 0 || (statsPeriod > 0 && statsPeriod < 100*time.Millisecond) {
+		fmt.Println("错误: --stats-period 至少为 100ms (0 表示使用 ffmpeg 默认值)")
+		os.Exit(1)
+	}
+
+	if moveOriginalsTo != "" && deleteOriginal {
+		fmt.Println("错误: --move-originals-to 不能与 --delete-original 同时使用")
+		os.Exit(1)
+	}
+
+	if !slices.Contains(config.Orders, order) {
+		fmt.Printf("错误: --order 取值应为 %s 之一\n", strings.Join(config.Orders, ", "))
+		os.Exit(1)
+	}
+
+	if audioChannels < 0 || audioChannels > 8 {
+		fmt.Printf("错误: --audio-channels 取值范围为 1-8，当前为 %d\n", audioChannels)
+		os.Exit(1)
+	}
+	if audioChannelsAuto && audioChannels == 0 {
+		audioChannels = 2
+	}
+	if audioSampleRate != 0 && !slices.Contains(config.AudioSampleRates, audioSampleRate) {
+		fmt.Printf("错误: --audio-sample-rate 应为标准采样率之一: %s\n", strings.Trim(fmt.Sprint(config.AudioSampleRates), "[]"))
+		os.Exit(1)
+	}
+	if audioSampleRate != 0 && audioSampleRateAuto {
+		fmt.Println("错误: --audio-sample-rate 不能与 --audio-sample-rate-auto 同时使用")
+		os.Exit(1)
+	}
+	if copyAudio && (audioCodec != "" || audioBitrate != "" || audioFilter != "" || audioChannels > 0 || audioSampleRate > 0) {
+		fmt.Println("错误: --copy-audio 不能与 --audio-codec / --audio-bitrate / --audio-filter / --audio-channels / --audio-sample-rate 同时使用")
+		os.Exit(1)
+	}
+	if audioBitrate != "" && !audioBitrateRe.MatchString(audioBitrate) {
+		fmt.Printf("错误: --audio-bitrate 格式应为数字加 k/M，如 160k，当前为 %q\n", audioBitrate)
+		os.Exit(1)
+	}
+
+	if bitDepth != 0 && bitDepth != 8 && bitDepth != 10 {
+		fmt.Printf("错误: --bit-depth 取值应为 8 或 10，当前为 %d\n", bitDepth)
+		os.Exit(1)
+	}
+
+	if burnSubs != "" && burnSubs != config.BurnSubsAuto {
+		if _, err := os.Stat(burnSubs); err != nil {
+			fmt.Printf("错误: 字幕文件不可用: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if watermark != "" {
+		if _, err := os.Stat(watermark); err != nil {
+			fmt.Printf("错误: 无法读取水印图片: %v\n", err)
+			os.Exit(1)
+		}
+		if !slices.Contains(ffmpeg.WatermarkPositions, watermarkPos) {
+			fmt.Printf("错误: --watermark-position 取值应为 %s\n", strings.Join(ffmpeg.WatermarkPositions, ", "))
+			os.Exit(1)
+		}
+		if watermarkOpacity <= 0 || watermarkOpacity > 1 {
+			fmt.Printf("错误: --watermark-opacity 取值范围为 (0, 1]，当前为 %g\n", watermarkOpacity)
+			os.Exit(1)
+		}
+	}
+
+	var maxOutputBytes int64
+	if maxOutput != "" {
+		var err error
+		if maxOutputBytes, err = utils.ParseSize(maxOutput); err != nil {
+			fmt.Printf("错误: --max-output: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	for i, k := range metadataKeys {
+		k = strings.TrimSpace(k)
+		metadataKeys[i] = k
+		if k == "" || strings.ContainsAny(k, "=,") {
+			fmt.Printf("错误: --map-metadata-keys 中的标签名无效: %q\n", k)
+			os.Exit(1)
+		}
+	}
+
+	if skipCompressedBy != config.SkipByName && skipCompressedBy != config.SkipByCodec && skipCompressedBy != config.SkipByBoth {
+		fmt.Printf("错误: --skip-compressed-by 取值应为 %s, %s 或 %s\n", config.SkipByName, config.SkipByCodec, config.SkipByBoth)
+		os.Exit(1)
+	}
+	var compressedMaxBps int64
+	if compressedMaxBitrate != "" {
+		var err error
+		if compressedMaxBps, err = utils.ParseBitrate(compressedMaxBitrate); err != nil {
+			fmt.Printf("错误: --compressed-max-bitrate: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var ioLimitBytes int64
+	if ioLimit != "" {
+		var err error
+		if ioLimitBytes, err = utils.ParseSize(ioLimit); err != nil || ioLimitBytes == 0 {
+			fmt.Printf("错误: --io-limit: 无效的速率 %q (示例: 200M)\n", ioLimit)
+			os.Exit(1)
+		}
+	}
+
+	var outputMode os.FileMode
+	if outputModeSpec != "" {
+		var err error
+		if outputMode, err = utils.ParseFileMode(outputModeSpec); err != nil {
+			fmt.Printf("错误: --output-mode: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	renditions, err := config.ParseRenditions(renditionSpec, presets)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	var spaceBudgetBytes int64
+	if spaceBudget != "" {
+		if spaceBudgetBytes, err = utils.ParseSize(spaceBudget); err != nil || spaceBudgetBytes == 0 {
+			fmt.Printf("错误: --space-budget: 无效的体积 %q (示例: 100G)\n", spaceBudget)
+			os.Exit(1)
+		}
+		// 多个版本共享同一个源文件，无法按任务计算节省的空间
+		if len(renditions) > 0 {
+			fmt.Println("错误: --space-budget 不能与 --renditions 同时使用")
+			os.Exit(1)
+		}
+	}
+
+	routes, err := config.ParseRoutes(routeSpecs)
+	if err != nil {
+		fmt.Printf("错误: --route: %v\n", err)
+		os.Exit(1)
+	}
+
 	cfg := config.Config{
-		InputPath:  pflag.Args()[0],
+		InputPaths: inputs,
+		Extensions: config.ParseExtensions(extensions),
 		OutputPath: outputDir,
+		Routes:     routes,
+		DateDirs:   dateDirs,
 		Preset:     strings.ToLower(presetName),
 		Quality:    customQuality,
+		PresetFile: presetFile,
+		Presets:    presets,
 		Workers:    workers,
+		RampUp:     rampUp,
+
+		InputFormat:  inputFormat,
+		MetadataKeys: metadataKeys,
+
+		FFmpegThreads:      threads,
+		PinCores:           pinCores,
+		HWAccelDevice:      hwaccelDevice,
+		ScannerBufferBytes: bufferSize,
+		StatsPeriod:        statsPeriod,
+		TempDir:            tempDir,
+		WorkingDir:         workingDir,
+
+		PriorityFirst: priorityFirst,
+		PriorityGlobs: priorityGlobs,
+		Order:         order,
+		GroupByDir:    groupByDir,
+		SpaceBudget:   spaceBudgetBytes,
+		Dedupe:        dedupe,
+
+		IncludeAudioOnly:   includeAudioOnly,
+		AudioOnlyIfNoVideo: audioIfNoVideo,
+		AudioBitrate:       audioBitrate,
+		AudioCodec:         audioCodec,
+		CopyAudio:          copyAudio,
+		AudioFilter:        audioFilter,
+		AudioChannels:      audioChannels,
+		AudioChannelsAuto:  audioChannelsAuto,
+		SplitEvery:         splitEvery,
+
+		AudioSampleRate:     audioSampleRate,
+		AudioSampleRateAuto: audioSampleRateAuto,
+
+		SegmentSeconds:   segmentResume.Seconds(),
+		DisableSegResume: noSegResume,
+
+		KeepSubtitles:  keepSubtitles,
+		SubtitleFormat: subtitleFormat,
+		BurnSubs:       burnSubs,
+
+		Watermark:         watermark,
+		WatermarkPosition: watermarkPos,
+		WatermarkOpacity:  watermarkOpacity,
+		WatermarkPadding:  watermarkPadding,
+
+		SkipSpherical:  skipSpherical,
+		AllowCollision: allowCollision,
+		SkipExisting:   skipExisting,
+		CheckInput:     checkInput,
+		ScanHidden:     scanHidden,
+
+		SkipCompressedBy:     skipCompressedBy,
+		CompressedMaxBitrate: compressedMaxBps,
+
+		VideoStream:    videoStream,
+		MaxHeight:      maxHeight,
+		VideoFilter:    videoFilter,
+		Tonemap:        tonemap,
+		AutoRotate:     autoRotate,
+		Resolution:     resolution,
+		Renditions:     renditions,
+		WaitForSpace:   waitForSpace,
+		MaxOutputBytes: maxOutputBytes,
+		IOLimit:        ioLimitBytes,
+		MinOutputRatio: minRatio,
+		DeleteOriginal: deleteOriginal,
+		ChecksumOutput: checksumOutput,
+		OutputMode:     outputMode,
+		Diagnose:       diagnose,
+		VisualCheck:    visualCheck,
+		DryRun:         dryRun,
+
+		QuarantineDir:   quarantineDir,
+		QuarantineAfter: quarantineAfter,
+
+		MarkSource: markSource,
+
+		MaxBitrateFromInput: maxBitrateAuto,
+
+		HWMaxResolution: hwMax,
+		MoveOriginalsTo: moveOriginalsTo,
+
+		DurationTolerance: tolerance,
+
+		TargetVMAF:        targetVMAF,
+		VMAFSampleSeconds: vmafSample.Seconds(),
+		VMAFMaxIterations: vmafIterations,
+
+		ColorDepthPassthrough: depthPassthrough,
+		BitDepth:              bitDepth,
+
+		KeepDataStreams: keepDataStreams,
+
+		ReportThreshold: reportThreshold,
+		ReportShowAll:   reportShowAll,
+		ReportStyle:     reportStyle,
+		ReportFormat:    reportFormat,
+		ReportFile:      reportFile,
+	}
+
+	if resolution != "" && resolution != config.ResolutionSource {
+		if _, ok := config.LookupResolution(resolution); !ok {
+			fmt.Println("错误: --resolution 取值应为 480p, 720p, 1080p, 1440p, 4k 或 source")
+			os.Exit(1)
+		}
+		if maxHeight > 0 {
+			fmt.Println("错误: --resolution 不能与 --max-height 同时使用")
+			os.Exit(1)
+		}
+	}
+
+	if segmentResume < 0 || (segmentResume > 0 && segmentResume < time.Second) {
+		fmt.Println("错误: --segment-resume 至少为 1s")
+		os.Exit(1)
+	}
+	if segmentResume > 0 && splitEvery > 0 {
+		fmt.Println("错误: --segment-resume 不能与 --split-every 同时使用")
+		os.Exit(1)
+	}
+
+	if reportStyle != "" && !slices.Contains(config.ReportStyles, reportStyle) {
+		fmt.Printf("错误: --report-style 取值应为 %s\n", strings.Join(config.ReportStyles, ", "))
+		os.Exit(1)
+	}
+	if !slices.Contains(report.Formats, reportFormat) {
+		fmt.Printf("错误: --report-format 取值应为 %s\n", strings.Join(report.Formats, ", "))
+		os.Exit(1)
+	}
+
+	if quarantineDir != "" && quarantineAfter < 1 {
+		fmt.Println("错误: --quarantine-after 至少为 1")
+		os.Exit(1)
+	}
+
+	var baseline *report.Report
+	if sinceReport != "" {
+		if baseline, err = report.LoadJSON(sinceReport); err != nil {
+			fmt.Printf("错误: --since-report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if diffReport != "" && !dryRun {
+		fmt.Println("错误: --diff 需要与 --dry-run 一起使用")
+		os.Exit(1)
+	}
+
+	if markSource != "" && !utils.XattrSupported {
+		fmt.Println("错误: 当前平台不支持扩展属性，无法使用 --mark-source / --clear-marks")
+		os.Exit(1)
+	}
+
+	if clearMarks {
+		if markSource == "" {
+			fmt.Println("错误: --clear-marks 需要通过 --mark-source 指定属性名")
+			os.Exit(1)
+		}
+		n, err := compressor.ClearMarks(cfg)
+		fmt.Printf("🏷  已清除 %d 个文件上的 %s 标记\n", n, markSource)
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if cfg.Preset != config.PresetAuto && !cfg.KnownPreset(cfg.Preset) {
+		fmt.Printf("错误: 未知预设 %q\n", presetName)
+		os.Exit(1)
 	}
 
-	// 2. 扫描任务
-	fmt.Println("正在扫描文件并分析时长...")
+	if warn := ffmpeg.QualityWarning(cfg); warn != "" {
+		fmt.Printf("⚠️  质量提醒: %s\n", warn)
+	}
+
+	// 未指定 --working-dir 时在扫描完成后为本次运行新建，全部成功则自动删除
+	var autoWorkDir bool
+
+	// 2. 信号监听 (扫描与编码阶段均可用 Ctrl+C 中断)
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		fmt.Println("\n\n⚠️ 用户中断，正在退出...")
+		if cfg.TempDir != "" {
+			_ = os.RemoveAll(compressor.RunTempDir(cfg))
+		}
+		if autoWorkDir {
+			_ = os.RemoveAll(cfg.WorkingDir)
+		}
+		os.Exit(1)
+	}()
+
+	// 3. 扫描任务
+	if banner {
+		fmt.Println("正在扫描文件并分析时长...")
+	}
 	jobs, ignoredItems, totalDuration, err := compressor.ScanJobs(cfg)
 	if err != nil {
 		fmt.Printf("错误: %v\n", err)
 		os.Exit(1)
 	}
 
+	// --since-report: 只处理自上次报告以来新增或变化的文件
+	if baseline != nil {
+		var unchanged []compressor.ReportItem
+		jobs, unchanged = baseline.SkipUnchanged(jobs)
+		totalDuration = 0
+		for _, j := range jobs {
+			totalDuration += j.DurationSec
+		}
+		ignoredItems = append(ignoredItems, unchanged...)
+		fmt.Printf("📋 对比 %s: %d 个文件未变化，%d 个新增或已修改\n", sinceReport, len(unchanged), len(jobs))
+	}
+
+	if dryRun {
+		os.Exit(runDryRun(jobs, ignoredItems, cfg, diffReport))
+	}
+
 	if len(ignoredItems) > 0 {
-		fmt.Printf("已忽略 %d 个不需要压缩的文件 (文件名包含 .compressed)\n", len(ignoredItems))
+		fmt.Printf("已忽略 %d 个文件 (原因见任务报告)\n", len(ignoredItems))
 	}
 
 	if len(jobs) == 0 {
 		fmt.Println("未找到需要处理的视频文件。")
-		printReport(nil, ignoredItems)
+		emitReport(nil, ignoredItems, cfg)
+		saveReports(reportJSON, "", nil, ignoredItems)
 		os.Exit(0)
 	}
 	if len(jobs) == 1 {
 		cfg.Workers = 1
 	}
 
-	// 3. UI 初始化
-	fmt.Println("------------------------------------------------")
-	fmt.Printf("目标架构: Apple Silicon M2 Max\n")
-	fmt.Printf("待处理文件: %d 个 (总时长: %.1f 小时)\n", len(jobs), totalDuration/3600)
-	fmt.Printf("并发线程数: %d\n", cfg.Workers)
-
-	if len(jobs) > 0 {
-		sampleCmd := ffmpeg.BuildArgs(jobs[0].InputFile, jobs[0].OutputFile, cfg)
-		fmt.Printf("执行命令预览: ffmpeg %s\n", strings.Join(sampleCmd, " "))
+	if cfg.WorkingDir == "" && cfg.SegmentSeconds > 0 {
+		// 分段续传需要在多次运行之间找到已完成的分段，使用固定目录且不自动删除
+		cfg.WorkingDir = filepath.Join(cfg.TempDir, "vc-segments")
 	}
+	if cfg.WorkingDir == "" {
+		if dir, err := os.MkdirTemp(cfg.TempDir, "vc-"); err == nil {
+			cfg.WorkingDir, autoWorkDir = dir, true
+		}
+	}
+	if cfg.WorkingDir != "" {
+		if err := compressor.PrepareWorkDir(cfg.WorkingDir); err != nil {
+			fmt.Printf("错误: 无法创建工作目录: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// 4. UI 初始化
+	if banner {
+		fmt.Println("------------------------------------------------")
+		fmt.Printf("目标架构: Apple Silicon M2 Max\n")
+		fmt.Printf("待处理文件: %d 个 (总时长: %.1f 小时)\n", len(jobs), totalDuration/3600)
+		if n := compressor.CountUnknownDuration(jobs); n > 0 {
+			fmt.Printf("未知时长文件: %d 个 (不计入总体进度百分比)\n", n)
+		}
+		fmt.Printf("并发线程数: %d\n", cfg.Workers)
+		if cfg.FFmpegThreads > 0 {
+			fmt.Printf("线程分配: %d workers × %d 线程 = %d / %d CPU\n",
+				cfg.Workers, cfg.FFmpegThreads, cfg.Workers*cfg.FFmpegThreads, runtime.NumCPU())
+		} else if cfg.Workers > 1 {
+			fmt.Printf("线程分配: 未限制 (建议 --threads %d = %d CPU / %d workers)\n",
+				max(1, runtime.NumCPU()/cfg.Workers), runtime.NumCPU(), cfg.Workers)
+		}
+		if share := compressor.IOShare(cfg, len(jobs)); share > 0 {
+			fmt.Printf("I/O 限制: 合计 %.1f MB/s (每个任务 %.1f MB/s，按源文件码率换算为 -readrate)\n",
+				float64(cfg.IOLimit)/(1<<20), float64(share)/(1<<20))
+		}
+		if est := compressor.EstimateTotal(jobs, cfg.Workers); est > 0 {
+			fmt.Printf("预计总耗时: %s\n", formatEstimate(est))
+		}
+
+		if len(jobs) > 0 {
+			sampleCmd := jobs[0].BuildArgs(cfg)
+			fmt.Printf("执行命令预览: ffmpeg %s\n", strings.Join(sampleCmd, " "))
+		}
 
-	fmt.Println("------------------------------------------------")
+		fmt.Println("------------------------------------------------")
+	}
 
+	// 全部任务都无法获取时长时，进度条退化为 spinner
+	barMax := int64(totalDuration * 1000000)
+	if barMax <= 0 {
+		barMax = -1
+	}
 	bar := progressbar.NewOptions64(
-		int64(totalDuration*1000000),
-		progressbar.OptionSetDescription("总体进度"),
+		barMax,
+		progressbar.OptionSetDescription(compressor.BarDescription(jobs)),
 		progressbar.OptionSetWriter(os.Stderr),
 		progressbar.OptionSetWidth(20),
 		progressbar.OptionThrottle(100*time.Millisecond),
@@ -97,90 +778,401 @@ func main() {
 	)
 	_ = bar.RenderBlank()
 
-	// 4. 信号监听
-	go func() {
-		sig := make(chan os.Signal, 1)
-		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
-		<-sig
-		fmt.Println("\n\n⚠️ 用户中断，正在退出...")
-		os.Exit(1)
-	}()
-
 	// 5. 执行
+	// 运行目录：报告、事件日志与各任务日志，作为审计记录按 --runs-keep 轮换
+	if runsKeep > 0 {
+		if dir, err := report.NewRunDir(time.Now()); err != nil {
+			fmt.Printf("⚠️ 无法创建运行目录: %v\n", err)
+		} else {
+			cfg.RunDir = dir
+			if _, err := report.PruneRuns(runsKeep); err != nil {
+				fmt.Printf("⚠️ 清理旧的运行目录失败: %v\n", err)
+			}
+		}
+	}
+
+	// --events、--progress-fd 与 --progress-pipe 可同时使用，写出同一事件流
+	var sinks []io.Writer
+	if cfg.RunDir != "" {
+		if f, err := os.Create(filepath.Join(cfg.RunDir, "events.jsonl")); err == nil {
+			defer f.Close()
+			sinks = append(sinks, f)
+		}
+	}
+	if eventsPath != "" {
+		w := os.Stdout
+		if eventsPath != "-" {
+			f, err := os.Create(eventsPath)
+			if err != nil {
+				fmt.Printf("❌ 无法创建事件文件: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+		sinks = append(sinks, w)
+	}
+	if progressFD >= 0 {
+		f := os.NewFile(uintptr(progressFD), fmt.Sprintf("fd%d", progressFD))
+		if _, err := f.Stat(); err != nil {
+			fmt.Printf("❌ 文件描述符 %d 不可用: %v\n", progressFD, err)
+			os.Exit(1)
+		}
+		sinks = append(sinks, f)
+	}
+	if progressPipe != "" {
+		// 打开 FIFO 会阻塞到读取端连接为止
+		f, err := os.OpenFile(progressPipe, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Printf("❌ 无法打开进度管道: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		sinks = append(sinks, f)
+	}
+	var ev *events.Emitter
+	if len(sinks) > 0 {
+		ev = events.New(events.MultiWriter(sinks...), nil)
+	}
+
 	start := time.Now()
-	processedItems := compressor.Process(jobs, cfg, bar)
+	processedItems := compressor.Process(jobs, cfg, bar, ev)
 	_ = bar.Finish()
 
+	// --space-budget: 达到预算后未执行的任务按跳过处理
+	var reclaimed int64
+	budgetSkipped := 0
+	if cfg.SpaceBudget > 0 {
+		kept := processedItems[:0]
+		for _, item := range processedItems {
+			if item.Status == "Ignored" {
+				ignoredItems = append(ignoredItems, item)
+				budgetSkipped++
+				continue
+			}
+			reclaimed += compressor.SavedBytes(item)
+			kept = append(kept, item)
+		}
+		processedItems = kept
+	}
+
+	if cfg.Dedupe {
+		// 硬链接回收的空间单独提示，不计入 --space-budget 的已节省量 (预算只统计压缩节省)
+		linked, err := compressor.Dedupe(processedItems)
+		if err != nil {
+			fmt.Printf("⚠️ 去重失败: %v\n", err)
+		}
+		if linked > 0 {
+			fmt.Printf("🔗 去重完成，通过硬链接回收 %.1f MB\n", float64(linked)/1024/1024)
+		}
+	}
+
+	quarantined := 0
+	if cfg.QuarantineDir != "" {
+		var err error
+		if quarantined, err = compressor.Quarantine(processedItems, cfg); err != nil {
+			fmt.Printf("⚠️ 更新隔离状态失败: %v\n", err)
+		}
+	}
+
+	marked := 0
+	if cfg.MarkSource != "" {
+		marked = compressor.MarkSources(processedItems, cfg)
+	}
+
 	// 6. 打印最终报告
-	printReport(processedItems, ignoredItems)
+	emitReport(processedItems, ignoredItems, cfg)
+	if cfg.SpaceBudget > 0 {
+		if reclaimed >= cfg.SpaceBudget {
+			fmt.Printf("🧹 空间预算: 已节省 %s，达到目标 %s，%d 个任务未开始\n", formatSize(reclaimed), formatSize(cfg.SpaceBudget), budgetSkipped)
+		} else {
+			fmt.Printf("🧹 空间预算: 已节省 %s，未达到目标 %s\n", formatSize(reclaimed), formatSize(cfg.SpaceBudget))
+		}
+	}
+	if marked > 0 {
+		fmt.Printf("🏷  已在 %d 个源文件上写入 %s 标记，之后的 --mark-source 扫描将跳过它们\n", marked, cfg.MarkSource)
+	}
+	if quarantined > 0 {
+		fmt.Printf("\n🚧 %d 个源文件连续失败 %d 次，已移入隔离目录 %s (vc quarantine list %s 查看):\n",
+			quarantined, cfg.QuarantineAfter, cfg.QuarantineDir, cfg.QuarantineDir)
+		for _, item := range processedItems {
+			if item.Quarantined != "" && item.Status == "Failed" {
+				fmt.Printf("    %s -> %s\n", item.InputFile, item.Quarantined)
+			}
+		}
+	}
+	saveReports(reportJSON, cfg.RunDir, processedItems, ignoredItems)
+
+	if autoWorkDir {
+		if slices.ContainsFunc(processedItems, func(item compressor.ReportItem) bool { return item.Status == "Failed" }) {
+			fmt.Printf("🗂  存在失败的任务，诊断日志等中间文件保留在 %s (vc clean-work --dir %s 清理)\n", cfg.WorkingDir, cfg.WorkingDir)
+		} else {
+			_ = os.RemoveAll(cfg.WorkingDir)
+		}
+	}
 
 	fmt.Printf("\n✅ 所有任务完成! 总耗时: %s\n", time.Since(start).Round(time.Second))
+	if cfg.RunDir != "" {
+		fmt.Printf("🗂  运行记录: %s (vc report --last 查看)\n", cfg.RunDir)
+	}
+}
+
+// formatSize 将字节数格式化为 "1.5 GB" 形式 (按 1024 进位)
+func formatSize(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// formatEstimate 将耗时格式化为 "4h23m" / "12m" 形式
+func formatEstimate(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "<1m"
+	}
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	if h > 0 {
+		return fmt.Sprintf("%dh%02dm", h, m)
+	}
+	return fmt.Sprintf("%dm", m)
+}
+
+// emitReport 按 --report-format 输出最终报告，指定 --report-file 时写入该文件而不是终端
+// --report-json 与运行目录中的报告由 saveReports 另行写出，不受这里的格式影响
+func emitReport(processed, ignored []compressor.ReportItem, cfg config.Config) {
+	w, closeFile := io.Writer(os.Stdout), func() error { return nil }
+	if cfg.ReportFile != "" {
+		f, err := os.Create(cfg.ReportFile)
+		if err != nil {
+			fmt.Printf("⚠️ 写入报告文件失败: %v\n", err)
+			return
+		}
+		w, closeFile = f, f.Close
+	}
+
+	var err error
+	if cfg.ReportFormat == "" || cfg.ReportFormat == report.FormatText {
+		// 写入文件时 resolveReportStyle 检测到非终端，默认使用 plain 样式
+		printReport(w, processed, ignored, cfg)
+	} else {
+		err = report.Render(w, report.New(processed, ignored), cfg.ReportFormat)
+	}
+	if cerr := closeFile(); err == nil {
+		err = cerr
+	}
+	switch {
+	case err != nil:
+		fmt.Printf("⚠️ 写入报告失败: %v\n", err)
+	case cfg.ReportFile != "":
+		fmt.Printf("\n📄 任务报告 (%s) 已写入 %s\n", cmp.Or(cfg.ReportFormat, report.FormatText), cfg.ReportFile)
+	}
+}
+
+// saveReports 保存最近一次运行的报告 (供 vc report 查看)，写入运行目录 (runDir 非空时)，并按 --report-json 另存
+func saveReports(reportJSON, runDir string, processed, ignored []compressor.ReportItem) {
+	if err := report.SaveLastRun(processed, ignored); err != nil {
+		fmt.Printf("⚠️ 保存运行报告失败: %v\n", err)
+	}
+	if runDir != "" {
+		if err := report.WriteJSON(filepath.Join(runDir, report.RunReportFile), processed, ignored); err != nil {
+			fmt.Printf("⚠️ 写入运行目录报告失败: %v\n", err)
+		}
+	}
+	if reportJSON != "" {
+		if err := report.WriteJSON(reportJSON, processed, ignored); err != nil {
+			fmt.Printf("⚠️ 写入 JSON 报告失败: %v\n", err)
+		}
+	}
 }
 
 // printReport 打印任务总结报告 (列表模式)
 // [修改] 改为列表展示，以便完整显示长文件名和命令
-func printReport(processed, ignored []compressor.ReportItem) {
-	fmt.Println("\n📊 任务处理报告")
-	fmt.Println("================================================================================")
+func printReport(w io.Writer, processed, ignored []compressor.ReportItem, cfg config.Config) {
+	fmt.Fprintln(w, "\n📊 任务处理报告")
+	fmt.Fprintln(w, "================================================================================")
 
-	formatSize := func(b int64) string {
-		const unit = 1024
-		if b < unit {
-			return fmt.Sprintf("%d B", b)
-		}
-		div, exp := int64(unit), 0
-		for n := b / unit; n >= unit; n /= unit {
-			div *= unit
-			exp++
+	// --report-threshold: 只展示压缩效果不佳的文件，失败与跳过的文件始终展示
+	shown := processed
+	if cfg.ReportThreshold > 0 && !cfg.ReportShowAll {
+		shown = nil
+		for _, item := range processed {
+			if item.Status != "Processed" || item.OriginalSize <= 0 ||
+				float64(item.NewSize)/float64(item.OriginalSize) > cfg.ReportThreshold {
+				shown = append(shown, item)
+			}
 		}
-		return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+		fmt.Fprintf(w, "显示 %d/%d 个文件 (压缩效果不佳: 体积比 > %.0f%%)\n",
+			len(shown)+len(ignored), len(processed)+len(ignored), cfg.ReportThreshold*100)
+		fmt.Fprintln(w, "--------------------------------------------------------------------------------")
 	}
 
-	totalCount := len(processed) + len(ignored)
+	if style := resolveReportStyle(w, cfg.ReportStyle); style != config.ReportStylePlain {
+		printReportTable(w, shown, ignored, style)
+		printReportSummary(w, processed, ignored)
+		return
+	}
+
+	// 按目录分组展示：先列出处理过的文件 (成功/失败)，再列出被忽略的文件
+	items := append(append([]compressor.ReportItem{}, shown...), ignored...)
+	groups := report.GroupByDirectory(items)
 	index := 1
+	for _, dir := range slices.Sorted(maps.Keys(groups)) {
+		fmt.Fprintf(w, "── %s ──\n", strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+		for _, item := range groups[dir] {
+			if item.Status == "Ignored" {
+				fmt.Fprintf(w, "[%d/%d] 文件: %s\n", index, len(items), filepath.Base(item.InputFile))
+				fmt.Fprintf(w, "    ⚠️ 状态: 跳过\n")
+				fmt.Fprintf(w, "    📝 原因: %s\n", item.Reason)
+			} else {
+				printReportItem(w, item, index, len(items), cfg)
+			}
+			fmt.Fprintln(w, "--------------------------------------------------------------------------------")
+			index++
+		}
+		printDirSubtotal(w, groups[dir])
+	}
 
-	// 1. 打印处理过的文件
-	for _, item := range processed {
-		// 显示完整文件名，不进行截断
-		name := filepath.Base(item.InputFile)
+	printReportSummary(w, processed, ignored)
+}
 
-		fmt.Printf("[%d/%d] 文件: %s\n", index, totalCount, name)
+// printReportItem 以 plain 样式打印一个处理过 (成功或失败) 的文件
+func printReportItem(w io.Writer, item compressor.ReportItem, index, total int, cfg config.Config) {
+	// 显示完整文件名，不进行截断 (目录已在分组标题中)
+	fmt.Fprintf(w, "[%d/%d] 文件: %s\n", index, total, filepath.Base(item.InputFile))
 
-		if item.Status == "Failed" {
-			fmt.Printf("    🔴 状态: 失败\n")
-			fmt.Printf("    ❌ 原因: %s\n", item.Reason)
-		} else {
-			reduction := item.OriginalSize - item.NewSize
-			percent := 0.0
-			if item.OriginalSize > 0 {
-				percent = (float64(reduction) / float64(item.OriginalSize)) * 100
-			}
+	if item.Rendition != "" {
+		fmt.Fprintf(w, "    🎞  版本: %s -> %s\n", item.Rendition, filepath.Base(item.OutputFile))
+	}
+	if len(cfg.Routes) > 0 && item.OutputFile != "" {
+		fmt.Fprintf(w, "    📂 输出: %s\n", item.OutputFile)
+	}
+	if item.Preset != "" {
+		fmt.Fprintf(w, "    🎛  预设: %s (自动选择)\n", item.Preset)
+	}
+	if item.Audio != "" && item.Audio != "copy" {
+		fmt.Fprintf(w, "    🔊 音频: %s\n", item.Audio)
+	}
+	if item.Container != "" {
+		fmt.Fprintf(w, "    📦 容器: %s\n", item.Container)
+	}
+	if item.ReadRate > 0 {
+		fmt.Fprintf(w, "    💽 读取速率: %s/s\n", formatSize(item.ReadRate))
+	}
+	if item.BurnedSubs != "" {
+		fmt.Fprintf(w, "    💬 烧录字幕: %s\n", filepath.Base(item.BurnedSubs))
+	}
+	if item.VMAF != "" {
+		fmt.Fprintf(w, "    🎯 VMAF: %s\n", item.VMAF)
+	}
+	if item.Tonemap != "" {
+		fmt.Fprintf(w, "    🌗 HDR → SDR: %s\n", item.Tonemap)
+	}
+	if item.Fallback != "" {
+		fmt.Fprintf(w, "    🔁 编码降级: %s\n", item.Fallback)
+	}
+	if item.AutoFix != "" {
+		fmt.Fprintf(w, "    🩹 自动修复: 已追加 %s 重试\n", item.AutoFix)
+	}
+	if item.Status == "Failed" {
+		fmt.Fprintf(w, "    🔴 状态: 失败\n")
+		fmt.Fprintf(w, "    ❌ 原因: %s\n", item.Reason)
+		if item.Diagnosis != "" {
+			fmt.Fprintf(w, "    🩺 诊断: %s\n", item.Diagnosis)
+		}
+		if item.Quarantined != "" {
+			fmt.Fprintf(w, "    🚧 已隔离: %s\n", item.Quarantined)
+		}
+	} else {
+		reduction := item.OriginalSize - item.NewSize
+		percent := 0.0
+		if item.OriginalSize > 0 {
+			percent = (float64(reduction) / float64(item.OriginalSize)) * 100
+		}
 
-			fmt.Printf("    ✅ 状态: 完成\n")
-			fmt.Printf("    📉 数据: %s -> %s (减少: %s / %.1f%%)\n",
-				formatSize(item.OriginalSize),
-				formatSize(item.NewSize),
-				formatSize(reduction),
-				percent,
-			)
-			// 显示完整命令
-			fmt.Printf("    🛠  命令: %s\n", item.Command)
+		fmt.Fprintf(w, "    ✅ 状态: 完成\n")
+		fmt.Fprintf(w, "    📉 数据: %s -> %s (减少: %s / %.1f%%)\n",
+			formatSize(item.OriginalSize),
+			formatSize(item.NewSize),
+			formatSize(reduction),
+			percent,
+		)
+		if len(item.Segments) > 0 {
+			fmt.Fprintf(w, "    ✂️  分段: %d 个文件 (%s ... %s)\n", len(item.Segments),
+				filepath.Base(item.Segments[0]), filepath.Base(item.Segments[len(item.Segments)-1]))
 		}
-		fmt.Println("--------------------------------------------------------------------------------")
-		index++
+		switch item.Spherical {
+		case "preserved":
+			fmt.Fprintf(w, "    🌐 全景: 已保留 360° 元数据\n")
+		case "lost":
+			fmt.Fprintf(w, "    ⚠️ 全景: 360° 元数据未能保留，输出将按普通视频播放\n")
+		}
+		switch item.DataStreams {
+		case "preserved":
+			fmt.Fprintf(w, "    🛰  数据流: 已保留\n")
+		case "lost":
+			fmt.Fprintf(w, "    ⚠️ 数据流: 未能写入输出\n")
+		case "skipped":
+			fmt.Fprintf(w, "    ⚠️ 数据流: 输出容器不支持，已跳过\n")
+		}
+		if len(item.Checksums) > 0 {
+			fmt.Fprintf(w, "    🔏 校验: 已写入 %s\n", filepath.Base(item.Checksums[0]))
+		}
+		for _, warning := range item.Warnings {
+			fmt.Fprintf(w, "    %s\n", warning)
+		}
+		if item.TrashedPath != "" {
+			fmt.Fprintf(w, "    🗑  源文件: 已移入废纸篓 (%s)\n", item.TrashedPath)
+		}
+		if item.MovedTo != "" {
+			fmt.Fprintf(w, "    📦 源文件: 已移至 %s\n", item.MovedTo)
+		} else if item.Reason != "" {
+			fmt.Fprintf(w, "    ⚠️ 提示: %s\n", item.Reason)
+		}
+		if item.Superseded {
+			fmt.Fprintln(w, "    ♻️  源文件: 已被重新压缩的输出替换")
+		}
+		if item.LinkedTo != "" {
+			fmt.Fprintf(w, "    🔗 硬链接: 与 %s 内容一致\n", item.LinkedTo)
+		}
+		// 显示完整命令
+		fmt.Fprintf(w, "    🛠  命令: %s\n", item.Command)
 	}
+}
 
-	// 2. 打印被忽略的文件
-	for _, item := range ignored {
-		name := filepath.Base(item.InputFile)
-		fmt.Printf("[%d/%d] 文件: %s\n", index, totalCount, name)
-		fmt.Printf("    ⚠️ 状态: 跳过\n")
-		fmt.Printf("    📝 原因: %s\n", item.Reason)
-		fmt.Println("--------------------------------------------------------------------------------")
-		index++
+// printDirSubtotal 打印一个目录分组的小计：文件数、节省的空间与平均体积比
+func printDirSubtotal(w io.Writer, items []compressor.ReportItem) {
+	var saved int64
+	var ratioSum float64
+	ratioCount := 0
+	for _, item := range items {
+		if item.Status != "Processed" {
+			continue
+		}
+		saved += item.OriginalSize - item.NewSize
+		if r := report.Ratio(item); r > 0 {
+			ratioSum += r
+			ratioCount++
+		}
+	}
+	line := fmt.Sprintf("小计: %d 个文件 | 节省 %s", len(items), formatSize(saved))
+	if ratioCount > 0 {
+		line += fmt.Sprintf(" | 平均体积比 %.1f%%", ratioSum/float64(ratioCount)*100)
 	}
+	fmt.Fprintln(w, line)
+	fmt.Fprintln(w)
+}
 
-	// 3. 统计汇总
+// printReportSummary 打印报告末尾的统计行
+func printReportSummary(w io.Writer, processed, ignored []compressor.ReportItem) {
+	totalCount := len(processed) + len(ignored)
 	successCount := 0
 	failCount := 0
 	for _, p := range processed {
@@ -191,7 +1183,17 @@ func printReport(processed, ignored []compressor.ReportItem) {
 		}
 	}
 
-	fmt.Printf("统计: 总计 %d | 成功 %d | 失败 %d | 跳过 %d\n",
+	fmt.Fprintf(w, "统计: 总计 %d | 成功 %d | 失败 %d | 跳过 %d\n",
 		totalCount, successCount, failCount, len(ignored))
-	fmt.Println("================================================================================")
+	// DRM 与无法解码的文件无法通过重试解决，单独列出数量
+	undecodable := 0
+	for _, item := range ignored {
+		if compressor.IsUndecodable(item) {
+			undecodable++
+		}
+	}
+	if undecodable > 0 {
+		fmt.Fprintf(w, "其中 DRM 保护或无法解码: %d (需先用其他工具解密或转换)\n", undecodable)
+	}
+	fmt.Fprintln(w, "================================================================================")
 }
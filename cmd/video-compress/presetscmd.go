@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"video-compress/internal/config"
+	"video-compress/internal/ffmpeg"
+
+	"github.com/spf13/pflag"
+)
+
+// sampleInput 是 vc presets show 示例命令假设的输入：1080p、8-bit、立体声
+var sampleInput = ffmpeg.InputInfo{
+	VideoStream:   0,
+	Width:         1920,
+	Height:        1080,
+	PixFmt:        "yuv420p",
+	BitDepth:      8,
+	AudioChannels: 2,
+}
+
+// runPresets 实现 vc presets list|show：列出预设，或打印某个预设的实际设置与示例命令
+func runPresets(args []string) int {
+	fs := pflag.NewFlagSet("presets", pflag.ExitOnError)
+	presetFile := fs.String("preset-file", "", "同时载入 YAML 文件中的自定义预设")
+	quality := fs.Int("quality", 0, "show 时按该 --quality 计算质量参数 (0 表示使用预设默认值)")
+	_ = fs.Parse(args)
+
+	usage := func() int {
+		fmt.Println("Usage: vc presets list [--preset-file presets.yaml]")
+		fmt.Println("       vc presets show <name> [--preset-file presets.yaml] [--quality N]")
+		return 1
+	}
+	if fs.NArg() < 1 {
+		return usage()
+	}
+
+	var presets map[string]config.PresetDefinition
+	if *presetFile != "" {
+		var err error
+		if presets, err = config.LoadPresets(*presetFile); err != nil {
+			fmt.Printf("错误: %v\n", err)
+			return 1
+		}
+	}
+
+	switch fs.Arg(0) {
+	case "list":
+		return runListPresets(presets)
+	case "show":
+		if fs.NArg() != 2 {
+			return usage()
+		}
+		cfg := config.Config{Preset: strings.ToLower(fs.Arg(1)), Presets: presets, Quality: *quality}
+		if !cfg.KnownPreset(cfg.Preset) {
+			fmt.Printf("错误: 未知预设 %q (vc presets list 查看全部预设)\n", fs.Arg(1))
+			return 1
+		}
+		printPreset(cfg)
+		return 0
+	}
+	return usage()
+}
+
+// printPreset 打印 cfg 当前预设的实际设置与示例命令
+func printPreset(cfg config.Config) {
+	p := ffmpeg.ResolvePreset(cfg)
+	source := "内置"
+	if _, ok := cfg.CustomPreset(); ok {
+		source = "自定义 (--preset-file)"
+	}
+	fmt.Printf("🎛  预设: %s (%s)\n", p.Name, source)
+	if p.Description != "" {
+		fmt.Printf("    说明: %s\n", p.Description)
+	}
+	fmt.Printf("    视频编码器: %s (输出 %s)\n", p.Encoder, ffmpeg.TargetCodec(cfg))
+
+	flag, q := ffmpeg.NativeQuality(cfg)
+	switch {
+	case cfg.Quality > 0:
+		fmt.Printf("    质量: --quality %d -> -%s %d\n", cfg.Quality, flag, q)
+	case q > 0:
+		fmt.Printf("    质量: -%s %d (默认)\n", flag, q)
+	default:
+		fmt.Printf("    质量: 不传质量参数 (编码器默认值)\n")
+	}
+	var mapping []string
+	for _, v := range []int{25, 50, 75, 100} {
+		mapping = append(mapping, fmt.Sprintf("%d -> %d", v, p.MapQuality(v)))
+	}
+	fmt.Printf("    --quality 映射: %s\n", strings.Join(mapping, ", "))
+
+	if len(p.Args) > 0 {
+		fmt.Printf("    编码参数: %s\n", strings.Join(p.Args, " "))
+	}
+	if len(p.X265Params) > 0 {
+		fmt.Printf("    x265 参数: %s\n", strings.Join(p.X265Params, ":"))
+	}
+	switch p.Pixels {
+	case ffmpeg.PixelsHardware:
+		fmt.Printf("    像素格式: videotoolbox 按位深选择 main/main10，编码器不接受的源格式先转换\n")
+	case ffmpeg.PixelsSoftware:
+		fmt.Printf("    像素格式: 编码前转换为 yuv420p (--bit-depth 10 时为 yuv420p10le)\n")
+	case ffmpeg.PixelsSoftware10:
+		fmt.Printf("    像素格式: 默认 10-bit (yuv420p10le)，--bit-depth 8 时为 yuv420p\n")
+	default:
+		fmt.Printf("    像素格式: 不处理 (由预设参数决定)\n")
+	}
+
+	codec, bitrate := ffmpeg.AudioPlan(cfg, sampleInput)
+	if codec == "copy" {
+		fmt.Printf("    音频: 流复制 (旧容器的音频或指定 --audio-* 时转码为 %s)\n", ffmpeg.LegacyAudioCodec)
+	} else {
+		fmt.Printf("    音频: %s %s\n", codec, bitrate)
+	}
+	fmt.Println("    容器: 与输入相同 (avi/wmv 等旧容器输出为 .mp4)")
+
+	args := ffmpeg.BuildArgs("input.mp4", "input.compressed.mp4", cfg, sampleInput)
+	fmt.Printf("\n示例命令 (1080p 8-bit 输入):\n    ffmpeg %s\n", strings.Join(args, " "))
+}
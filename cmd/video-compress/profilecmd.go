@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"video-compress/internal/profile"
+
+	"github.com/spf13/pflag"
+)
+
+// profileUsage 打印 vc profile 的用法
+func profileUsage() int {
+	fmt.Println("Usage: vc profile list")
+	fmt.Println("       vc profile show <name>")
+	fmt.Println("       vc profile add <name> [flags...]")
+	fmt.Println("       vc profile delete <name>")
+	fmt.Println("       vc --profile <name> <input_file_or_dir>... [flags]")
+	return 1
+}
+
+// runProfile 实现 vc profile list|show|delete：查看或删除保存的命名配置
+// add 需要主命令的参数定义来校验参数，由 runProfileAdd 处理
+func runProfile(args []string) int {
+	if len(args) == 0 {
+		return profileUsage()
+	}
+	switch args[0] {
+	case "list":
+		profiles, err := profile.All()
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+			return 1
+		}
+		if len(profiles) == 0 {
+			fmt.Println("尚未保存任何配置 (vc profile add <name> [flags...] 添加)。")
+		}
+		for _, name := range profile.Names(profiles) {
+			fmt.Printf("🗂  %s: %s\n", name, profile.Format(profiles[name]))
+		}
+		return 0
+
+	case "show":
+		if len(args) != 2 {
+			return profileUsage()
+		}
+		saved, err := profile.Load(args[1])
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+			return 1
+		}
+		fmt.Printf("🗂  配置: %s\n", args[1])
+		fmt.Printf("    参数: %s\n", profile.Format(saved))
+		fmt.Printf("    vc --profile %s <input>... 等同于 vc <input>... %s\n", args[1], profile.Format(saved))
+		return 0
+
+	case "delete":
+		if len(args) != 2 {
+			return profileUsage()
+		}
+		if err := profile.Delete(args[1]); err != nil {
+			fmt.Printf("错误: %v\n", err)
+			return 1
+		}
+		fmt.Printf("🗑  已删除配置 %s\n", args[1])
+		return 0
+	}
+	return profileUsage()
+}
+
+// runProfileAdd 实现 vc profile add <name> [flags...]
+// 在主命令的参数定义完成后调用：先按主命令的规则解析一遍，拼写错误的参数在保存前就会报错
+func runProfileAdd(args []string) int {
+	if len(args) < 2 {
+		return profileUsage()
+	}
+	name, flags := args[0], args[1:]
+	if !profile.ValidName(name) {
+		fmt.Printf("错误: 配置名 %q 只能包含字母、数字与 . _ -\n", name)
+		return 1
+	}
+	_ = pflag.CommandLine.Parse(flags)
+	if pflag.NArg() > 0 {
+		fmt.Printf("错误: 配置中只能保存参数，不能包含输入路径: %s\n", strings.Join(pflag.Args(), " "))
+		return 1
+	}
+	if pflag.CommandLine.Changed("profile") {
+		fmt.Println("错误: 配置中不能再引用 --profile")
+		return 1
+	}
+
+	existing, err := profile.All()
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+	if err := profile.Save(name, flags); err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+	verb := "已保存"
+	if _, ok := existing[name]; ok {
+		verb = "已覆盖"
+	}
+	fmt.Printf("✅ %s配置 %s: %s\n", verb, name, profile.Format(flags))
+	fmt.Printf("    使用: vc --profile %s <input>... (命令行中的参数覆盖配置中的值)\n", name)
+	return 0
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"video-compress/internal/compressor"
+
+	"github.com/spf13/pflag"
+)
+
+// runQuarantine 实现 vc quarantine list|release：查看隔离的源文件，或将其放回原位置
+func runQuarantine(args []string) int {
+	fs := pflag.NewFlagSet("quarantine", pflag.ExitOnError)
+	all := fs.Bool("all", false, "release 时放回全部隔离的文件")
+	_ = fs.Parse(args)
+
+	usage := func() int {
+		fmt.Println("Usage: vc quarantine list <quarantine-dir>")
+		fmt.Println("       vc quarantine release <quarantine-dir> <file...> | --all")
+		return 1
+	}
+	if fs.NArg() < 2 {
+		return usage()
+	}
+
+	store, err := compressor.OpenQuarantine(fs.Arg(1))
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+
+	switch fs.Arg(0) {
+	case "list":
+		if len(store.Entries) == 0 {
+			fmt.Println("隔离目录中没有文件。")
+		}
+		for _, e := range store.Entries {
+			fmt.Printf("🚧 %s\n", filepath.Base(e.Path))
+			fmt.Printf("    原位置: %s\n", e.Original)
+			fmt.Printf("    连续失败: %d 次 (隔离于 %s)\n", e.Failures, e.Time.Format("2006-01-02 15:04"))
+			if e.Reason != "" {
+				fmt.Printf("    原因: %s\n", e.Reason)
+			}
+		}
+		if n := len(store.Failures); n > 0 {
+			fmt.Printf("另有 %d 个文件有失败记录但尚未达到隔离次数\n", n)
+		}
+		return 0
+
+	case "release":
+		names := fs.Args()[2:]
+		if len(names) == 0 && !*all {
+			return usage()
+		}
+		matches := func(e compressor.QuarantineEntry) bool {
+			if *all {
+				return true
+			}
+			for _, n := range names {
+				abs, _ := filepath.Abs(n)
+				if n == filepath.Base(e.Path) || abs == e.Path || abs == e.Original {
+					return true
+				}
+			}
+			return false
+		}
+
+		released, failed := 0, 0
+		for _, e := range append([]compressor.QuarantineEntry{}, store.Entries...) {
+			if !matches(e) {
+				continue
+			}
+			if err := store.Release(e); err != nil {
+				fmt.Printf("❌ %s: %v\n", filepath.Base(e.Path), err)
+				failed++
+				continue
+			}
+			fmt.Printf("↩️  %s -> %s\n", filepath.Base(e.Path), e.Original)
+			released++
+		}
+		if err := store.Save(); err != nil {
+			fmt.Printf("错误: 保存隔离状态失败: %v\n", err)
+			return 1
+		}
+		fmt.Printf("已放回 %d 个文件，失败 %d 个\n", released, failed)
+		if failed > 0 {
+			return 1
+		}
+		return 0
+	}
+	return usage()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"video-compress/internal/compressor"
+	"video-compress/internal/config"
+	"video-compress/internal/report"
+)
+
+var testReportItems = []compressor.ReportItem{
+	{InputFile: "/videos/a.mov", OutputFile: "/videos/a.compressed.mp4", Status: "Processed", OriginalSize: 4 << 20, NewSize: 1 << 20, Command: "ffmpeg -i a.mov"},
+	{InputFile: "/videos/b.mov", Status: "Failed", Reason: "exit status 1"},
+}
+
+// 非终端的 Writer 默认使用 plain 样式，且不带颜色
+func TestPrintReportToWriter(t *testing.T) {
+	var buf bytes.Buffer
+	printReport(&buf, testReportItems, nil, config.Config{})
+	out := buf.String()
+	for _, want := range []string{"📊 任务处理报告", "[1/2] 文件: a.mov", "✅ 状态: 完成", "❌ 原因: exit status 1", "统计: 总计 2 | 成功 1 | 失败 1 | 跳过 0"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	printReport(&buf, testReportItems, nil, config.Config{ReportStyle: config.ReportStyleWide})
+	if out := buf.String(); !strings.Contains(out, "STATUS") || strings.Contains(out, colorReset) {
+		t.Errorf("wide report to a buffer should be an uncolored table:\n%s", out)
+	}
+}
+
+// --report-file 写入文件，标准输出保持不变
+func TestEmitReportFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+	stdout := os.Stdout
+	emitReport(testReportItems, nil, config.Config{ReportFile: path, ReportFormat: report.FormatText})
+	if os.Stdout != stdout {
+		t.Fatal("emitReport replaced os.Stdout")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "[2/2] 文件: b.mov") {
+		t.Errorf("report file missing items:\n%s", data)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"video-compress/internal/compressor"
+	"video-compress/internal/config"
+	"video-compress/internal/report"
+
+	"github.com/spf13/pflag"
+)
+
+// runReport 实现 vc report：重新显示之前运行的报告，或对比两次运行
+func runReport(args []string) int {
+	fs := pflag.NewFlagSet("report", pflag.ExitOnError)
+	format := fs.String("report-format", report.FormatText, "输出格式: "+strings.Join(report.Formats, ", "))
+	style := fs.String("report-style", "", "文本报告样式: "+strings.Join(config.ReportStyles, ", "))
+	compare := fs.Bool("compare", false, "对比两份报告: vc report --compare <prev.json> <new.json>")
+	last := fs.Bool("last", false, "显示最近一次运行目录 (~/.local/state/vc/runs/) 中的报告")
+	_ = fs.Parse(args)
+
+	if !slices.Contains(report.Formats, *format) {
+		fmt.Printf("错误: --report-format 取值应为 %s\n", strings.Join(report.Formats, ", "))
+		return 1
+	}
+
+	if *style != "" && !slices.Contains(config.ReportStyles, *style) {
+		fmt.Printf("错误: --report-style 取值应为 %s\n", strings.Join(config.ReportStyles, ", "))
+		return 1
+	}
+
+	if *compare {
+		if fs.NArg() != 2 {
+			fmt.Println("Usage: vc report --compare <prev.json> <new.json>")
+			return 1
+		}
+		prev, err := report.LoadJSON(fs.Arg(0))
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+			return 1
+		}
+		next, err := report.LoadJSON(fs.Arg(1))
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+			return 1
+		}
+		printComparison(report.Compare(prev, next))
+		return 0
+	}
+
+	path := fs.Arg(0)
+	if *last {
+		dir, err := report.LatestRun()
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+			return 1
+		}
+		path = filepath.Join(dir, report.RunReportFile)
+	}
+	if path == "" {
+		var err error
+		if path, err = report.LastRunPath(); err != nil {
+			fmt.Printf("错误: %v\n", err)
+			return 1
+		}
+	}
+	r, err := report.LoadJSON(path)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+
+	if *format != report.FormatText {
+		if err := report.Render(os.Stdout, r, *format); err != nil {
+			fmt.Printf("错误: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Printf("报告: %s (生成于 %s)\n", path, r.GeneratedAt.Format("2006-01-02 15:04:05"))
+	processed, ignored := r.Split()
+	printReport(os.Stdout, processed, ignored, config.Config{ReportStyle: *style})
+	return 0
+}
+
+// printComparison 打印两次运行之间的体积比变化
+func printComparison(changes []report.Change) {
+	labels := map[string]string{
+		report.ChangeImproved:  "📉 改善",
+		report.ChangeDegraded:  "📈 变差",
+		report.ChangeUnchanged: "➖ 不变",
+		report.ChangeAdded:     "🆕 新增",
+		report.ChangeRemoved:   "🗑  移除",
+	}
+	ratio := func(v float64) string {
+		if v == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f%%", v*100)
+	}
+
+	counts := make(map[string]int)
+	fmt.Println("\n📊 报告对比 (体积比 = 新/原，越低越好)")
+	fmt.Println("================================================================================")
+	for _, c := range changes {
+		counts[c.Kind]++
+		name := filepath.Base(c.InputFile)
+		if c.Rendition != "" {
+			name += " [" + c.Rendition + "]"
+		}
+		fmt.Printf("%s  %-40s %7s -> %7s\n", labels[c.Kind], name, ratio(c.PrevRatio), ratio(c.NewRatio))
+	}
+	fmt.Println("================================================================================")
+	fmt.Printf("统计: 改善 %d | 变差 %d | 不变 %d | 新增 %d | 移除 %d\n",
+		counts[report.ChangeImproved], counts[report.ChangeDegraded], counts[report.ChangeUnchanged],
+		counts[report.ChangeAdded], counts[report.ChangeRemoved])
+}
+
+// runDryRun 打印扫描得到的任务计划；diffPath 非空时与之前的报告对比
+func runDryRun(jobs []compressor.Job, ignored []compressor.ReportItem, cfg config.Config, diffPath string) int {
+	if diffPath == "" {
+		fmt.Printf("\n📝 计划 (--dry-run): %d 个任务，%d 个文件跳过\n", len(jobs), len(ignored))
+		for i, j := range jobs {
+			fmt.Printf("[%d/%d] %s -> %s\n", i+1, len(jobs), j.InputFile, j.OutputFile)
+			fmt.Printf("    ffmpeg %s\n", strings.Join(j.BuildArgs(cfg), " "))
+		}
+		for _, item := range ignored {
+			fmt.Printf("⏭  %s (%s)\n", item.InputFile, item.Reason)
+		}
+		return 0
+	}
+
+	prev, err := report.LoadJSON(diffPath)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+	printPlan(report.Plan(prev, jobs, ignored, cfg))
+	return 0
+}
+
+// printPlan 打印与之前报告对比后的重跑计划
+func printPlan(entries []report.PlanEntry) {
+	labels := map[string]string{
+		report.PlanNew:             "🆕 新增",
+		report.PlanSettingsChanged: "🔧 设置变化",
+		report.PlanSourceChanged:   "✏️  源文件变化",
+		report.PlanRetry:           "🔁 上次未成功",
+		report.PlanUnchanged:       "➖ 无变化",
+		report.PlanSkip:            "⏭  跳过",
+	}
+
+	counts := make(map[string]int)
+	fmt.Println("\n📝 重跑计划 (--dry-run --diff)")
+	fmt.Println("================================================================================")
+	for _, e := range entries {
+		counts[e.Kind]++
+		name := filepath.Base(e.InputFile)
+		if e.Rendition != "" {
+			name += " [" + e.Rendition + "]"
+		}
+		if e.Reason != "" {
+			fmt.Printf("%s  %s (%s)\n", labels[e.Kind], name, e.Reason)
+		} else {
+			fmt.Printf("%s  %s\n", labels[e.Kind], name)
+		}
+	}
+	fmt.Println("================================================================================")
+	encode := counts[report.PlanNew] + counts[report.PlanSettingsChanged] + counts[report.PlanSourceChanged] + counts[report.PlanRetry]
+	fmt.Printf("将编码 %d 个 (新增 %d | 设置变化 %d | 源文件变化 %d | 重试 %d)，无变化 %d，跳过 %d\n",
+		encode, counts[report.PlanNew], counts[report.PlanSettingsChanged], counts[report.PlanSourceChanged],
+		counts[report.PlanRetry], counts[report.PlanUnchanged], counts[report.PlanSkip])
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"video-compress/internal/compressor"
+	"video-compress/internal/config"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/term"
+)
+
+// ANSI 颜色，仅在报告输出到终端时使用
+const (
+	colorGreen  = "\033[32m"
+	colorRed    = "\033[31m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+// defaultTermWidth 是无法获取终端宽度时使用的宽度
+const defaultTermWidth = 120
+
+// isTerminal 判断报告的输出目标是否为终端
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// resolveReportStyle 返回实际使用的报告样式：未指定时终端使用 compact，重定向时保持 plain 以兼容解析旧格式的脚本
+func resolveReportStyle(w io.Writer, style string) string {
+	if style != "" {
+		return style
+	}
+	if isTerminal(w) {
+		return config.ReportStyleCompact
+	}
+	return config.ReportStylePlain
+}
+
+// termWidth 返回 w 所在终端的列数，w 不是终端时按 $COLUMNS 或默认宽度
+func termWidth(w io.Writer) int {
+	if f, ok := w.(*os.File); ok {
+		if cols, _, err := term.GetSize(int(f.Fd())); err == nil && cols > 0 {
+			return cols
+		}
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return defaultTermWidth
+}
+
+// reportRow 是表格中的一行
+type reportRow struct {
+	status, color string
+	ratio         string
+	sizes         string
+	path          string
+	note          string
+}
+
+// printReportTable 以表格形式打印报告 (每个文件一行)
+// compact 按终端宽度截断：路径获得剩余宽度，原因列最多占三分之一；wide 不截断
+func printReportTable(w io.Writer, shown, ignored []compressor.ReportItem, style string) {
+	useColor := isTerminal(w) && os.Getenv("NO_COLOR") == ""
+
+	var rows []reportRow
+	for _, item := range shown {
+		r := reportRow{path: displayPath(item.InputFile)}
+		if item.Rendition != "" {
+			r.path += " [" + item.Rendition + "]"
+		}
+		switch {
+		case item.Status == "Failed":
+			r.status, r.color = "FAIL", colorRed
+			r.note = item.Reason
+			if item.Quarantined != "" {
+				r.note += " (quarantined)"
+			}
+		case len(item.Warnings) > 0:
+			r.status, r.color = "WARN", colorYellow
+			r.note = strings.Join(item.Warnings, "; ")
+		default:
+			r.status, r.color = "OK", colorGreen
+			r.note = item.Reason
+		}
+		if item.Status == "Processed" {
+			r.sizes = formatSize(item.OriginalSize) + " -> " + formatSize(item.NewSize)
+			if item.OriginalSize > 0 {
+				r.ratio = fmt.Sprintf("%.1f%%", float64(item.NewSize)/float64(item.OriginalSize)*100)
+			}
+		}
+		rows = append(rows, r)
+	}
+	for _, item := range ignored {
+		rows = append(rows, reportRow{status: "SKIP", color: colorYellow, path: displayPath(item.InputFile), note: item.Reason})
+	}
+
+	// 固定宽度的列按内容取最大值
+	statusW, ratioW, sizesW := len("STATUS"), len("RATIO"), len("SIZE")
+	pathW, noteW := len("FILE"), 0
+	for _, r := range rows {
+		ratioW = max(ratioW, len(r.ratio))
+		sizesW = max(sizesW, len(r.sizes))
+		pathW = max(pathW, uniseg.StringWidth(r.path))
+		noteW = max(noteW, uniseg.StringWidth(r.note))
+	}
+
+	if style == config.ReportStyleCompact {
+		// 列之间各有两个空格
+		avail := termWidth(w) - statusW - ratioW - sizesW - 2*4
+		noteW = min(noteW, avail/3)
+		pathW = max(10, min(pathW, avail-noteW))
+		noteW = max(0, min(noteW, avail-pathW))
+	}
+
+	fmt.Fprintf(w, "%-*s  %*s  %*s  %s  %s\n", statusW, "STATUS", ratioW, "RATIO", sizesW, "SIZE", padRight("FILE", pathW), "NOTE")
+	for _, r := range rows {
+		status := fmt.Sprintf("%-*s", statusW, r.status)
+		if useColor {
+			status = r.color + status + colorReset
+		}
+		path, note := r.path, r.note
+		if style == config.ReportStyleCompact {
+			path, note = elideMiddle(path, pathW), elideEnd(note, noteW)
+		}
+		line := fmt.Sprintf("%s  %*s  %*s  %s  %s", status, ratioW, r.ratio, sizesW, r.sizes, padRight(path, pathW), note)
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+	fmt.Fprintln(w, "--------------------------------------------------------------------------------")
+}
+
+// displayPath 尽量以相对当前目录的形式显示路径
+func displayPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+// padRight 按显示宽度 (中文等宽字符计为 2 列) 在右侧补空格
+func padRight(s string, width int) string {
+	if w := uniseg.StringWidth(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// elideEnd 将 s 截断到 width 列，末尾以 … 表示
+func elideEnd(s string, width int) string {
+	if uniseg.StringWidth(s) <= width {
+		return s
+	}
+	if width <= 1 {
+		return ""
+	}
+	var b strings.Builder
+	w := 0
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		if w+g.Width() > width-1 {
+			break
+		}
+		b.WriteString(g.Str())
+		w += g.Width()
+	}
+	return b.String() + "…"
+}
+
+// elideMiddle 将路径截断到 width 列，保留开头与结尾 (文件名通常在结尾)，中间以 … 表示
+func elideMiddle(s string, width int) string {
+	if uniseg.StringWidth(s) <= width {
+		return s
+	}
+	var clusters []string
+	var widths []int
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		clusters = append(clusters, g.Str())
+		widths = append(widths, g.Width())
+	}
+
+	// 结尾分配约三分之二的宽度
+	tailBudget := (width - 1) * 2 / 3
+	headBudget := width - 1 - tailBudget
+	tail, tw := len(clusters), 0
+	for tail > 0 && tw+widths[tail-1] <= tailBudget {
+		tail--
+		tw += widths[tail]
+	}
+	head, hw := 0, 0
+	for head < tail && hw+widths[head] <= headBudget+(tailBudget-tw) {
+		hw += widths[head]
+		head++
+	}
+	return strings.Join(clusters[:head], "") + "…" + strings.Join(clusters[tail:], "")
+}
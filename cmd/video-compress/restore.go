@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"video-compress/internal/report"
+	"video-compress/internal/utils"
+
+	"github.com/spf13/pflag"
+)
+
+// runRestore 实现 vc restore：根据 JSON 报告将移入废纸篓 (或 --move-originals-to 移走) 的源文件放回原处
+// 可额外传入文件或目录，只恢复其中的条目
+func runRestore(args []string) int {
+	fs := pflag.NewFlagSet("restore", pflag.ExitOnError)
+	removeOutputs := fs.Bool("remove-outputs", false, "同时删除对应的压缩输出")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Println("Usage: vc restore <report.json> [file_or_dir...] [--remove-outputs]")
+		fs.PrintDefaults()
+		return 1
+	}
+
+	r, err := report.LoadJSON(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+
+	var filters []string
+	for _, f := range fs.Args()[1:] {
+		if abs, err := filepath.Abs(f); err == nil {
+			filters = append(filters, abs)
+		}
+	}
+	matches := func(path string) bool {
+		if len(filters) == 0 {
+			return true
+		}
+		abs, _ := filepath.Abs(path)
+		for _, f := range filters {
+			if abs == f || strings.HasPrefix(abs, f+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	restored, failed := 0, 0
+	for _, item := range r.Items {
+		if (item.TrashedPath == "" && item.MovedTo == "") || !matches(item.InputFile) {
+			continue
+		}
+		var err error
+		if item.TrashedPath != "" {
+			err = utils.RestoreFromTrash(item.TrashedPath, item.InputFile)
+		} else {
+			err = restoreMoved(item.MovedTo, item.InputFile)
+		}
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", item.InputFile, err)
+			failed++
+			continue
+		}
+		fmt.Printf("✅ 已恢复: %s\n", item.InputFile)
+		restored++
+
+		if *removeOutputs && item.OutputFile != "" {
+			if err := os.Remove(item.OutputFile); err != nil && !os.IsNotExist(err) {
+				fmt.Printf("    ⚠️ 删除输出失败: %v\n", err)
+			} else {
+				fmt.Printf("    🗑  已删除输出: %s\n", item.OutputFile)
+			}
+		}
+	}
+
+	fmt.Printf("统计: 恢复 %d | 失败 %d\n", restored, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// restoreMoved 将 --move-originals-to 移走的源文件放回原处
+func restoreMoved(moved, original string) error {
+	if _, err := os.Lstat(moved); err != nil {
+		return fmt.Errorf("已找不到移走的源文件: %w", err)
+	}
+	if _, err := os.Lstat(original); err == nil {
+		return fmt.Errorf("原位置已存在同名文件: %s", original)
+	}
+	if err := os.MkdirAll(filepath.Dir(original), 0755); err != nil {
+		return err
+	}
+	return utils.MoveFile(moved, original)
+}
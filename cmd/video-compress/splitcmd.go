@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"video-compress/internal/ffmpeg"
+
+	"github.com/spf13/pflag"
+)
+
+// runSplit 实现 vc split：不重新编码，将视频按固定时长切分为 name_001.ext、name_002.ext ...
+// 时长可作为第二个参数或 --duration 给出，支持秒数 (600) 或 Go 时长写法 (15m)
+func runSplit(args []string) int {
+	fs := pflag.NewFlagSet("split", pflag.ExitOnError)
+	durationSpec := fs.String("duration", "", "每段时长，如 600 或 15m")
+	outputDir := fs.String("output", "", "输出目录 (默认与输入相同)")
+	atKeyframes := fs.Bool("split-at-keyframes", false, "只在目标时刻附近的关键帧处切分 (-segment_time_delta 0.05)，各段时长更接近目标")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 2 && *durationSpec == "" {
+		*durationSpec = fs.Arg(1)
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 || *durationSpec == "" {
+		fmt.Println("Usage: vc split <input> <duration> [--output <dir>] [--split-at-keyframes]")
+		fmt.Println("       vc split <input> --duration 600 [--output <dir>]")
+		fs.PrintDefaults()
+		return 1
+	}
+	seconds, err := parseSplitSeconds(*durationSpec)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+
+	input := fs.Arg(0)
+	if _, err := os.Stat(input); err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+	dir := *outputDir
+	if dir == "" {
+		dir = filepath.Dir(input)
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("错误: 无法创建输出目录: %v\n", err)
+		return 1
+	}
+	ext := filepath.Ext(input)
+	pattern := filepath.Join(dir, strings.TrimSuffix(filepath.Base(input), ext)+"_%03d"+ext)
+	if existing := ffmpeg.SegmentOutputs(pattern); len(existing) > 0 {
+		fmt.Printf("错误: 输出目录中已存在分段文件 (如 %s)，请先移走或指定其他 --output\n", existing[0])
+		return 1
+	}
+
+	fmt.Printf("✂️  按每段 %s 切分 %s ...\n", time.Duration(seconds)*time.Second, filepath.Base(input))
+	files, err := ffmpeg.Split(input, pattern, seconds, *atKeyframes)
+	if err != nil {
+		fmt.Printf("❌ 切分失败: %v\n", err)
+		var runErr *ffmpeg.RunError
+		if errors.As(err, &runErr) && runErr.Stderr != "" {
+			fmt.Println(strings.TrimSpace(runErr.Stderr))
+		}
+		return 1
+	}
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			fmt.Printf("  %s (%.1f MB)\n", f, float64(info.Size())/1024/1024)
+		}
+	}
+	fmt.Printf("✅ 已生成 %d 个文件\n", len(files))
+	return 0
+}
+
+// parseSplitSeconds 解析切分时长：纯数字按秒计算，否则按 Go 时长写法 (如 15m、1h30m) 解析
+func parseSplitSeconds(s string) (int, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n <= 0 {
+			return 0, fmt.Errorf("切分时长必须大于 0，当前为 %s", s)
+		}
+		return n, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Second {
+		return 0, fmt.Errorf("无效的切分时长 %q (应为秒数或 15m 这样的时长，至少 1s)", s)
+	}
+	return int(d.Round(time.Second).Seconds()), nil
+}
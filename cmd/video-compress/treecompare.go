@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"video-compress/internal/compressor"
+	"video-compress/internal/config"
+)
+
+// runTreeCompare 实现 --two-dir-compare：核对输出目录是否覆盖源目录中的所有视频
+// 全部输出存在且可读时返回 0
+func runTreeCompare(srcRoot, outRoot string, tolerance config.DurationTolerance) int {
+	fmt.Printf("🔍 核对 %s -> %s ...\n", srcRoot, outRoot)
+	items, err := compressor.CompareTrees(srcRoot, outRoot, tolerance)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+
+	var missing, invalid int
+	var srcTotal, outTotal int64
+	for _, item := range items {
+		switch item.Status {
+		case compressor.AuditMissing:
+			missing++
+			fmt.Printf("❌ 缺少输出: %s\n", item.Source)
+		case compressor.AuditInvalid:
+			invalid++
+			fmt.Printf("⚠️ 输出无效: %s (%s)\n", item.Output, item.Reason)
+		default:
+			srcTotal += item.SourceSize
+			outTotal += item.OutputSize
+			fmt.Printf("✅ %s (体积比 %.1f%%)\n", item.Output, item.Ratio()*100)
+		}
+	}
+
+	fmt.Println("================================================================================")
+	fmt.Printf("统计: 源文件 %d | 正常 %d | 缺少 %d | 无效 %d\n",
+		len(items), len(items)-missing-invalid, missing, invalid)
+	if srcTotal > 0 {
+		fmt.Printf("正常输出总体积: %.1f MB -> %.1f MB (%.1f%%)\n",
+			float64(srcTotal)/1024/1024, float64(outTotal)/1024/1024, float64(outTotal)/float64(srcTotal)*100)
+	}
+	if missing+invalid > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"video-compress/internal/compressor"
+)
+
+// runVerifyOnly 实现 --verify-only：不编码，只逐个校验 roots 下已有的压缩输出能否完整解码
+// 结果按常规报告保存 (完好为 Processed，损坏为 Failed)，全部完好时返回 0
+func runVerifyOnly(roots []string, reportJSON string) int {
+	outputs, err := compressor.FindOutputs(roots)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 1
+	}
+	if len(outputs) == 0 {
+		fmt.Println("未找到压缩输出 (*.compressed.*)")
+		return 0
+	}
+
+	fmt.Printf("🔍 校验 %d 个输出 (完整解码，耗时与解码速度相关) ...\n", len(outputs))
+	var items []compressor.ReportItem
+	var bad int
+	var total int64
+	for i, path := range outputs {
+		item := compressor.VerifyOutput(path)
+		items = append(items, item)
+		total += item.NewSize
+		if item.Status == "Failed" {
+			bad++
+			fmt.Printf("[%d/%d] ❌ %s (%s)\n", i+1, len(outputs), path, item.Reason)
+		} else {
+			fmt.Printf("[%d/%d] ✅ %s\n", i+1, len(outputs), path)
+		}
+	}
+
+	fmt.Println("================================================================================")
+	fmt.Printf("统计: 输出 %d | 完好 %d | 损坏 %d | 总体积 %.1f MB\n",
+		len(items), len(items)-bad, bad, float64(total)/1024/1024)
+	saveReports(reportJSON, "", items, nil)
+	if bad > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package compressor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"video-compress/internal/config"
+	"video-compress/internal/utils"
+)
+
+// 目录对比结果
+const (
+	AuditOK      = "ok"      // 输出存在且可被 ffprobe 正常读取
+	AuditMissing = "missing" // 找不到对应的输出
+	AuditInvalid = "invalid" // 输出存在但无法读取或时长为 0
+)
+
+// AuditItem 是源目录中一个视频与其输出的对比结果
+type AuditItem struct {
+	Source     string
+	Output     string // 未找到时为空
+	Status     string
+	Reason     string
+	SourceSize int64
+	OutputSize int64
+}
+
+// Ratio 返回体积比 (新/原)，无法计算时返回 0
+func (a AuditItem) Ratio() float64 {
+	if a.SourceSize <= 0 || a.OutputSize <= 0 {
+		return 0
+	}
+	return float64(a.OutputSize) / float64(a.SourceSize)
+}
+
+// CompareTrees 事后核对输出目录：源目录中的每个视频是否都有对应且可读的输出
+// 输出按相对路径匹配 (保持目录结构的输出树)，其次在输出根目录下按文件名匹配 (-o 平铺输出)
+// tolerance 非零时还要求输出时长与源文件一致 (在容许偏差内)，否则视为无效 (多半是被截断)
+func CompareTrees(srcRoot, outRoot string, tolerance config.DurationTolerance) ([]AuditItem, error) {
+	var items []AuditItem
+	err := filepath.Walk(srcRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			// 源与输出为同一棵树的子目录时不要把输出当作源
+			if filepath.Clean(path) == filepath.Clean(outRoot) && path != srcRoot {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		name := strings.TrimSuffix(info.Name(), filepath.Ext(path))
+		if !slices.Contains(videoExts, ext) || compressedNameRe.MatchString(name) {
+			return nil
+		}
+
+		item := AuditItem{Source: path, SourceSize: info.Size(), Status: AuditMissing}
+		rel, _ := filepath.Rel(srcRoot, filepath.Dir(path))
+		for _, dir := range []string{filepath.Join(outRoot, rel), outRoot} {
+			if out := findOutput(dir, name); out != "" {
+				item.Output = out
+				break
+			}
+		}
+		if item.Output != "" {
+			item.Status = AuditOK
+			if fi, err := os.Stat(item.Output); err == nil {
+				item.OutputSize = fi.Size()
+			}
+			if dur, err := utils.GetVideoDuration(item.Output); err != nil {
+				item.Status, item.Reason = AuditInvalid, "ffprobe: "+err.Error()
+			} else if dur <= 0 {
+				item.Status, item.Reason = AuditInvalid, "duration is 0 or unknown"
+			} else if tolerance != (config.DurationTolerance{}) {
+				if srcDur, err := utils.GetVideoDuration(path); err == nil && srcDur > 0 && !tolerance.Allows(srcDur, dur) {
+					item.Status, item.Reason = AuditInvalid, fmt.Sprintf("duration mismatch: output %.2fs vs source %.2fs", dur, srcDur)
+				}
+			}
+		}
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+// findOutput 在 dir 中查找 name 对应的压缩输出 (name.compressed.ext 或冲突序号 name.compressed.N.ext)
+func findOutput(dir, name string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, escapeGlob(name)+".compressed*"))
+	for _, m := range matches {
+		base := filepath.Base(m)
+		if compressedNameRe.MatchString(strings.TrimSuffix(base, filepath.Ext(base))) {
+			return m
+		}
+	}
+	return ""
+}
+
+// escapeGlob 转义文件名中的 glob 元字符
+func escapeGlob(s string) string {
+	return strings.NewReplacer("*", `\*`, "?", `\?`, "[", `\[`, `\`, `\\`).Replace(s)
+}
@@ -2,43 +2,127 @@ package compressor
 
 import (
 	"bufio"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
-	"sync"
+	"time"
 	"video-compress/internal/config"
 	"video-compress/internal/ffmpeg"
 	"video-compress/internal/utils"
-
-	"github.com/schollz/progressbar/v3"
 )
 
 // ReportItem 存储单个文件的处理结果
 type ReportItem struct {
-	InputFile    string
-	OutputFile   string
-	Status       string // Processed, Ignored, Failed
-	Reason       string // Ignored 或 Failed 的原因
-	OriginalSize int64
-	NewSize      int64
-	Command      string
+	InputFile    string   `json:"input_file"`
+	OutputFile   string   `json:"output_file,omitempty"`
+	Status       string   `json:"status"`           // Processed, Ignored, Failed
+	Reason       string   `json:"reason,omitempty"` // Ignored 或 Failed 的原因
+	OriginalSize int64    `json:"original_size"`
+	NewSize      int64    `json:"new_size"`
+	Command      string   `json:"command,omitempty"`
+	LinkedTo     string   `json:"linked_to,omitempty"`    // --dedupe: 与该文件内容一致，已替换为硬链接
+	Segments     []string `json:"segments,omitempty"`     // --split-every: 实际生成的分段文件
+	AutoFix      string   `json:"auto_fix,omitempty"`     // 自动重试时追加的修复参数
+	Spherical    string   `json:"spherical,omitempty"`    // 360° 元数据: preserved / lost，非全景视频为空
+	Preset       string   `json:"preset,omitempty"`       // --preset auto 时为该文件实际选用的预设
+	Rendition    string   `json:"rendition,omitempty"`    // --renditions 时的版本名
+	TrashedPath  string   `json:"trashed_path,omitempty"` // --delete-original: 源文件在废纸篓中的位置
+	MovedTo      string   `json:"moved_to,omitempty"`     // --move-originals-to: 源文件被移到的位置
+	Superseded   bool     `json:"superseded,omitempty"`   // 重新压缩已压缩的文件：输出与源文件同名，已就地替换源文件
+	Container    string   `json:"container,omitempty"`    // 旧容器迁移，如 "avi -> mp4"
+	DataStreams  string   `json:"data_streams,omitempty"` // --keep-data-streams: preserved / lost / skipped
+	Checksums    []string `json:"checksums,omitempty"`    // --checksum-output: 写出的 .sha256 文件
+	Audio        string   `json:"audio,omitempty"`        // 音频处理方式，如 "copy"、"aac 64k (1ch)"
+	Diagnosis    string   `json:"diagnosis,omitempty"`    // --diagnose: 首个失败任务的诊断结论
+	Warnings     []string `json:"warnings,omitempty"`     // 成功但需留意的问题 (如输出小得可疑)
+	Fallback     string   `json:"fallback,omitempty"`     // 硬件编码失败后的降级重试，如 "hevc_videotoolbox -> libx265"
+	BurnedSubs   string   `json:"burned_subs,omitempty"`  // --burn-subs: 烧录进画面的字幕文件
+	Quarantined  string   `json:"quarantined,omitempty"`  // --quarantine-dir: 连续失败后源文件被移到的位置
+	Settings     string   `json:"settings,omitempty"`     // 编码参数指纹 (不含路径)，用于 --dry-run --diff 判断设置是否变化
+	ReadRate     int64    `json:"read_rate,omitempty"`    // --io-limit: 编码期间的实际平均读取速率 (字节/秒)
+	Log          string   `json:"log,omitempty"`          // 本次运行目录中该任务的日志
+	Tonemap      string   `json:"tonemap,omitempty"`      // --tonemap: 实际执行的色调映射，如 "smpte2084 -> bt709"
+	VMAF         string   `json:"vmaf,omitempty"`         // --target-vmaf: 样本搜索的结果，如 "target 93: quality 62 (VMAF 93.4, 5 samples)"
+
+	SourceModTime time.Time `json:"source_mtime,omitzero"` // 源文件修改时间，用于判断源文件是否变化
+	QueuedAt      time.Time `json:"queued_at,omitzero"`
+	StartedAt     time.Time `json:"started_at,omitzero"`
+	FinishedAt    time.Time `json:"finished_at,omitzero"`
+
+	// --segment-resume 时实际执行的分段命令 (Command 为整体编码的参数)，写入运行日志
+	SegmentCommands []string `json:"segment_commands,omitempty"`
 }
 
 type Job struct {
 	InputFile   string
 	OutputFile  string
 	DurationSec float64
+	Priority    int    // 数值越大越先被调度
+	Preset      string // 该任务实际使用的预设 (--preset auto 或 --renditions 时按任务决定)
+	Rendition   string // --renditions 时的版本名
+	MaxHeight   int    // 版本要求的最大输出高度，0 表示沿用全局设置
+	Info        ffmpeg.InputInfo
+	ModTime     time.Time // 输入文件的修改时间 (--order newest-first/oldest-first)
+	Size        int64     // 输入文件的大小 (--order largest-first)
+
+	MaxBitrateKbps int64  // 视频码率上限 (--max-bitrate-auto 按输入码率计算)，0 表示不限制
+	Fallback       string // 扫描时即改用软件编码的说明 (如超出 --hw-max-resolution)，为空表示按预设编码
+	Quality        int    // --target-vmaf 为该任务搜索出的 --quality，0 表示沿用全局设置
+
+	EstimatedEncodeTime time.Duration // 预计编码耗时 (单个 worker)
+}
+
+// Config 返回应用了任务级设置 (如自动选择的预设、版本分辨率) 后的配置
+func (j Job) Config(cfg config.Config) config.Config {
+	if j.Preset != "" {
+		cfg.Preset = j.Preset
+	}
+	if j.MaxHeight > 0 {
+		cfg.MaxHeight = j.MaxHeight
+	}
+	if j.MaxBitrateKbps > 0 {
+		cfg.MaxBitrateKbps = j.MaxBitrateKbps
+	}
+	if j.Quality > 0 {
+		cfg.Quality = j.Quality
+	}
+	return cfg
+}
+
+// BuildArgs 构建该任务的 FFmpeg 参数
+func (j Job) BuildArgs(cfg config.Config) []string {
+	return ffmpeg.BuildArgs(j.InputFile, j.OutputFile, j.Config(cfg), j.Info)
+}
+
+// SettingsHash 返回该任务编码参数的指纹
+// 输入输出路径替换为占位符，--temp-dir 或移动目录不会影响结果；进度输出间隔与编码结果无关，不计入
+func (j Job) SettingsHash(cfg config.Config) string {
+	jc := j.Config(cfg)
+	jc.StatsPeriod = 0
+	args := ffmpeg.BuildArgs("<input>", "<output>", jc, j.Info)
+	sum := sha256.Sum256([]byte(strings.Join(args, "\x00")))
+	return hex.EncodeToString(sum[:6])
 }
 
+var (
+	compressedNameRe = regexp.MustCompile(`(?i)\.compressed(\.\d+)?$`)
+
+	// videoExts 是默认扫描的视频扩展名，可用 --extensions 覆盖
+	videoExts = []string{".mp4", ".mkv", ".mov", ".m4v", ".webm", ".avi", ".wmv", ".flv", ".ts", ".m2ts", ".mpg"}
+	// legacyExts 是无法直接承载 HEVC 或其原有音频 (WMA、Vorbis、PCM 等) 的容器，统一迁移为 .mp4 并转码音频
+	legacyExts = []string{".webm", ".avi", ".wmv", ".flv", ".ts", ".m2ts", ".mpg"}
+	audioExts  = []string{".mp3", ".m4a", ".flac", ".wav", ".aac"}
+)
+
 // ScanJobs 扫描文件
 // 返回值: jobs, ignored, totalDuration, error
 func ScanJobs(cfg config.Config) ([]Job, []ReportItem, float64, error) {
-	info, err := os.Stat(cfg.InputPath)
-	if err != nil {
-		return nil, nil, 0, err
-	}
-
 	var jobs []Job
 	var ignored []ReportItem
 	var totalDuration float64
@@ -46,23 +130,83 @@ func ScanJobs(cfg config.Config) ([]Job, []ReportItem, float64, error) {
 	// 用于读取用户输入
 	reader := bufio.NewReader(os.Stdin)
 
-	getOutputPath := func(input string) string {
-		ext := filepath.Ext(input)
-		name := strings.TrimSuffix(filepath.Base(input), ext)
+	scan := newScanProgress()
+	defer scan.finish()
+
+	// 记录已分配的输出路径 (忽略大小写，兼容 APFS 等不区分大小写的文件系统)
+	usedOutputs := make(map[string]int)
+	var collisions []string
+
+	// 未指定 --renditions 时视为只有一个无名版本
+	renditions := cfg.Renditions
+	if len(renditions) == 0 {
+		renditions = []config.Rendition{{}}
+	}
+
+	// resolveOutputPath 计算输出路径；reserve 为 false 时只查看将分配的路径，不占用序号也不创建目录
+	resolveOutputPath := func(input, ext, rendition string, reserve bool) string {
+		name, suffix := outputName(input, rendition, cfg.SplitEvery > 0)
 		targetDir := filepath.Dir(input)
-		if cfg.OutputPath != "" {
-			targetDir = cfg.OutputPath
-			_ = os.MkdirAll(targetDir, 0755)
+		dir := cfg.OutputDirFor(filepath.Ext(input))
+		if dir != "" {
+			targetDir = dir
+		}
+		// --date-dirs: 在路由后的目录下按源文件的拍摄日期再分一层
+		if cfg.DateDirs != "" {
+			targetDir = filepath.Join(targetDir, DateDir(input, cfg.DateDirs))
+		}
+		if reserve && (dir != "" || cfg.DateDirs != "") {
+			if _, err := os.Stat(targetDir); os.IsNotExist(err) && !cfg.DryRun && os.MkdirAll(targetDir, 0755) == nil && cfg.OutputMode != 0 {
+				// MkdirAll 受 umask 影响，显式设置以便其他用户可以进入
+				_ = os.Chmod(targetDir, utils.DirMode(cfg.OutputMode))
+			}
+		}
+		if cfg.SplitEvery > 0 {
+			// 切分模式下整个路径都是 printf 模板，目录中的 % 需转义 (文件名已由 outputName 转义)
+			targetDir, ext = ffmpeg.EscapeTemplate(targetDir), ffmpeg.EscapeTemplate(ext)
+		}
+		output := filepath.Join(targetDir, name+suffix+ext)
+		if cfg.AllowCollision {
+			return output
 		}
-		return filepath.Join(targetDir, fmt.Sprintf("%s.compressed%s", name, ext))
+
+		// 多个输入映射到同一输出时追加序号: video.compressed.mp4, video.compressed.1.mp4 ...
+		key := strings.ToLower(output)
+		resolved := output
+		for n := usedOutputs[key]; usedOutputs[strings.ToLower(resolved)] > 0; n++ {
+			resolved = filepath.Join(targetDir, collisionName(name, suffix, ext, n))
+		}
+		if !reserve {
+			return resolved
+		}
+		usedOutputs[key]++
+		if resolved != output {
+			usedOutputs[strings.ToLower(resolved)]++
+			collisions = append(collisions, fmt.Sprintf("%s -> %s", input, resolved))
+		}
+		return resolved
+	}
+	getOutputPath := func(input, ext, rendition string) string {
+		return resolveOutputPath(input, ext, rendition, true)
 	}
+	// existingOutput 返回输出 (--split-every 时为第一个分段) 是否已存在且非空
+	existingOutput := func(outputFile string) bool {
+		if cfg.SplitEvery > 0 {
+			outputFile = fmt.Sprintf(outputFile, 0)
+		}
+		fi, err := os.Stat(outputFile)
+		return err == nil && fi.Size() > 0
+	}
+
+	addFile := func(path string, explicit bool) error {
+		scan.discover(path)
+		defer scan.done()
 
-	addFile := func(path string) error {
 		ext := filepath.Ext(path)
 		nameWithoutExt := strings.TrimSuffix(filepath.Base(path), ext)
 
-		// 判断文件名是否以 .compressed 结尾 (忽略大小写)
-		if strings.HasSuffix(strings.ToLower(nameWithoutExt), ".compressed") {
+		// 判断文件名是否以 .compressed (或冲突序号 .compressed.N) 结尾 (忽略大小写)
+		if cfg.SkipCompressedBy != config.SkipByCodec && compressedNameRe.MatchString(nameWithoutExt) {
 			ignored = append(ignored, ReportItem{
 				InputFile: path,
 				Status:    "Ignored",
@@ -71,26 +215,242 @@ func ScanJobs(cfg config.Config) ([]Job, []ReportItem, float64, error) {
 			return nil
 		}
 
+		if cfg.MarkSource != "" {
+			if _, ok := utils.GetXattr(path, cfg.MarkSource); ok {
+				ignored = append(ignored, ReportItem{
+					InputFile: path,
+					Status:    "Ignored",
+					Reason:    fmt.Sprintf("Source marked as processed (xattr %s)", cfg.MarkSource),
+				})
+				return nil
+			}
+		}
+
+		// 空文件与不可随机读取的输入 (FIFO、设备等) 无法探测，直接跳过
+		if fi, err := os.Stat(path); err == nil {
+			reason := ""
+			switch {
+			case fi.Mode()&(os.ModeNamedPipe|os.ModeSocket|os.ModeDevice|os.ModeCharDevice) != 0:
+				reason = "non-seekable input (pipe/device)"
+			case fi.Mode().IsRegular() && fi.Size() == 0:
+				reason = "empty file"
+			}
+			if reason != "" {
+				ignored = append(ignored, ReportItem{
+					InputFile: path,
+					Status:    "Ignored",
+					Reason:    reason,
+				})
+				return nil
+			}
+		}
+
+		// --skip-existing: 在任何 ffprobe 探测之前，按视频输出的扩展名检查全部版本的输出
+		// 纯音频输入的输出扩展名 (.opus) 要探测后才能确定，由下方逐个版本再检查一次
+		outExt := ext
+		if slices.Contains(legacyExts, strings.ToLower(ext)) {
+			outExt = ".mp4"
+		}
+		if cfg.SkipExisting && !slices.ContainsFunc(renditions, func(r config.Rendition) bool {
+			return !existingOutput(resolveOutputPath(path, outExt, r.Name, false))
+		}) {
+			for _, r := range renditions {
+				ignored = append(ignored, ReportItem{
+					InputFile:  path,
+					OutputFile: getOutputPath(path, outExt, r.Name),
+					Status:     "Ignored",
+					Reason:     "output already exists",
+					Rendition:  r.Name,
+				})
+			}
+			return nil
+		}
+
+		// DRM 加密或本机 ffmpeg 无法解码的输入：单独计为跳过，不占用失败列表
+		if reason := undecodableReason(path); reason != "" {
+			ignored = append(ignored, ReportItem{
+				InputFile: path,
+				Status:    "Ignored",
+				Reason:    reason,
+			})
+			return nil
+		}
+
+		// 纯音频文件输出为 Opus，需先探测以确定输出路径
+		info := ffmpeg.InputInfo{VideoStream: -1}
+		var maxBitrateKbps int64
+		if slices.Contains(legacyExts, strings.ToLower(ext)) {
+			info.TranscodeAudio = true
+		}
+		if probe, err := utils.ProbeStreams(path); err == nil {
+			// 声道数决定转码音频时的默认码率
+			info.AudioChannels = probe.AudioChannels
+			streams := probe.Video
+			selected := utils.PrimaryVideoStream(streams)
+			if cfg.VideoStream >= 0 {
+				i := slices.IndexFunc(streams, func(s utils.VideoStream) bool { return s.Index == cfg.VideoStream })
+				if i < 0 {
+					ignored = append(ignored, ReportItem{
+						InputFile: path,
+						Status:    "Ignored",
+						Reason:    fmt.Sprintf("Video stream %d not found", cfg.VideoStream),
+					})
+					return nil
+				}
+				selected = &streams[i]
+			}
+			if selected != nil {
+				info.VideoStream = selected.Index
+				info.Width, info.Height = selected.Width, selected.Height
+				info.PixFmt = selected.PixFmt
+				info.SAR = ffmpeg.ParseSAR(selected.SAR)
+				info.BitDepth = ffmpeg.PixFmtBitDepth(selected.PixFmt)
+				info.Spherical = selected.Spherical
+			}
+			// 无视频流的输入：按音频转码，或作为 "no video" 跳过，避免以视频参数编码时莫名失败
+			if info.VideoStream < 0 {
+				if !cfg.IncludeAudioOnly && !cfg.AudioOnlyIfNoVideo {
+					ignored = append(ignored, ReportItem{
+						InputFile: path,
+						Status:    "Ignored",
+						Reason:    "no video stream (use --audio-only-if-no-video to transcode as audio)",
+					})
+					return nil
+				}
+				info.AudioOnly = true
+				outExt = ffmpeg.AudioOnlyOutputExt(cfg)
+			}
+			// 按编码判断：改名后仍能识别已压缩的文件
+			if cfg.SkipCompressedBy == config.SkipByCodec || cfg.SkipCompressedBy == config.SkipByBoth {
+				if reason := alreadyEncoded(path, selected, cfg); reason != "" {
+					ignored = append(ignored, ReportItem{
+						InputFile: path,
+						Status:    "Ignored",
+						Reason:    reason,
+					})
+					return nil
+				}
+			}
+			// --max-bitrate-auto: 输出码率不超过输入码率 × 系数
+			if cfg.MaxBitrateFromInput != 1 && selected != nil {
+				maxBitrateKbps = int64(float64(videoBitrate(path, selected)) / 1000 * cfg.MaxBitrateFromInput)
+			}
+		}
+
 		// [新增功能] 检查输出文件是否存在并提示
-		outputFile := getOutputPath(path)
-		if _, err := os.Stat(outputFile); err == nil {
-			fmt.Printf("\n⚠️  目标文件已存在: %s\n", outputFile)
-			fmt.Print("❓ 是否覆盖? (y/N): ")
-			input, _ := reader.ReadString('\n')
-			input = strings.TrimSpace(strings.ToLower(input))
-
-			if input != "y" && input != "yes" {
+		// --renditions 时每个版本各有一个输出，分别确认
+		type target struct {
+			rendition config.Rendition
+			output    string
+		}
+		var targets []target
+		for _, r := range renditions {
+			outputFile := getOutputPath(path, outExt, r.Name)
+			existing := outputFile
+			if cfg.SplitEvery > 0 {
+				existing = fmt.Sprintf(outputFile, 0)
+			}
+			if cfg.SkipExisting {
+				// 只有部分版本已存在，或纯音频输入的输出已存在：跳过这些版本，不再询问
+				if existingOutput(outputFile) {
+					ignored = append(ignored, ReportItem{
+						InputFile:  path,
+						OutputFile: outputFile,
+						Status:     "Ignored",
+						Reason:     "output already exists",
+						Rendition:  r.Name,
+					})
+					continue
+				}
+			}
+			if _, err := os.Stat(existing); err == nil && !cfg.DryRun {
+				scan.clear()
+				if sameFile(path, existing) {
+					fmt.Printf("\n⚠️  重新压缩后将替换源文件: %s\n", existing)
+				} else {
+					fmt.Printf("\n⚠️  目标文件已存在: %s\n", existing)
+				}
+				fmt.Print("❓ 是否覆盖? (y/N): ")
+				input, _ := reader.ReadString('\n')
+				input = strings.TrimSpace(strings.ToLower(input))
+
+				if input != "y" && input != "yes" {
+					ignored = append(ignored, ReportItem{
+						InputFile:  path,
+						OutputFile: outputFile,
+						Status:     "Ignored",
+						Reason:     "目标文件已存在 (用户选择跳过)",
+						Rendition:  r.Name,
+					})
+					continue
+				}
+			}
+			targets = append(targets, target{rendition: r, output: outputFile})
+		}
+		if len(targets) == 0 {
+			return nil
+		}
+		outputFile := targets[0].output
+
+		// 全景元数据已在 GetVideoStreams 中随视频流一并读取
+		if info.Spherical && cfg.SkipSpherical {
+			ignored = append(ignored, ReportItem{
+				InputFile: path,
+				Status:    "Ignored",
+				Reason:    "Spherical (360°) video skipped (--skip-spherical)",
+			})
+			return nil
+		}
+
+		if cfg.AutoRotate && !info.AudioOnly {
+			info.Rotation, _ = utils.GetRotation(path)
+		}
+
+		if cfg.BurnSubs != "" && !info.AudioOnly {
+			info.BurnSubtitles = burnSubtitleFile(path, cfg)
+		}
+
+		if cfg.KeepSubtitles && !info.AudioOnly {
+			info.SubtitleCodecs, _ = utils.GetSubtitleCodecs(path)
+			if img := info.ImageSubtitles(); len(img) > 0 && cfg.SubtitleFormat == "mov_text" && ffmpeg.IsMP4Family(outputFile) {
+				scan.clear()
+				fmt.Printf("⚠️ 警告: %s 含图像字幕 (%s)，无法转换为 mov_text，将不保留字幕\n",
+					filepath.Base(path), strings.Join(img, ", "))
+			}
+		}
+
+		if cfg.KeepDataStreams && !info.AudioOnly {
+			info.DataStreams, _ = utils.GetDataStreams(path)
+			if len(info.DataStreams) > 0 && !ffmpeg.IsMP4Family(outputFile) {
+				scan.clear()
+				fmt.Printf("⚠️ 警告: %s 含数据流 (%s)，%s 容器无法保留，将跳过数据流\n",
+					filepath.Base(path), strings.Join(info.DataStreams, ", "), filepath.Ext(outputFile))
+			}
+		}
+
+		// 损坏或截断的文件在编码时才失败会白白占用一个 worker，提前完整解码检查
+		if cfg.CheckInput {
+			if err := ffmpeg.CheckInput(path); err != nil {
 				ignored = append(ignored, ReportItem{
 					InputFile: path,
 					Status:    "Ignored",
-					Reason:    "目标文件已存在 (用户选择跳过)",
+					Reason:    fmt.Sprintf("input validation failed: %v", err),
 				})
 				return nil
 			}
 		}
 
+		if cfg.Tonemap && !info.AudioOnly {
+			info.ColorTransfer, info.ColorPrimaries, _ = utils.GetColorInfo(path, info.VideoStream)
+		}
+
+		if len(cfg.MetadataKeys) > 0 {
+			info.MetadataTags, _ = ffmpeg.GetMetadataTags(path, cfg.MetadataKeys)
+		}
+
 		dur, err := utils.GetVideoDuration(path)
 		if err != nil {
+			scan.clear()
 			fmt.Printf("⚠️ 警告: 无法读取文件信息，跳过: %s\n", filepath.Base(path))
 			ignored = append(ignored, ReportItem{
 				InputFile: path,
@@ -99,86 +459,287 @@ func ScanJobs(cfg config.Config) ([]Job, []ReportItem, float64, error) {
 			})
 			return nil
 		}
-		jobs = append(jobs, Job{
-			InputFile:   path,
-			OutputFile:  outputFile,
-			DurationSec: dur,
-		})
-		totalDuration += dur
+
+		// 所有版本共享同一份探测结果，各自计入总时长
+		preset := resolvePreset(path, cfg)
+		var modTime time.Time
+		var size int64
+		if fi, err := os.Stat(path); err == nil {
+			modTime, size = fi.ModTime(), fi.Size()
+		}
+		for _, t := range targets {
+			job := Job{
+				InputFile:   path,
+				OutputFile:  t.output,
+				DurationSec: dur,
+				Priority:    jobPriority(path, explicit, cfg),
+				ModTime:     modTime,
+				Size:        size,
+				Preset:      preset,
+				Rendition:   t.rendition.Name,
+				MaxHeight:   t.rendition.MaxHeight,
+				Info:        info,
+
+				MaxBitrateKbps: maxBitrateKbps,
+			}
+			if t.rendition.Preset != "" {
+				job.Preset = t.rendition.Preset
+			}
+			// 超出硬件编码器的尺寸上限时 videotoolbox 会立即失败，直接改用 libx265
+			if w, h, exceeded := ffmpeg.ExceedsHardwareLimit(job.Config(cfg), info); exceeded {
+				job.Preset = ffmpeg.SoftwareFallback(job.Config(cfg)).Preset
+				job.Fallback = fmt.Sprintf("%s -> %s (%dx%d exceeds --hw-max-resolution %dx%d)",
+					ffmpeg.HardwareEncoder, ffmpeg.SoftwareEncoder, w, h, cfg.HWMaxResolution.Long, cfg.HWMaxResolution.Short)
+			}
+			job.EstimatedEncodeTime = estimateJob(job, cfg)
+			jobs = append(jobs, job)
+			totalDuration += dur
+		}
 		return nil
 	}
 
-	if !info.IsDir() {
-		_ = addFile(cfg.InputPath)
-	} else {
-		err = filepath.Walk(cfg.InputPath, func(path string, info os.FileInfo, err error) error {
+	scanExts := videoExts
+	if len(cfg.Extensions) > 0 {
+		scanExts = cfg.Extensions
+	}
+
+	var quarantineAbs, holdingAbs string
+	if cfg.QuarantineDir != "" {
+		quarantineAbs, _ = filepath.Abs(cfg.QuarantineDir)
+	}
+	if cfg.MoveOriginalsTo != "" {
+		holdingAbs, _ = filepath.Abs(cfg.MoveOriginalsTo)
+	}
+
+	for _, input := range cfg.InputPaths {
+		info, err := os.Stat(input)
+		if err != nil && utils.HasGlobMeta(input) {
+			// 未被 shell 展开的 glob (加了引号或无匹配)：自行展开，匹配的文件视同显式列出，不按扩展名过滤
+			// 同名文件确实存在 (如 "movie [1080p].mp4") 时优先按普通路径处理
+			matches, globErr := utils.ExpandGlob(input)
+			if globErr != nil {
+				return nil, nil, 0, fmt.Errorf("展开 %s 失败: %w", input, globErr)
+			}
+			if len(matches) == 0 {
+				return nil, nil, 0, fmt.Errorf("没有文件匹配 %s", input)
+			}
+			for _, m := range matches {
+				_ = addFile(m, true)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		if !info.IsDir() {
+			_ = addFile(input, true)
+			continue
+		}
+		err = filepath.Walk(input, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
+			// 隐藏文件与目录 (.Trash、macOS 的 ._ 元数据文件、__MACOSX 等) 默认跳过；显式给出的输入目录本身不受影响
+			if !cfg.ScanHidden && path != input && isHidden(info.Name()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() && (quarantineAbs != "" || holdingAbs != "") {
+				// 隔离目录或 --move-originals-to 目录位于输入目录内时跳过，已移走的源文件不再参与扫描
+				if abs, _ := filepath.Abs(path); abs == quarantineAbs || abs == holdingAbs {
+					return filepath.SkipDir
+				}
+			}
 			if !info.IsDir() {
+				// --input-format: 扩展名不可信，处理目录中的所有文件
 				ext := strings.ToLower(filepath.Ext(path))
-				if ext == ".mp4" || ext == ".mkv" || ext == ".mov" {
-					_ = addFile(path)
+				if cfg.InputFormat != "" || slices.Contains(scanExts, ext) || (cfg.IncludeAudioOnly && slices.Contains(audioExts, ext)) {
+					_ = addFile(path, false)
 				}
 			}
 			return nil
 		})
+		if err != nil {
+			return jobs, ignored, totalDuration, err
+		}
+	}
+
+	// 队列对同优先级任务保持入队顺序，因此按修改时间或大小排序即可
+	switch cfg.Order {
+	case config.OrderNewestFirst:
+		slices.SortStableFunc(jobs, func(a, b Job) int { return b.ModTime.Compare(a.ModTime) })
+	case config.OrderOldestFirst:
+		slices.SortStableFunc(jobs, func(a, b Job) int { return a.ModTime.Compare(b.ModTime) })
+	case config.OrderLargestFirst:
+		slices.SortStableFunc(jobs, func(a, b Job) int { return cmp.Compare(b.Size, a.Size) })
+	}
+	if cfg.GroupByDir {
+		GroupByDir(jobs)
+	}
+
+	if len(collisions) > 0 {
+		scan.clear()
+		fmt.Printf("⚠️ 检测到 %d 处输出路径冲突，已自动追加序号 (使用 --allow-collision 关闭):\n", len(collisions))
+		for _, c := range collisions {
+			fmt.Printf("    %s\n", c)
+		}
 	}
-	return jobs, ignored, totalDuration, err
+	return jobs, ignored, totalDuration, nil
 }
 
-// Process 批量处理任务
-func Process(jobs []Job, cfg config.Config, globalBar *progressbar.ProgressBar) []ReportItem {
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, cfg.Workers)
+// outputName 返回输入对应的输出文件名 (不含扩展名) 的两部分：name 为主名 (含版本名与切分序号模板)，suffix 为 .compressed 后缀
+// 有意重新压缩已压缩的文件 (--skip-compressed-by codec) 时沿用其 .compressed[.N] 后缀，不叠加为 .compressed.compressed
+func outputName(input, rendition string, split bool) (name, suffix string) {
+	name = strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	suffix = ".compressed"
+	if m := compressedNameRe.FindString(name); m != "" {
+		name, suffix = strings.TrimSuffix(name, m), m
+	}
+	if rendition != "" {
+		name += "." + rendition
+	}
+	if split {
+		// 切分模式下输出为文件名模板，序号位于 .compressed 之前以保留跳过标记；文件名中原有的 % 需转义
+		name = ffmpeg.EscapeTemplate(name) + "-%03d"
+	}
+	return name, suffix
+}
 
-	results := make([]ReportItem, 0, len(jobs))
-	var mu sync.Mutex
+// collisionName 返回输出冲突时第 n 个备选文件名：序号追加在 outputName 的后缀之后 (video.web.compressed.1.mp4)，
+// 切分模式下保留 name 中的序号模板；沿用的 .compressed.N 后缀替换其中的序号，不叠加为 .compressed.N.M
+func collisionName(name, suffix, ext string, n int) string {
+	if i := strings.LastIndexByte(suffix, '.'); i > 0 {
+		suffix = suffix[:i]
+	}
+	return fmt.Sprintf("%s%s.%d%s", name, suffix, n, ext)
+}
 
-	for _, job := range jobs {
-		wg.Add(1)
-		sem <- struct{}{}
+// isHidden 判断文件或目录名是否为隐藏项 (以 . 开头，或解压 zip 时留下的 __MACOSX)
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "__MACOSX"
+}
 
-		go func(j Job) {
-			defer wg.Done()
-			defer func() { <-sem }()
+// burnSubtitleFile 返回 --burn-subs 要烧录的字幕文件
+// 显式路径对所有输入生效；auto 时依次查找与输入同名的 .ass、.srt，找不到返回空字符串
+func burnSubtitleFile(input string, cfg config.Config) string {
+	if cfg.BurnSubs != config.BurnSubsAuto {
+		return cfg.BurnSubs
+	}
+	base := strings.TrimSuffix(input, filepath.Ext(input))
+	for _, ext := range []string{".ass", ".srt"} {
+		if fi, err := os.Stat(base + ext); err == nil && fi.Mode().IsRegular() {
+			return base + ext
+		}
+	}
+	return ""
+}
 
-			var origSize int64
-			if info, err := os.Stat(j.InputFile); err == nil {
-				origSize = info.Size()
+// screenRecorderRe 匹配常见录屏软件写入的元数据
+var screenRecorderRe = regexp.MustCompile(`(?i)\bobs\b|screenflow|camtasia|screen ?(capture|recording)|屏幕录制`)
+
+// resolvePreset 决定单个文件使用的预设
+// --preset auto 时，元数据 (encoder/comment 等标签) 表明是录屏的文件使用 screen，其余使用 standard
+func resolvePreset(path string, cfg config.Config) string {
+	if cfg.Preset != config.PresetAuto {
+		return cfg.Preset
+	}
+	tags, err := utils.GetFormatTags(path)
+	if err == nil {
+		for _, v := range tags {
+			if screenRecorderRe.MatchString(v) {
+				return config.PresetScreen
 			}
+		}
+	}
+	return config.PresetStandard
+}
 
-			args := ffmpeg.BuildArgs(j.InputFile, j.OutputFile, cfg)
-			cmdStr := fmt.Sprintf("ffmpeg %s", strings.Join(args, " "))
+// compressedBitrate1080p 是 1080p 下视为已压缩的默认码率上限 (bit/s)，其他分辨率按像素数等比换算
+const compressedBitrate1080p = 8_000_000
 
-			err := ffmpeg.Run(args, globalBar)
+// alreadyEncoded 判断视频流是否已是目标编码且码率不高于上限，是则返回跳过原因，否则返回空字符串
+func alreadyEncoded(path string, s *utils.VideoStream, cfg config.Config) string {
+	if s == nil || s.Codec != ffmpeg.TargetCodec(cfg) {
+		return ""
+	}
+	bitrate := videoBitrate(path, s)
+	if bitrate <= 0 {
+		return ""
+	}
+	limit := cfg.CompressedMaxBitrate
+	if limit <= 0 {
+		limit = max(1_000_000, int64(compressedBitrate1080p*float64(s.Width*s.Height)/(1920*1080)))
+	}
+	if bitrate > limit {
+		return ""
+	}
+	return fmt.Sprintf("Codec indicates already compressed (%s at %.1f Mbps <= %.1f Mbps)", s.Codec, float64(bitrate)/1e6, float64(limit)/1e6)
+}
 
-			item := ReportItem{
-				InputFile:    j.InputFile,
-				OutputFile:   j.OutputFile,
-				OriginalSize: origSize,
-				Command:      cmdStr,
-			}
+// videoBitrate 返回视频流的码率 (bit/s)，无法获取时返回 0
+func videoBitrate(path string, s *utils.VideoStream) int64 {
+	if s.BitRate > 0 {
+		return s.BitRate
+	}
+	// 容器未记录流码率 (MKV 常见)：以整体码率估算，含音频因此略偏高
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	dur, err := utils.GetVideoDuration(path)
+	if err != nil || dur <= 0 {
+		return 0
+	}
+	return int64(float64(fi.Size()) * 8 / dur)
+}
 
-			if err != nil {
-				globalBar.Clear()
-				fmt.Printf("\n❌ 失败: %s (%v)\n", filepath.Base(j.InputFile), err)
-				_ = globalBar.RenderBlank()
-				item.Status = "Failed"
-				item.Reason = err.Error()
-			} else {
-				item.Status = "Processed"
-				if info, err := os.Stat(j.OutputFile); err == nil {
-					item.NewSize = info.Size()
-				}
-			}
+// audioOnlySpeedRatio 是纯音频转码的经验速度 (相对实时)
+const audioOnlySpeedRatio = 50
 
-			mu.Lock()
-			results = append(results, item)
-			mu.Unlock()
+// estimateJob 估算单个任务的编码耗时，按实际输出高度查表
+func estimateJob(j Job, cfg config.Config) time.Duration {
+	if j.Info.AudioOnly {
+		return time.Duration(j.DurationSec / audioOnlySpeedRatio * float64(time.Second))
+	}
+	jc := j.Config(cfg)
+	height := j.Info.Height
+	if jc.MaxHeight > 0 && (height == 0 || height > jc.MaxHeight) {
+		height = jc.MaxHeight
+	} else if r, ok := config.LookupResolution(jc.Resolution); ok && jc.MaxHeight == 0 && height > r.Short && j.Info.Width >= height {
+		height = r.Short
+	}
+	return ffmpeg.EstimateEncodingTime(j.DurationSec, jc.Preset, height)
+}
 
-		}(job)
+// EstimateTotal 估算整批任务的墙钟耗时 (按 workers 并发平分)
+func EstimateTotal(jobs []Job, workers int) time.Duration {
+	var total time.Duration
+	for _, j := range jobs {
+		total += j.EstimatedEncodeTime
+	}
+	if workers > 1 {
+		total /= time.Duration(workers)
+	}
+	return total
+}
+
+// jobPriority 计算任务的调度优先级
+// 显式指定的文件 (--priority-first) 高于 --priority 匹配的文件，二者均高于普通文件
+func jobPriority(path string, explicit bool, cfg config.Config) int {
+	priority := 0
+	if explicit && cfg.PriorityFirst {
+		priority += 2
+	}
+	for _, pattern := range cfg.PriorityGlobs {
+		matchBase, _ := filepath.Match(pattern, filepath.Base(path))
+		matchFull, _ := filepath.Match(pattern, path)
+		if matchBase || matchFull {
+			priority++
+			break
+		}
 	}
-	wg.Wait()
-	return results
+	return priority
 }
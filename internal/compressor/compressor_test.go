@@ -0,0 +1,112 @@
+package compressor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"video-compress/internal/config"
+)
+
+func TestOutputName(t *testing.T) {
+	tests := []struct {
+		input, rendition string
+		split            bool
+		want             string
+	}{
+		{"/v/x.mp4", "", false, "x.compressed"},
+		{"/v/x.compressed.mp4", "", false, "x.compressed"},
+		{"/v/x.COMPRESSED.mp4", "", false, "x.COMPRESSED"},
+		{"/v/x.compressed.1.mp4", "", false, "x.compressed.1"},
+		{"/v/x.mp4", "web", false, "x.web.compressed"},
+		{"/v/x.compressed.mp4", "web", false, "x.web.compressed"},
+		{"/v/x.mp4", "", true, "x-%03d.compressed"},
+		{"/v/x.compressed.mp4", "", true, "x-%03d.compressed"},
+		{"/v/100%.mp4", "", false, "100%.compressed"},
+		{"/v/100%.mp4", "web", true, "100%%.web-%03d.compressed"},
+	}
+	for _, tt := range tests {
+		name, suffix := outputName(tt.input, tt.rendition, tt.split)
+		if got := name + suffix; got != tt.want {
+			t.Errorf("outputName(%q, %q, %v) = %q, want %q", tt.input, tt.rendition, tt.split, got, tt.want)
+		}
+	}
+}
+
+// 冲突序号追加在 outputName 的完整后缀之后，切分模式下不破坏序号模板
+func TestCollisionName(t *testing.T) {
+	tests := []struct {
+		input, rendition string
+		split            bool
+		want             string
+	}{
+		{"/v/x.mp4", "", false, "x.compressed.2.mp4"},
+		{"/v/x.mp4", "web", false, "x.web.compressed.2.mp4"},
+		{"/v/x.compressed.1.mp4", "", false, "x.compressed.2.mp4"},
+		{"/v/x.COMPRESSED.mp4", "", false, "x.COMPRESSED.2.mp4"},
+		{"/v/x.mp4", "web", true, "x.web-%03d.compressed.2.mp4"},
+	}
+	for _, tt := range tests {
+		name, suffix := outputName(tt.input, tt.rendition, tt.split)
+		got := collisionName(name, suffix, ".mp4", 2)
+		if got != tt.want {
+			t.Errorf("collisionName for (%q, %q, %v) = %q, want %q", tt.input, tt.rendition, tt.split, got, tt.want)
+		}
+		if !compressedNameRe.MatchString(strings.TrimSuffix(strings.ReplaceAll(got, "-%03d", ""), ".mp4")) {
+			t.Errorf("collision name %q is not recognized as compressed", got)
+		}
+	}
+}
+
+// 反复重新压缩同一个文件 (--skip-compressed-by codec) 时输出名保持不变，不会叠加后缀
+func TestOutputNameRepeatedRuns(t *testing.T) {
+	input := "/v/x.mp4"
+	for run := 1; run <= 3; run++ {
+		name, suffix := outputName(input, "", false)
+		output := "/v/" + name + suffix + ".mp4"
+		if output != "/v/x.compressed.mp4" {
+			t.Fatalf("run %d: output %q, want /v/x.compressed.mp4", run, output)
+		}
+		input = output
+	}
+}
+
+// --skip-existing 在探测之前跳过：输出已存在时不调用 ffprobe/ffmpeg，ScanJobs 也不会占用冲突序号
+func TestScanJobsSkipExistingBeforeProbe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffprobe is a shell script")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	marker := filepath.Join(dir, "probed")
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range []string{"ffprobe", "ffmpeg"} {
+		script := "#!/bin/sh\necho " + tool + " >> '" + marker + "'\nexit 1\n"
+		if err := os.WriteFile(filepath.Join(bin, tool), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+
+	input := filepath.Join(dir, "clip.mov")
+	output := filepath.Join(dir, "clip.compressed.mov")
+	for path, data := range map[string]string{input: "source", output: "done"} {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jobs, ignored, _, err := ScanJobs(config.Config{InputPaths: []string{input}, SkipExisting: true, VideoStream: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 0 || len(ignored) != 1 || ignored[0].Reason != "output already exists" || ignored[0].OutputFile != output {
+		t.Fatalf("jobs = %v, ignored = %+v", jobs, ignored)
+	}
+	if data, err := os.ReadFile(marker); err == nil {
+		t.Errorf("probes ran for an existing output: %s", data)
+	}
+}
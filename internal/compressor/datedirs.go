@@ -0,0 +1,51 @@
+package compressor
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+	"video-compress/internal/utils"
+)
+
+// UndatedDir 是 --date-dirs 下无法确定日期的文件所在的目录
+const UndatedDir = "undated"
+
+// creationTimeTags 是记录拍摄时间的容器标签，按优先级排列
+// Apple 设备的 creationdate 带有拍摄地的时区，creation_time 为 UTC
+var creationTimeTags = []string{"com.apple.quicktime.creationdate", "creation_time"}
+
+// creationTimeLayouts 是上述标签常见的时间格式
+var creationTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05-0700", "2006-01-02 15:04:05"}
+
+// SourceDate 返回源文件的拍摄日期：优先读取容器元数据，其次使用修改时间；都无法获取时 ok 为 false
+func SourceDate(path string) (t time.Time, ok bool) {
+	if tags, err := utils.GetFormatTags(path); err == nil {
+		for _, key := range creationTimeTags {
+			v := tags[key]
+			if v == "" {
+				continue
+			}
+			for _, layout := range creationTimeLayouts {
+				if t, err := time.Parse(layout, v); err == nil && t.Year() > 1970 {
+					if key == "creation_time" {
+						t = t.Local()
+					}
+					return t, true
+				}
+			}
+		}
+	}
+	if fi, err := os.Stat(path); err == nil {
+		return fi.ModTime(), true
+	}
+	return time.Time{}, false
+}
+
+// DateDir 按 Go 时间格式 layout (如 2006/01) 返回源文件所属的日期目录，无法确定日期时返回 UndatedDir
+func DateDir(path, layout string) string {
+	t, ok := SourceDate(path)
+	if !ok {
+		return UndatedDir
+	}
+	return filepath.FromSlash(t.Format(layout))
+}
@@ -0,0 +1,47 @@
+package compressor
+
+import (
+	"fmt"
+	"strings"
+	"video-compress/internal/ffmpeg"
+	"video-compress/internal/utils"
+)
+
+// ReasonDRM 是带有 DRM 加密的输入被跳过时的原因
+const ReasonDRM = "DRM protected"
+
+// undecodableSuffix 是本机 ffmpeg 无法解码的输入被跳过时的原因后缀
+const undecodableSuffix = " not decodable by local ffmpeg"
+
+// undecodableReason 在扫描时检查输入能否被本机 ffmpeg 解码，返回跳过的原因；可以解码时返回空字符串
+// iTunes 购买的影片等 DRM 文件，以及 ffmpeg 不认识的编码，编码到一半才会以难以理解的错误失败
+func undecodableReason(path string) string {
+	streams, err := utils.GetMediaStreams(path)
+	if err != nil {
+		return "" // 探测失败交给后续步骤报告
+	}
+	for _, s := range streams {
+		if s.Encrypted {
+			return ReasonDRM
+		}
+	}
+	for _, s := range streams {
+		name := s.Codec
+		if name == "" {
+			name = s.CodecTag
+			if name == "" || strings.HasPrefix(name, "[") {
+				name = "unknown"
+			}
+			return fmt.Sprintf("%s codec %s%s", s.Type, name, undecodableSuffix)
+		}
+		if ok, err := ffmpeg.CanDecode(name); err == nil && !ok {
+			return fmt.Sprintf("%s codec %s%s", s.Type, name, undecodableSuffix)
+		}
+	}
+	return ""
+}
+
+// IsUndecodable 判断报告项是否因 DRM 或无法解码的编码而被跳过
+func IsUndecodable(item ReportItem) bool {
+	return item.Status == "Ignored" && (item.Reason == ReasonDRM || strings.HasSuffix(item.Reason, undecodableSuffix))
+}
@@ -0,0 +1,61 @@
+package compressor
+
+import (
+	"fmt"
+	"os"
+	"video-compress/internal/utils"
+)
+
+// Dedupe 对已成功输出的文件做去重：内容完全一致的输出只保留第一份，
+// 其余替换为指向第一份的硬链接。返回回收的字节数。
+// 仅在大小相同的文件之间计算哈希，避免无谓的全量读取。
+func Dedupe(items []ReportItem) (int64, error) {
+	bySize := make(map[int64][]int)
+	for i, item := range items {
+		// 切分输出由多个文件组成，不参与去重
+		if item.Status != "Processed" || item.NewSize == 0 || len(item.Segments) > 0 {
+			continue
+		}
+		bySize[item.NewSize] = append(bySize[item.NewSize], i)
+	}
+
+	var reclaimed int64
+	for size, idxs := range bySize {
+		if len(idxs) < 2 {
+			continue
+		}
+
+		first := make(map[string]int) // hash -> items 下标
+		for _, i := range idxs {
+			sum, err := utils.FileSHA256(items[i].OutputFile)
+			if err != nil {
+				return reclaimed, fmt.Errorf("计算哈希失败 %s: %w", items[i].OutputFile, err)
+			}
+			origin, ok := first[sum]
+			if !ok {
+				first[sum] = i
+				continue
+			}
+			if err := replaceWithHardlink(items[origin].OutputFile, items[i].OutputFile); err != nil {
+				return reclaimed, err
+			}
+			items[i].LinkedTo = items[origin].OutputFile
+			reclaimed += size
+		}
+	}
+	return reclaimed, nil
+}
+
+// replaceWithHardlink 先在目标旁创建临时硬链接，再原子替换目标文件
+func replaceWithHardlink(src, dst string) error {
+	tmp := dst + ".vc-link"
+	_ = os.Remove(tmp)
+	if err := os.Link(src, tmp); err != nil {
+		return fmt.Errorf("创建硬链接失败 %s: %w", dst, err)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("替换文件失败 %s: %w", dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package compressor
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"video-compress/internal/config"
+	"video-compress/internal/ffmpeg"
+	"video-compress/internal/utils"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// spacePollInterval 是 --wait-for-space 时检查剩余空间的间隔
+const spacePollInterval = 30 * time.Second
+
+// spaceGuard 在输出磁盘写满时暂停调度
+// worker 领取任务前先经过 gate (读锁)；检测到磁盘已满的 worker 持有写锁等待空间释放，
+// 期间其他 worker 无法领取新任务。未开启 --wait-for-space 时直接终止剩余任务。
+type spaceGuard struct {
+	gate    sync.RWMutex
+	aborted atomic.Bool
+	once    sync.Once
+}
+
+// wait 在调度暂停期间阻塞，返回 false 表示因磁盘已满而终止调度
+func (g *spaceGuard) wait() bool {
+	g.gate.RLock()
+	g.gate.RUnlock()
+	return !g.aborted.Load()
+}
+
+// handleFull 处理一次磁盘已满的失败。返回 true 表示空间已恢复、任务应重新入队
+// partial 为 ffmpeg 实际写入的残缺输出，删除以释放部分空间；与源文件相同的路径永远不会被删除
+func (g *spaceGuard) handleFull(j Job, partial []string, cfg config.Config, bar *progressbar.ProgressBar) bool {
+	for _, f := range partial {
+		if !sameFile(j.InputFile, f) {
+			_ = os.Remove(f)
+		}
+	}
+	dir := ffmpeg.OutputDir(j.OutputFile, cfg.SplitEvery > 0)
+
+	if !cfg.WaitForSpace {
+		g.once.Do(func() {
+			g.aborted.Store(true)
+			bar.Clear()
+			fmt.Printf("\n\n💾 输出磁盘空间不足 (%s 剩余 %s)，停止调度剩余任务\n\n", dir, freeSpaceText(dir))
+			_ = bar.RenderBlank()
+		})
+		return false
+	}
+
+	g.gate.Lock()
+	defer g.gate.Unlock()
+
+	// 以输入大小作为输出所需空间的保守估计
+	var need uint64
+	if info, err := os.Stat(j.InputFile); err == nil {
+		need = uint64(info.Size())
+	}
+	bar.Clear()
+	fmt.Printf("\n\n💾 输出磁盘空间不足 (%s 剩余 %s)，暂停调度，等待至少 %.1f GB 可用空间...\n\n",
+		dir, freeSpaceText(dir), float64(need)/(1<<30))
+	for {
+		free, err := utils.FreeSpace(dir)
+		if err == nil && free >= need {
+			break
+		}
+		time.Sleep(spacePollInterval)
+		if err != nil {
+			// 无法查询剩余空间 (不支持的平台)：等待一个周期后直接重试任务
+			break
+		}
+	}
+	fmt.Printf("✅ 磁盘空间已恢复 (%s 剩余 %s)，继续处理\n", dir, freeSpaceText(dir))
+	_ = bar.RenderBlank()
+	return true
+}
+
+func freeSpaceText(dir string) string {
+	free, err := utils.FreeSpace(dir)
+	if err != nil {
+		return "未知"
+	}
+	return fmt.Sprintf("%.1f MB", float64(free)/(1<<20))
+}
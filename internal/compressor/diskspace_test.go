@@ -0,0 +1,65 @@
+package compressor
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"video-compress/internal/config"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// 就地重新压缩时磁盘写满：只删除隐藏工作目录中的残缺输出，源文件 (即 j.OutputFile) 必须保留
+func TestHandleFullKeepsInPlaceSource(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "x.compressed.mp4")
+	if err := os.WriteFile(source, []byte("source"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	workDir := filepath.Join(dir, ".vc-1-job-1")
+	if err := os.Mkdir(workDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	partial := filepath.Join(workDir, "x.compressed.mp4")
+	if err := os.WriteFile(partial, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var g spaceGuard
+	bar := progressbar.NewOptions64(-1, progressbar.OptionSetWriter(io.Discard))
+	j := Job{InputFile: source, OutputFile: source}
+	// 传入源文件本身也不能被删除
+	if g.handleFull(j, []string{partial, source}, config.Config{}, bar) {
+		t.Fatal("handleFull without --wait-for-space should abort scheduling")
+	}
+	if data, err := os.ReadFile(source); err != nil || string(data) != "source" {
+		t.Fatalf("source changed or removed: %q, %v", data, err)
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Fatalf("partial output not removed: %v", err)
+	}
+	if g.wait() {
+		t.Fatal("scheduling should stop after the disk is full")
+	}
+}
+
+func TestHandleFullRemovesPartialOutput(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "x.mp4")
+	output := filepath.Join(dir, "x.compressed.mp4")
+	for _, f := range []string{source, output} {
+		if err := os.WriteFile(f, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var g spaceGuard
+	bar := progressbar.NewOptions64(-1, progressbar.OptionSetWriter(io.Discard))
+	g.handleFull(Job{InputFile: source, OutputFile: output}, []string{output}, config.Config{}, bar)
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Fatalf("partial output not removed: %v", err)
+	}
+	if _, err := os.Stat(source); err != nil {
+		t.Fatalf("source removed: %v", err)
+	}
+}
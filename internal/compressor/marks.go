@@ -0,0 +1,64 @@
+package compressor
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"video-compress/internal/config"
+	"video-compress/internal/utils"
+)
+
+// MarkSources 在全部版本都编码成功的源文件上写入 cfg.MarkSource 扩展属性 (值为首个输出路径)
+// 属性随文件移动保留，之后的扫描据此跳过；已移入废纸篓的源文件不再标记。返回标记的文件数
+func MarkSources(items []ReportItem, cfg config.Config) int {
+	failed := make(map[string]bool)
+	for _, item := range items {
+		if item.Status != "Processed" {
+			failed[item.InputFile] = true
+		}
+	}
+	marked := make(map[string]bool)
+	for i, item := range items {
+		if item.Status != "Processed" || failed[item.InputFile] || marked[item.InputFile] {
+			continue
+		}
+		if _, err := os.Stat(item.InputFile); err != nil {
+			continue
+		}
+		output, _ := filepath.Abs(item.OutputFile)
+		if err := utils.SetXattr(item.InputFile, cfg.MarkSource, output); err != nil {
+			items[i].Warnings = append(items[i].Warnings, fmt.Sprintf("mark source failed: %v", err))
+			continue
+		}
+		marked[item.InputFile] = true
+	}
+	return len(marked)
+}
+
+// ClearMarks 删除输入路径 (目录则递归) 中所有文件上的 cfg.MarkSource 扩展属性，返回清除的文件数
+func ClearMarks(cfg config.Config) (int, error) {
+	cleared := 0
+	for _, input := range cfg.InputPaths {
+		err := filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			if _, ok := utils.GetXattr(path, cfg.MarkSource); !ok {
+				return nil
+			}
+			if err := utils.RemoveXattr(path, cfg.MarkSource); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			cleared++
+			return nil
+		})
+		if err != nil {
+			return cleared, err
+		}
+	}
+	return cleared, nil
+}
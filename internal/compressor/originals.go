@@ -0,0 +1,35 @@
+package compressor
+
+import (
+	"path/filepath"
+	"strings"
+	"video-compress/internal/utils"
+)
+
+// MoveOriginal 将处理成功的源文件移入 dir (--move-originals-to)，返回新位置
+// 扫描目录中的文件保留相对该输入目录的路径，显式列出的文件直接放在 dir 下；跨文件系统时复制后删除
+func MoveOriginal(src, dir string, inputs []string) (string, error) {
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return "", err
+	}
+	rel := filepath.Base(abs)
+	best := ""
+	for _, input := range inputs {
+		root, err := filepath.Abs(input)
+		if err != nil || len(root) <= len(best) {
+			continue
+		}
+		if r, err := filepath.Rel(root, abs); err == nil && r != "." && !strings.HasPrefix(r, "..") {
+			best, rel = root, r
+		}
+	}
+	dest, err := availablePath(filepath.Join(dir, filepath.Dir(rel)), abs)
+	if err != nil {
+		return "", err
+	}
+	if err := utils.MoveFile(abs, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
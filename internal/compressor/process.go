@@ -0,0 +1,606 @@
+package compressor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"video-compress/internal/config"
+	"video-compress/internal/events"
+	"video-compress/internal/ffmpeg"
+	"video-compress/internal/utils"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// batch 保存一次批处理中各 worker 共享的状态
+type batch struct {
+	cfg     config.Config
+	bar     *progressbar.ProgressBar
+	tracker *progressTracker
+	events  *events.Emitter
+	ioShare int64 // --io-limit 分给每个任务的读取速率 (字节/秒)
+
+	diagnosed atomic.Bool  // --diagnose 只诊断第一个失败的任务
+	seq       atomic.Int64 // 任务临时目录的序号
+	started   atomic.Int64 // 已开始的任务数，用于事件中的队列位置
+}
+
+// IOShare 返回 --io-limit 下每个任务可用的读取速率 (字节/秒)
+// 按同时运行的最大任务数平均分配，保证所有 worker 满载时合计不超过上限；未限制时返回 0
+func IOShare(cfg config.Config, jobs int) int64 {
+	if cfg.IOLimit <= 0 || jobs <= 0 {
+		return 0
+	}
+	return cfg.IOLimit / int64(max(1, min(cfg.Workers, jobs)))
+}
+
+// minOutputBytes 以下的输出视为空输出：连一个 MP4 的 moov 头都放不下
+const minOutputBytes = 1024
+
+// errDurationMismatch 表示输出时长与源文件相差超出 --duration-tolerance (多半是被截断)
+var errDurationMismatch = errors.New("duration mismatch")
+
+// errEmptyOutput 表示 ffmpeg 正常退出却没有写出有效内容 (如滤镜丢弃了所有帧)
+var errEmptyOutput = errors.New("output is empty although ffmpeg exited successfully")
+
+// RunTempDir 返回本次运行在 --temp-dir 下的临时目录，各任务在其中使用独立的子目录
+// 编码中的输出、VMAF 样本与诊断重跑的输出都位于任务子目录，运行结束或中断时整体删除
+func RunTempDir(cfg config.Config) string {
+	return filepath.Join(cfg.TempDir, fmt.Sprintf("vc-%d", os.Getpid()))
+}
+
+// Process 批量处理任务
+// 每个 worker 从优先级队列中领取任务，直到队列为空；ev 为 nil 时不输出 JSON 事件
+func Process(jobs []Job, cfg config.Config, globalBar *progressbar.ProgressBar, ev *events.Emitter) []ReportItem {
+	var wg sync.WaitGroup
+	queue := newJobQueue(jobs)
+	b := &batch{
+		cfg:     cfg,
+		bar:     globalBar,
+		tracker: newProgressTracker(globalBar, jobs),
+		events:  ev,
+		ioShare: IOShare(cfg, len(jobs)),
+	}
+
+	queuedAt := ev.Now()
+	ev.Emit(events.Event{Type: events.RunStarted, Total: len(jobs)})
+	for i, j := range queue.Snapshot() {
+		ev.Emit(events.Event{Type: events.JobQueued, Job: j.InputFile, Position: i + 1})
+	}
+	// 任务开始或重新排队后，为仍在排队的任务发出实时位置
+	// 加锁使各次队列变化的事件按发生顺序整体写出，不会交错出旧的位置
+	var queueMu sync.Mutex
+	changeQueue := func(change func()) {
+		queueMu.Lock()
+		defer queueMu.Unlock()
+		change()
+		if ev == nil {
+			return
+		}
+		for i, j := range queue.Snapshot() {
+			ev.Emit(events.Event{Type: events.QueuePosition, Job: j.InputFile, Position: i + 1})
+		}
+	}
+
+	// --group-by-dir: 记录各目录尚未完成的任务数，目录全部完成时立即提示
+	pendingDirs := make(map[string]int)
+	if cfg.GroupByDir {
+		for _, j := range jobs {
+			pendingDirs[filepath.Dir(j.InputFile)]++
+		}
+	}
+
+	results := make([]ReportItem, 0, len(jobs))
+	var mu sync.Mutex
+	var guard spaceGuard
+	var saved int64 // 已完成任务的累计节省 (--space-budget)
+	budgetMet := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return cfg.SpaceBudget > 0 && saved >= cfg.SpaceBudget
+	}
+
+	for w := 0; w < cfg.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 错开各 worker 的首个任务，避免同时发起大量读取造成机械硬盘寻道抖动
+			time.Sleep(time.Duration(w) * cfg.RampUp)
+			for {
+				if !guard.wait() || budgetMet() {
+					return
+				}
+				var j Job
+				var ok bool
+				changeQueue(func() { j, ok = queue.Pop() })
+				if !ok {
+					return
+				}
+				item, err := b.processJob(j, w)
+				item.QueuedAt = queuedAt
+
+				// 输出磁盘已满：后续任务必然同样失败，暂停或终止调度
+				var partial *partialOutputError
+				if errors.As(err, &partial) && guard.handleFull(j, partial.Paths, cfg, globalBar) {
+					if j.DurationSec > 0 {
+						globalBar.ChangeMax64(globalBar.GetMax64() + int64(j.DurationSec*1000000))
+					}
+					changeQueue(func() { queue.Push(j) })
+					continue
+				}
+
+				mu.Lock()
+				results = append(results, item)
+				saved += SavedBytes(item)
+				if cfg.GroupByDir {
+					dir := filepath.Dir(j.InputFile)
+					if pendingDirs[dir]--; pendingDirs[dir] == 0 {
+						globalBar.Clear()
+						fmt.Printf("\n📁 目录已完成: %s\n", dir)
+						_ = globalBar.RenderBlank()
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	b.tracker.stop()
+
+	// 因磁盘已满或已达到空间预算而未执行的任务；后者不算失败
+	status, reason := "Failed", "not attempted (disk full)"
+	if budgetMet() {
+		status, reason = "Ignored", "not attempted (space budget reached)"
+	}
+	for {
+		j, ok := queue.Pop()
+		if !ok {
+			break
+		}
+		results = append(results, ReportItem{
+			InputFile:  j.InputFile,
+			OutputFile: j.OutputFile,
+			Status:     status,
+			Reason:     reason,
+			Rendition:  j.Rendition,
+			QueuedAt:   queuedAt,
+		})
+	}
+
+	if cfg.TempDir != "" {
+		_ = os.RemoveAll(RunTempDir(cfg))
+	}
+
+	ev.Emit(events.Event{Type: events.RunFinished, Total: len(results), Data: results})
+	return results
+}
+
+// WorkerCores 将 cpus 中的核心按顺序均分给 workers 个 worker，返回第 worker 个分到的核心
+// worker 多于核心时各分一个核心，轮流共用
+func WorkerCores(worker, workers int, cpus []int) []int {
+	n := len(cpus)
+	if workers <= 0 || n == 0 {
+		return nil
+	}
+	if workers >= n {
+		return []int{cpus[worker%n]}
+	}
+	return cpus[worker*n/workers : (worker+1)*n/workers]
+}
+
+// SavedBytes 返回成功任务节省的字节数 (源文件大小减输出大小)，失败或输出更大时为 0
+func SavedBytes(item ReportItem) int64 {
+	if item.Status != "Processed" || item.NewSize <= 0 || item.NewSize >= item.OriginalSize {
+		return 0
+	}
+	return item.OriginalSize - item.NewSize
+}
+
+// processJob 执行单个任务并生成报告项，同时返回 ffmpeg 的错误供调度层判断
+// worker 为执行该任务的 worker 序号 (--pin-cores 据此选择 CPU 核心)
+func (b *batch) processJob(j Job, worker int) (ReportItem, error) {
+	cfg, globalBar := b.cfg, b.bar
+
+	var origSize int64
+	if info, err := os.Stat(j.InputFile); err == nil {
+		origSize = info.Size()
+	}
+
+	// --temp-dir: 先写入任务专属的临时目录 (如本地 SSD)，成功后再移动到最终位置 (如 NAS)
+	// scratch 同时存放该任务的 VMAF 样本与诊断重跑输出，为空时这些功能退回系统临时目录
+	work := j
+	var scratch string
+	if cfg.TempDir != "" {
+		jobDir := filepath.Join(RunTempDir(cfg), fmt.Sprintf("job-%d", b.seq.Add(1)))
+		if err := os.MkdirAll(jobDir, 0755); err == nil {
+			defer os.RemoveAll(jobDir)
+			scratch = jobDir
+			if cfg.SplitEvery > 0 {
+				work.OutputFile = filepath.Join(ffmpeg.EscapeTemplate(jobDir), filepath.Base(j.OutputFile))
+			} else {
+				work.OutputFile = filepath.Join(jobDir, filepath.Base(j.OutputFile))
+			}
+		}
+	}
+	// 重新压缩已带 .compressed 后缀的文件时输出与源文件同名：先写入同目录下的隐藏子目录 (扫描时跳过)，
+	// 通过核对后再替换源文件，ffmpeg 不能边读边覆盖自己的输入
+	inPlace := sameFile(j.InputFile, j.OutputFile)
+	if inPlace && work.OutputFile == j.OutputFile {
+		jobDir := filepath.Join(filepath.Dir(j.OutputFile), fmt.Sprintf(".vc-%d-job-%d", os.Getpid(), b.seq.Add(1)))
+		if err := os.MkdirAll(jobDir, 0755); err != nil {
+			return ReportItem{
+				InputFile:    j.InputFile,
+				OutputFile:   j.OutputFile,
+				OriginalSize: origSize,
+				Status:       "Failed",
+				Reason:       fmt.Sprintf("cannot create work dir for in-place re-encode: %v", err),
+				Rendition:    j.Rendition,
+			}, err
+		}
+		defer os.RemoveAll(jobDir)
+		work.OutputFile = filepath.Join(jobDir, filepath.Base(j.OutputFile))
+	}
+
+	if b.ioShare > 0 && j.DurationSec > 0 && origSize > 0 {
+		// 源文件平均码率 × 倍数 = 分配的读取速率
+		work.Info.ReadRate = float64(b.ioShare) / (float64(origSize) / j.DurationSec)
+	}
+
+	// --target-vmaf: 先在样本上搜索达到目标分数的质量，再按该质量编码完整文件
+	var vmafNote, vmafWarning string
+	if cfg.TargetVMAF > 0 && !j.Info.AudioOnly {
+		if res, err := SearchVMAFQuality(j, cfg, scratch); err != nil {
+			vmafWarning = fmt.Sprintf("⚠️ VMAF search failed, encoded at default quality: %v", err)
+		} else {
+			j.Quality, work.Quality = res.Quality, res.Quality
+			vmafNote = res.String(cfg.TargetVMAF)
+			globalBar.Clear()
+			fmt.Printf("\n🎯 %s: %s\n", filepath.Base(j.InputFile), vmafNote)
+			_ = globalBar.RenderBlank()
+		}
+	}
+
+	args := work.BuildArgs(cfg)
+	cmdStr := fmt.Sprintf("ffmpeg %s", strings.Join(args, " "))
+
+	item := ReportItem{
+		InputFile:    j.InputFile,
+		OutputFile:   j.OutputFile,
+		OriginalSize: origSize,
+	}
+	if cfg.Preset == config.PresetAuto {
+		item.Preset = j.Preset
+	}
+	item.Rendition = j.Rendition
+	item.Fallback = j.Fallback
+	item.VMAF = vmafNote
+	if vmafWarning != "" {
+		item.Warnings = append(item.Warnings, vmafWarning)
+	}
+	item.Settings = j.SettingsHash(cfg)
+	item.BurnedSubs = j.Info.BurnSubtitles
+	if cfg.Tonemap && j.Info.IsHDR() {
+		item.Tonemap = j.Info.ColorTransfer + " -> bt709"
+	}
+	item.SourceModTime = j.ModTime
+	item.Audio = audioDescription(j.Config(cfg), j.Info)
+	if inExt, outExt := filepath.Ext(j.InputFile), filepath.Ext(j.OutputFile); !strings.EqualFold(inExt, outExt) && !j.Info.AudioOnly {
+		item.Container = strings.ToLower(strings.TrimPrefix(inExt, ".") + " -> " + strings.TrimPrefix(outExt, "."))
+	}
+
+	item.StartedAt = b.events.Now()
+	b.events.Emit(events.Event{Type: events.JobStarted, Job: j.InputFile, Position: int(b.started.Add(1))})
+
+	onProgress, done := b.tracker.jobProgress(j)
+	runOpts := ffmpeg.RunOptions{
+		ScannerBufferBytes: cfg.ScannerBufferBytes,
+		MaxOutputBytes:     cfg.MaxOutputBytes,
+		OutputSize:         func() int64 { return outputSize(work, cfg) },
+		OnProgress: func(p ffmpeg.Progress) {
+			onProgress(p)
+			ev := events.Event{Type: events.JobProgress, Job: j.InputFile, OutTimeUs: p.OutTimeUs, Bytes: p.TotalSize}
+			if j.DurationSec > 0 {
+				ev.Percent = min(100, float64(p.OutTimeUs)/(j.DurationSec*10000))
+			}
+			b.events.Emit(ev)
+		},
+	}
+	// --pin-cores: 硬件编码几乎不占 CPU，只绑定软件编码
+	if cfg.PinCores && !j.Info.AudioOnly && !ffmpeg.UsesHardwareEncoder(work.Config(cfg)) {
+		runOpts.CPUs = WorkerCores(worker, cfg.Workers, utils.AllowedCPUs())
+	}
+	var err error
+	if cfg.SegmentSeconds > 0 && !j.Info.AudioOnly && j.DurationSec > cfg.SegmentSeconds {
+		// 分段续传只用于首次尝试，下面的自动重试仍整体编码
+		item.SegmentCommands, err = ProcessWithSegmentResume(work, cfg, runOpts)
+	} else {
+		err = ffmpeg.Run(args, runOpts)
+	}
+
+	// 复用队列溢出 / DTS 非单调等错误：追加修复参数后自动重试一次
+	var muxErr error // 触发自动修复的错误，软件编码重试时据此保留修复参数
+	if fixed, note := ffmpeg.MuxingFix(args, err); note != "" {
+		muxErr = err
+		globalBar.Clear()
+		fmt.Printf("\n🩹 自动修复重试: %s (%s)\n", filepath.Base(j.InputFile), note)
+		_ = globalBar.RenderBlank()
+		args = fixed
+		cmdStr = fmt.Sprintf("ffmpeg %s", strings.Join(args, " "))
+		item.AutoFix = note
+		err = ffmpeg.Run(args, runOpts)
+	}
+
+	// videotoolbox 偶尔拒绝少见的像素格式：改用 libx265 软件编码再重试一次
+	fallback := func(why string) {
+		globalBar.Clear()
+		fmt.Printf("\n🔁 %s，改用 %s 重试: %s\n", why, ffmpeg.SoftwareEncoder, filepath.Base(j.InputFile))
+		_ = globalBar.RenderBlank()
+		args = softwareFallbackArgs(work, cfg, muxErr)
+		cmdStr = fmt.Sprintf("ffmpeg %s", strings.Join(args, " "))
+		item.Fallback = ffmpeg.HardwareEncoder + " -> " + ffmpeg.SoftwareEncoder
+		err = ffmpeg.Run(args, runOpts)
+	}
+	var runErr *ffmpeg.RunError
+	if errors.As(err, &runErr) && !ffmpeg.IsNoSpace(err) && ffmpeg.UsesHardwareEncoder(work.Config(cfg)) {
+		if ffmpeg.IsHardwareLimit(err) {
+			fallback("画面尺寸超出硬件编码器上限")
+			item.Fallback += " (hardware limit)"
+		} else {
+			fallback("硬件编码失败")
+		}
+	}
+
+	// --visual-check: 部分系统上 videotoolbox 对 4:2:2 输入输出整体偏绿的画面，退出码却为 0
+	// 色调映射会整体改变亮度，此时只比较色度；分段输出 (--split-every) 无法按源文件时间抽帧
+	if err == nil && cfg.VisualCheck && !j.Info.AudioOnly && cfg.SplitEvery == 0 {
+		skipLuma := cfg.Tonemap && j.Info.IsHDR()
+		err = ffmpeg.CheckVisual(work.InputFile, work.OutputFile, j.DurationSec, skipLuma)
+		if errors.Is(err, ffmpeg.ErrVisuallyCorrupt) && item.Fallback == "" && ffmpeg.UsesHardwareEncoder(work.Config(cfg)) {
+			fallback("输出画面异常")
+			if err == nil {
+				err = ffmpeg.CheckVisual(work.InputFile, work.OutputFile, j.DurationSec, skipLuma)
+			}
+		}
+		if err != nil && !errors.Is(err, ffmpeg.ErrVisuallyCorrupt) {
+			// 抽帧本身失败不代表输出损坏
+			item.Warnings = append(item.Warnings, fmt.Sprintf("⚠️ Visual check skipped: %v", err))
+			err = nil
+		}
+	}
+	done()
+	item.Command = cmdStr
+
+	// 退出码为 0 不代表成功：空输出按失败处理并保留源文件，避免 --delete-original 丢失内容
+	// 以下核对在移动到最终位置之前进行，就地重新压缩时不合格的输出不会替换源文件
+	if err == nil {
+		if size := outputSize(work, cfg); size < minOutputBytes {
+			removeOutputs(work, cfg)
+			err = fmt.Errorf("%w (%d bytes)", errEmptyOutput, size)
+		}
+	}
+
+	// 核对输出时长：切分输出 (--split-every) 分散在多个文件中，源或输出时长未知时不核对
+	if err == nil && cfg.DurationTolerance != (config.DurationTolerance{}) && cfg.SplitEvery == 0 && j.DurationSec > 0 {
+		if outDur, probeErr := utils.GetVideoDuration(work.OutputFile); probeErr == nil && outDur > 0 && !cfg.DurationTolerance.Allows(j.DurationSec, outDur) {
+			removeOutputs(work, cfg)
+			err = fmt.Errorf("%w: output %.2fs vs source %.2fs", errDurationMismatch, outDur, j.DurationSec)
+		}
+	}
+
+	if err == nil && work.OutputFile != j.OutputFile {
+		if mvErr := moveOutputs(work, j, cfg); mvErr != nil {
+			err = fmt.Errorf("从临时目录移动输出失败: %w", mvErr)
+		}
+	}
+
+	if err != nil {
+		globalBar.Clear()
+		fmt.Printf("\n❌ 失败: %s (%v)\n", filepath.Base(j.InputFile), err)
+		_ = globalBar.RenderBlank()
+		item.Status = "Failed"
+		item.Reason = err.Error()
+		if errors.Is(err, ffmpeg.ErrOutputTooLarge) || errors.Is(err, ffmpeg.ErrVisuallyCorrupt) {
+			// 被终止或画面损坏的输出，删除以免被当作压缩结果
+			removeOutputs(work, cfg)
+		}
+		if cfg.Diagnose && errors.As(err, &runErr) && !ffmpeg.IsNoSpace(err) && b.diagnosed.CompareAndSwap(false, true) {
+			item.Diagnosis = b.diagnose(j, args, err, scratch)
+		}
+	} else {
+		item.Status = "Processed"
+		if cfg.SplitEvery > 0 {
+			item.Segments = ffmpeg.SegmentOutputs(j.OutputFile)
+			for _, seg := range item.Segments {
+				if info, err := os.Stat(seg); err == nil {
+					item.NewSize += info.Size()
+				}
+			}
+		} else if info, err := os.Stat(j.OutputFile); err == nil {
+			item.NewSize = info.Size()
+		}
+		// 输出小得可疑时多半是质量参数配置错误，画质已严重劣化，但不视为失败
+		if cfg.MinOutputRatio > 0 && item.OriginalSize > 0 && float64(item.NewSize) < float64(item.OriginalSize)*cfg.MinOutputRatio {
+			item.Warnings = append(item.Warnings, fmt.Sprintf("⚠️ Output suspiciously small (%.0f%% of original) — check visual quality",
+				float64(item.NewSize)/float64(item.OriginalSize)*100))
+		}
+		if j.Info.Spherical {
+			item.Spherical = "lost"
+			if ok, _ := utils.IsSpherical(j.OutputFile); ok {
+				item.Spherical = "preserved"
+			}
+		}
+		if cfg.KeepDataStreams && len(j.Info.DataStreams) > 0 {
+			item.DataStreams = dataStreamStatus(j, item)
+		}
+		if cfg.OutputMode != 0 {
+			for _, f := range outputsOf(j, cfg) {
+				if err := os.Chmod(f, cfg.OutputMode); err != nil {
+					item.Warnings = append(item.Warnings, fmt.Sprintf("chmod %s failed: %v", filepath.Base(f), err))
+				}
+			}
+		}
+		if cfg.ChecksumOutput {
+			for _, f := range outputsOf(j, cfg) {
+				sidecar, err := utils.WriteSHA256Sidecar(f)
+				if err != nil {
+					item.Reason = fmt.Sprintf("校验文件写入失败: %v", err)
+					break
+				}
+				if cfg.OutputMode != 0 {
+					_ = os.Chmod(sidecar, cfg.OutputMode)
+				}
+				item.Checksums = append(item.Checksums, sidecar)
+			}
+		}
+		// 多版本输出时源文件被多个任务共享，不能在单个任务完成后移走；就地重新压缩时源文件已被输出替换
+		if inPlace {
+			item.Superseded = true
+		} else if cfg.DeleteOriginal && len(cfg.Renditions) == 0 && item.NewSize > 0 {
+			if trashed, err := utils.MoveToTrash(j.InputFile); err != nil {
+				item.Reason = fmt.Sprintf("源文件未移入废纸篓: %v", err)
+			} else {
+				item.TrashedPath = trashed
+			}
+		} else if cfg.MoveOriginalsTo != "" && len(cfg.Renditions) == 0 && item.NewSize > 0 {
+			if moved, err := MoveOriginal(j.InputFile, cfg.MoveOriginalsTo, cfg.InputPaths); err != nil {
+				item.Reason = fmt.Sprintf("源文件未移走: %v", err)
+			} else {
+				item.MovedTo = moved
+			}
+		}
+	}
+	item.FinishedAt = b.events.Now()
+	if elapsed := item.FinishedAt.Sub(item.StartedAt).Seconds(); cfg.IOLimit > 0 && elapsed > 0 {
+		item.ReadRate = int64(float64(origSize) / elapsed)
+	}
+	if cfg.RunDir != "" {
+		if log, logErr := writeJobLog(cfg.RunDir, j, item, err); logErr == nil {
+			item.Log = log
+		}
+	}
+	b.events.Emit(events.Event{Type: events.JobFinished, Job: j.InputFile, Status: item.Status, Reason: item.Reason, Data: item})
+	if ffmpeg.IsNoSpace(err) {
+		// 磁盘写满时由调度层清理残缺输出：只能删除 ffmpeg 实际写入的文件 (临时目录中的输出)，
+		// 就地重新压缩时 j.OutputFile 就是源文件
+		err = &partialOutputError{Paths: outputsOf(work, cfg), err: err}
+	}
+	return item, err
+}
+
+// softwareFallbackArgs 构建改用软件编码重试的参数
+// muxErr 非空表示之前已自动修复过复用错误，重新生成的参数同样追加这些修复参数，否则重试会因同一错误再次失败
+func softwareFallbackArgs(work Job, cfg config.Config, muxErr error) []string {
+	args := ffmpeg.BuildArgs(work.InputFile, work.OutputFile, ffmpeg.SoftwareFallback(work.Config(cfg)), work.Info)
+	if fixed, note := ffmpeg.MuxingFix(args, muxErr); note != "" {
+		args = fixed
+	}
+	return args
+}
+
+// partialOutputError 携带失败任务实际写入的 (残缺) 输出，供 spaceGuard.handleFull 释放空间
+type partialOutputError struct {
+	Paths []string
+	err   error
+}
+
+func (e *partialOutputError) Error() string { return e.err.Error() }
+func (e *partialOutputError) Unwrap() error { return e.err }
+
+// diagnose 重跑失败的任务并打印诊断结论，重跑的输出写入任务的临时目录 scratch，日志保留在工作目录
+func (b *batch) diagnose(j Job, args []string, err error, scratch string) string {
+	b.bar.Clear()
+	fmt.Printf("\n🩺 正在诊断首个失败任务: %s (详细日志 + 软件解码重跑)...\n", filepath.Base(j.InputFile))
+	dir, dirErr := JobWorkDir(b.cfg, j)
+	if dirErr != nil {
+		dir = "" // 退回系统临时目录
+	}
+	d := ffmpeg.Diagnose(args, err, dir, scratch)
+	fmt.Printf("🩺 诊断结论: %s\n", d.Summary())
+	if d.LogFile != "" {
+		fmt.Printf("   详细日志: %s\n", d.LogFile)
+	}
+	_ = b.bar.RenderBlank()
+	return d.Summary()
+}
+
+// audioDescription 描述任务的音频处理方式，供报告展示码率策略的实际结果
+func audioDescription(cfg config.Config, in ffmpeg.InputInfo) string {
+	codec, bitrate := ffmpeg.AudioPlan(cfg, in)
+	if bitrate == "" {
+		return codec
+	}
+	if ch := ffmpeg.TargetAudioChannels(cfg, in); ch > 0 && in.AudioChannels > 0 {
+		return fmt.Sprintf("%s %s (%dch -> %dch)", codec, bitrate, in.AudioChannels, ch)
+	}
+	if in.AudioChannels > 0 {
+		return fmt.Sprintf("%s %s (%dch)", codec, bitrate, in.AudioChannels)
+	}
+	return codec + " " + bitrate
+}
+
+// outputsOf 返回任务当前已写出的输出文件 (切分模式下为全部分段)
+func outputsOf(j Job, cfg config.Config) []string {
+	if cfg.SplitEvery > 0 {
+		return ffmpeg.SegmentOutputs(j.OutputFile)
+	}
+	return []string{j.OutputFile}
+}
+
+// outputSize 返回任务当前的输出总体积
+func outputSize(j Job, cfg config.Config) int64 {
+	var total int64
+	for _, f := range outputsOf(j, cfg) {
+		if info, err := os.Stat(f); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// sameFile 判断两个路径是否指向同一个已存在的文件 (兼容大小写不敏感的文件系统)
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	return err == nil && os.SameFile(fa, fb)
+}
+
+// moveOutputs 将临时目录中的输出移动到最终位置 (切分模式下移动全部分段)
+func moveOutputs(work, final Job, cfg config.Config) error {
+	for _, f := range outputsOf(work, cfg) {
+		dst := filepath.Join(ffmpeg.OutputDir(final.OutputFile, cfg.SplitEvery > 0), filepath.Base(f))
+		if err := utils.MoveFile(f, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeOutputs 删除任务的 (残留) 输出
+func removeOutputs(j Job, cfg config.Config) {
+	for _, f := range outputsOf(j, cfg) {
+		_ = os.Remove(f)
+	}
+}
+
+// dataStreamStatus 确认请求保留的数据流是否真的写入了输出
+func dataStreamStatus(j Job, item ReportItem) string {
+	if !ffmpeg.IsMP4Family(j.OutputFile) {
+		return "skipped"
+	}
+	output := j.OutputFile
+	if len(item.Segments) > 0 {
+		output = item.Segments[0]
+	}
+	if tags, err := utils.GetDataStreams(output); err == nil && len(tags) >= len(j.Info.DataStreams) {
+		return "preserved"
+	}
+	return "lost"
+}
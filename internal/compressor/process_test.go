@@ -0,0 +1,36 @@
+package compressor
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"video-compress/internal/config"
+	"video-compress/internal/ffmpeg"
+)
+
+// 自动修复复用错误后又因硬件编码失败改用软件编码：重新生成的参数必须保留修复参数
+func TestSoftwareFallbackKeepsMuxingFix(t *testing.T) {
+	work := Job{InputFile: "in.mov", OutputFile: "out.mp4", Info: ffmpeg.InputInfo{VideoStream: -1}}
+	cfg := config.Config{Preset: config.PresetStandard}
+	muxErr := &ffmpeg.RunError{
+		Err:    errors.New("exit status 1"),
+		Stderr: "Too many packets buffered for output stream 0:1.\n[mp4 @ 0x1] non monotonically increasing dts to muxer",
+	}
+
+	args := softwareFallbackArgs(work, cfg, muxErr)
+	if !slices.Contains(args, ffmpeg.SoftwareEncoder) {
+		t.Fatalf("fallback args do not use %s: %q", ffmpeg.SoftwareEncoder, args)
+	}
+	if i := slices.Index(args, "-max_muxing_queue_size"); i < 0 || args[i+1] != "4096" || args[len(args)-1] != "out.mp4" {
+		t.Errorf("muxing queue fix missing or misplaced: %q", args)
+	}
+	if i := slices.Index(args, "+genpts"); i < 0 || i > slices.Index(args, "-i") {
+		t.Errorf("+genpts should precede -i: %q", args)
+	}
+
+	// 没有自动修复过时参数与直接构建的一致
+	plain := softwareFallbackArgs(work, cfg, nil)
+	if want := ffmpeg.BuildArgs("in.mov", "out.mp4", ffmpeg.SoftwareFallback(work.Config(cfg)), work.Info); !slices.Equal(plain, want) {
+		t.Errorf("args = %q, want %q", plain, want)
+	}
+}
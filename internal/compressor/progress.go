@@ -0,0 +1,165 @@
+package compressor
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"video-compress/internal/ffmpeg"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+const barDescription = "总体进度"
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// CountUnknownDuration 统计无法获取时长的任务数量
+func CountUnknownDuration(jobs []Job) int {
+	n := 0
+	for _, j := range jobs {
+		if j.DurationSec <= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// BarDescription 返回总体进度条的描述文字
+// 未知时长的任务不计入总百分比，只在描述中单独提示
+func BarDescription(jobs []Job) string {
+	if n := CountUnknownDuration(jobs); n > 0 {
+		return fmt.Sprintf("%s (+%d 个未知时长文件)", barDescription, n)
+	}
+	return barDescription
+}
+
+// progressFlushInterval 是汇总进度刷新到进度条的最短间隔
+// 多个 worker 并发时各自的进度回调只累加增量，由单个 goroutine 统一刷新，避免频繁重绘占用 CPU
+const progressFlushInterval = 250 * time.Millisecond
+
+// progressTracker 将各任务的 ffmpeg 进度汇总到全局进度条
+// 已知时长的任务按 out_time 计入总进度；未知时长的任务以 spinner 形式显示在描述中
+type progressTracker struct {
+	mu      sync.Mutex
+	bar     *progressbar.ProgressBar
+	base    string
+	tick    int
+	unknown map[string]ffmpeg.Progress
+	dirty   bool // unknown 有变化，下次刷新时需要重绘描述
+
+	pending atomic.Int64 // 尚未计入进度条的已完成时长 (微秒)
+	stopCh  chan struct{}
+	stopped chan struct{}
+}
+
+func newProgressTracker(bar *progressbar.ProgressBar, jobs []Job) *progressTracker {
+	t := &progressTracker{
+		bar:     bar,
+		base:    BarDescription(jobs),
+		unknown: make(map[string]ffmpeg.Progress),
+		stopCh:  make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// run 每 progressFlushInterval 将累积的进度刷新到进度条，直到 stop
+func (t *progressTracker) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(progressFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.flush()
+		case <-t.stopCh:
+			t.flush()
+			return
+		}
+	}
+}
+
+// stop 停止刷新 goroutine 并把剩余进度写入进度条，所有任务结束后调用
+func (t *progressTracker) stop() {
+	close(t.stopCh)
+	<-t.stopped
+}
+
+// flush 将累积的时长与未知时长任务的状态写入进度条
+func (t *progressTracker) flush() {
+	if d := t.pending.Swap(0); d > 0 {
+		_ = t.bar.Add64(d)
+	}
+	t.mu.Lock()
+	if t.dirty {
+		t.dirty = false
+		t.tick++
+		t.describeLocked()
+	}
+	t.mu.Unlock()
+}
+
+// jobProgress 返回单个任务的进度回调，以及任务结束时调用的收尾函数
+func (t *progressTracker) jobProgress(j Job) (func(ffmpeg.Progress), func()) {
+	if j.DurationSec > 0 {
+		totalUs := int64(j.DurationSec * 1000000)
+		var reported int64
+		onProgress := func(p ffmpeg.Progress) {
+			cur := min(p.OutTimeUs, totalUs)
+			if cur > reported {
+				t.pending.Add(cur - reported)
+				reported = cur
+			}
+		}
+		// 无论成功失败，结束时补齐该任务剩余的时长，保证进度条最终走到 100%
+		done := func() {
+			if totalUs > reported {
+				t.pending.Add(totalUs - reported)
+				reported = totalUs
+			}
+		}
+		return onProgress, done
+	}
+
+	onProgress := func(p ffmpeg.Progress) {
+		t.mu.Lock()
+		t.unknown[j.InputFile] = p
+		t.dirty = true
+		t.mu.Unlock()
+	}
+	done := func() {
+		t.mu.Lock()
+		delete(t.unknown, j.InputFile)
+		t.dirty = true
+		t.mu.Unlock()
+	}
+	return onProgress, done
+}
+
+func (t *progressTracker) describeLocked() {
+	if len(t.unknown) == 0 {
+		t.bar.Describe(t.base)
+		return
+	}
+
+	names := make([]string, 0, len(t.unknown))
+	for name := range t.unknown {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	spinner := spinnerFrames[t.tick%len(spinnerFrames)]
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		p := t.unknown[name]
+		parts = append(parts, fmt.Sprintf("%s %s %.1fMB@%s",
+			spinner, filepath.Base(name), float64(p.TotalSize)/1024/1024,
+			(time.Duration(p.OutTimeUs)*time.Microsecond).Round(time.Second)))
+	}
+	t.bar.Describe(t.base + " " + strings.Join(parts, " | "))
+}
@@ -0,0 +1,119 @@
+package compressor
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+	"video-compress/internal/ffmpeg"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+const (
+	benchWorkers = 8
+	benchBlocks  = 20000 // 每个任务回放的进度块数
+	benchSeconds = 600   // 每个任务的时长 (秒)
+)
+
+// newBenchBar 与 main 中的总体进度条使用相同的刷新节流，但输出丢弃
+func newBenchBar(total int64) *progressbar.ProgressBar {
+	return progressbar.NewOptions64(total,
+		progressbar.OptionSetWriter(io.Discard),
+		progressbar.OptionSetWidth(20),
+		progressbar.OptionThrottle(100*time.Millisecond),
+		progressbar.OptionShowCount(),
+		progressbar.OptionFullWidth(),
+	)
+}
+
+// replayJobs 代替 ffmpeg.Run：benchWorkers 个任务并发，各自按顺序回放 benchBlocks 个进度块
+func replayJobs(jobs []Job, progress func(Job) (func(ffmpeg.Progress), func())) {
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			onProgress, done := progress(j)
+			totalUs := int64(j.DurationSec * 1000000)
+			for i := 1; i <= benchBlocks; i++ {
+				onProgress(ffmpeg.Progress{OutTimeUs: totalUs * int64(i) / benchBlocks, TotalSize: int64(i) << 10})
+			}
+			done()
+		}()
+	}
+	wg.Wait()
+}
+
+func benchJobs(unknown bool) []Job {
+	jobs := make([]Job, benchWorkers)
+	for i := range jobs {
+		jobs[i] = Job{InputFile: fmt.Sprintf("/videos/clip-%d.mov", i), DurationSec: benchSeconds}
+		if unknown {
+			jobs[i].DurationSec = 0
+		}
+	}
+	return jobs
+}
+
+// directProgress 是批量刷新之前的做法：每个进度块都直接写入进度条，作为基准
+func directProgress(bar *progressbar.ProgressBar) func(Job) (func(ffmpeg.Progress), func()) {
+	return func(j Job) (func(ffmpeg.Progress), func()) {
+		totalUs := int64(j.DurationSec * 1000000)
+		var reported int64
+		onProgress := func(p ffmpeg.Progress) {
+			if cur := min(p.OutTimeUs, totalUs); cur > reported {
+				_ = bar.Add64(cur - reported)
+				reported = cur
+			}
+		}
+		return onProgress, func() { _ = bar.Add64(totalUs - reported) }
+	}
+}
+
+func BenchmarkProgressDirect(b *testing.B) {
+	jobs := benchJobs(false)
+	for b.Loop() {
+		bar := newBenchBar(benchWorkers * benchSeconds * 1000000)
+		replayJobs(jobs, directProgress(bar))
+	}
+}
+
+func BenchmarkProgressBatched(b *testing.B) {
+	jobs := benchJobs(false)
+	for b.Loop() {
+		bar := newBenchBar(benchWorkers * benchSeconds * 1000000)
+		t := newProgressTracker(bar, jobs)
+		replayJobs(jobs, t.jobProgress)
+		t.stop()
+	}
+}
+
+func BenchmarkProgressBatchedUnknownDuration(b *testing.B) {
+	jobs := benchJobs(true)
+	for b.Loop() {
+		bar := newBenchBar(-1)
+		t := newProgressTracker(bar, jobs)
+		replayJobs(jobs, t.jobProgress)
+		t.stop()
+	}
+}
+
+// 批量刷新后进度条最终数值与逐块写入一致
+func TestProgressTrackerTotal(t *testing.T) {
+	jobs := benchJobs(false)
+	total := int64(benchWorkers * benchSeconds * 1000000)
+
+	direct := newBenchBar(total)
+	replayJobs(jobs, directProgress(direct))
+
+	batched := newBenchBar(total)
+	tr := newProgressTracker(batched, jobs)
+	replayJobs(jobs, tr.jobProgress)
+	tr.stop()
+
+	if got, want := batched.State().CurrentNum, direct.State().CurrentNum; got != want || got != total {
+		t.Errorf("batched bar at %d, direct at %d, want %d", got, want, total)
+	}
+}
@@ -0,0 +1,169 @@
+package compressor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"video-compress/internal/config"
+	"video-compress/internal/utils"
+)
+
+// quarantineStateFile 保存在隔离目录中，记录连续失败次数与已隔离的文件
+const quarantineStateFile = ".vc-quarantine.json"
+
+// QuarantineEntry 描述一个被隔离的源文件
+type QuarantineEntry struct {
+	Original string    `json:"original"` // 隔离前的绝对路径
+	Path     string    `json:"path"`     // 隔离目录中的路径
+	Failures int       `json:"failures"`
+	Reason   string    `json:"reason,omitempty"` // 最后一次失败的原因
+	Time     time.Time `json:"time"`
+}
+
+// QuarantineStore 是隔离目录的状态文件
+type QuarantineStore struct {
+	dir      string
+	Failures map[string]int    `json:"failures"` // 源文件绝对路径 -> 连续失败次数
+	Entries  []QuarantineEntry `json:"entries"`
+}
+
+// OpenQuarantine 读取隔离目录的状态，目录或状态文件不存在时返回空状态
+func OpenQuarantine(dir string) (*QuarantineStore, error) {
+	s := &QuarantineStore{dir: dir, Failures: make(map[string]int)}
+	data, err := os.ReadFile(filepath.Join(dir, quarantineStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("解析隔离状态失败: %w", err)
+	}
+	if s.Failures == nil {
+		s.Failures = make(map[string]int)
+	}
+	return s, nil
+}
+
+// Save 写回状态文件
+func (s *QuarantineStore) Save() error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, quarantineStateFile), data, 0644)
+}
+
+// Release 将隔离的文件移回原位置并移出记录
+func (s *QuarantineStore) Release(e QuarantineEntry) error {
+	if _, err := os.Stat(e.Original); err == nil {
+		return fmt.Errorf("原位置已存在同名文件: %s", e.Original)
+	}
+	if err := os.MkdirAll(filepath.Dir(e.Original), 0755); err != nil {
+		return err
+	}
+	if err := utils.MoveFile(e.Path, e.Original); err != nil {
+		return err
+	}
+	for i := range s.Entries {
+		if s.Entries[i].Path == e.Path {
+			s.Entries = append(s.Entries[:i], s.Entries[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Quarantine 根据本次运行结果更新连续失败计数，失败达到 cfg.QuarantineAfter 次的源文件移入隔离目录
+// 被隔离的条目写入 ReportItem.Quarantined；返回本次新隔离的文件数
+func Quarantine(items []ReportItem, cfg config.Config) (int, error) {
+	s, err := OpenQuarantine(cfg.QuarantineDir)
+	if err != nil {
+		return 0, err
+	}
+
+	// 多版本任务中同一源文件对应多条结果，任一版本失败即计为该源文件失败一次
+	failed := make(map[string]int) // 源文件绝对路径 -> 首个失败条目的下标
+	succeeded := make(map[string]bool)
+	for i, item := range items {
+		abs, err := filepath.Abs(item.InputFile)
+		if err != nil {
+			continue
+		}
+		switch {
+		case item.Status == "Failed" && strings.HasPrefix(item.Reason, "not attempted"):
+			// 因磁盘已满未执行，与源文件本身无关
+		case item.Status == "Failed":
+			if _, ok := failed[abs]; !ok {
+				failed[abs] = i
+			}
+		case item.Status == "Processed":
+			succeeded[abs] = true
+		}
+	}
+	for abs := range succeeded {
+		if _, ok := failed[abs]; !ok {
+			delete(s.Failures, abs)
+		}
+	}
+
+	quarantined := 0
+	for abs, i := range failed {
+		s.Failures[abs]++
+		if s.Failures[abs] < cfg.QuarantineAfter {
+			continue
+		}
+		// 多版本共享源文件时 DeleteOriginal 不会移走源文件，这里同样只在源文件仍存在时处理
+		if _, err := os.Stat(abs); err != nil {
+			delete(s.Failures, abs)
+			continue
+		}
+		dest, err := availablePath(cfg.QuarantineDir, abs)
+		if err == nil {
+			err = utils.MoveFile(abs, dest)
+		}
+		if err != nil {
+			items[i].Warnings = append(items[i].Warnings, fmt.Sprintf("quarantine failed: %v", err))
+			continue
+		}
+		s.Entries = append(s.Entries, QuarantineEntry{
+			Original: abs,
+			Path:     dest,
+			Failures: s.Failures[abs],
+			Reason:   items[i].Reason,
+			Time:     time.Now(),
+		})
+		delete(s.Failures, abs)
+		for k := range items {
+			if items[k].InputFile == items[i].InputFile {
+				items[k].Quarantined = dest
+			}
+		}
+		quarantined++
+	}
+	return quarantined, s.Save()
+}
+
+// availablePath 返回源文件移入 dir 后的位置 (隔离目录、--move-originals-to)，同名时追加序号
+func availablePath(dir, src string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(src)
+	name := strings.TrimSuffix(filepath.Base(src), ext)
+	dest := filepath.Join(dir, name+ext)
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(dest); errors.Is(err, os.ErrNotExist) {
+			return dest, nil
+		}
+		dest = filepath.Join(dir, fmt.Sprintf("%s.%d%s", name, n, ext))
+	}
+}
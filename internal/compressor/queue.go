@@ -0,0 +1,104 @@
+package compressor
+
+import (
+	"cmp"
+	"container/heap"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// jobQueue 是按优先级调度的并发安全任务队列
+// 优先级相同时保持入队顺序
+type jobQueue struct {
+	mu    sync.Mutex
+	items jobHeap
+	seq   int
+}
+
+type queuedJob struct {
+	job Job
+	seq int
+}
+
+func newJobQueue(jobs []Job) *jobQueue {
+	q := &jobQueue{}
+	for _, j := range jobs {
+		q.items = append(q.items, queuedJob{job: j, seq: q.seq})
+		q.seq++
+	}
+	heap.Init(&q.items)
+	return q
+}
+
+// Push 将任务重新加入队列
+func (q *jobQueue) Push(j Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	heap.Push(&q.items, queuedJob{job: j, seq: q.seq})
+	q.seq++
+}
+
+// Pop 取出优先级最高的任务，队列为空时返回 false
+func (q *jobQueue) Pop() (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.items.Len() == 0 {
+		return Job{}, false
+	}
+	return heap.Pop(&q.items).(queuedJob).job, true
+}
+
+// Snapshot 按调度顺序返回当前排队的任务，不修改队列
+func (q *jobQueue) Snapshot() []Job {
+	q.mu.Lock()
+	h := slices.Clone(q.items)
+	q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(h))
+	for h.Len() > 0 {
+		jobs = append(jobs, heap.Pop(&h).(queuedJob).job)
+	}
+	return jobs
+}
+
+// GroupByDir 按源文件所在目录重排任务 (--group-by-dir)，使同一目录的文件连续调度
+// 目录按其中最先被调度的文件排序，目录内保持原有顺序 (--order)；
+// 同一目录内的优先级统一为其中的最高值，以免 --priority 匹配的文件把目录拆散
+func GroupByDir(jobs []Job) {
+	slices.SortStableFunc(jobs, func(a, b Job) int { return cmp.Compare(b.Priority, a.Priority) })
+	rank := make(map[string]int)
+	priority := make(map[string]int)
+	for _, j := range jobs {
+		dir := filepath.Dir(j.InputFile)
+		if _, ok := rank[dir]; !ok {
+			rank[dir] = len(rank)
+			priority[dir] = j.Priority
+		}
+	}
+	slices.SortStableFunc(jobs, func(a, b Job) int {
+		return cmp.Compare(rank[filepath.Dir(a.InputFile)], rank[filepath.Dir(b.InputFile)])
+	})
+	for i := range jobs {
+		jobs[i].Priority = priority[filepath.Dir(jobs[i].InputFile)]
+	}
+}
+
+type jobHeap []queuedJob
+
+func (h jobHeap) Len() int { return len(h) }
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].job.Priority != h[j].job.Priority {
+		return h[i].job.Priority > h[j].job.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *jobHeap) Push(x any)   { *h = append(*h, x.(queuedJob)) }
+func (h *jobHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
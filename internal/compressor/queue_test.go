@@ -0,0 +1,70 @@
+package compressor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"video-compress/internal/config"
+	"video-compress/internal/events"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// 每个任务开始后，仍在排队的任务收到新的实时位置 (Position-1 即前面还有多少个任务)
+func TestProcessEmitsLiveQueuePositions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "ffmpeg"), []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	var jobs []Job
+	for i := range 3 {
+		in := filepath.Join(dir, fmt.Sprintf("clip%d.mov", i))
+		if err := os.WriteFile(in, []byte("source"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, Job{InputFile: in, OutputFile: filepath.Join(dir, fmt.Sprintf("clip%d.compressed.mov", i))})
+	}
+
+	var buf bytes.Buffer
+	bar := progressbar.NewOptions64(-1, progressbar.OptionSetWriter(io.Discard))
+	cfg := config.Config{Preset: config.PresetHigh, Workers: 1, TempDir: t.TempDir()}
+	Process(jobs, cfg, bar, events.New(&buf, nil))
+
+	var got []string
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var ev events.Event
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatal(err)
+		}
+		switch ev.Type {
+		case events.QueuePosition:
+			got = append(got, fmt.Sprintf("%s@%d", filepath.Base(ev.Job), ev.Position))
+		case events.JobStarted:
+			got = append(got, "start "+filepath.Base(ev.Job))
+		}
+	}
+	want := []string{
+		"clip1.mov@1", "clip2.mov@2", "start clip0.mov",
+		"clip2.mov@1", "start clip1.mov",
+		"start clip2.mov",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %q\nwant     %q", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package compressor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"video-compress/internal/ffmpeg"
+)
+
+// writeJobLog 在运行目录的 logs/ 下写出单个任务的日志 (命令、结果及失败时 ffmpeg 标准错误的末尾)，返回日志路径
+func writeJobLog(runDir string, j Job, item ReportItem, runErr error) (string, error) {
+	dir := filepath.Join(runDir, "logs")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// 不同目录下的同名文件以路径哈希区分
+	sum := sha256.Sum256([]byte(j.InputFile + "\x00" + j.Rendition))
+	name := strings.TrimSuffix(filepath.Base(j.InputFile), filepath.Ext(j.InputFile))
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.log", name, hex.EncodeToString(sum[:3])))
+
+	var b strings.Builder
+	field := func(k, v string) { fmt.Fprintf(&b, "%-9s %s\n", k+":", v) }
+	field("input", j.InputFile)
+	field("output", j.OutputFile)
+	field("status", item.Status)
+	if item.Reason != "" {
+		field("reason", item.Reason)
+	}
+	field("started", item.StartedAt.Format("2006-01-02 15:04:05"))
+	field("finished", item.FinishedAt.Format("2006-01-02 15:04:05"))
+	field("command", item.Command)
+	if len(item.SegmentCommands) > 0 {
+		// --segment-resume: 实际执行的是以下分段命令 (已完成的分段不重复编码，不在其中)
+		fmt.Fprintf(&b, "\n--- segment commands ---\n%s\n", strings.Join(item.SegmentCommands, "\n"))
+	}
+	var re *ffmpeg.RunError
+	if errors.As(runErr, &re) && re.Stderr != "" {
+		fmt.Fprintf(&b, "\n--- ffmpeg stderr ---\n%s\n", strings.TrimRight(re.Stderr, "\n"))
+	}
+	return path, os.WriteFile(path, []byte(b.String()), 0644)
+}
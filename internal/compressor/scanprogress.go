@@ -0,0 +1,93 @@
+package compressor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// scanSummaryInterval 是非终端输出 (重定向到日志) 时打印扫描摘要的间隔
+const scanSummaryInterval = 30 * time.Second
+
+// scanProgress 在扫描阶段显示已发现/已分析的文件数与当前文件
+// 输出为终端时原地刷新一行；否则每 scanSummaryInterval 打印一行摘要，便于判断是否卡在某个网络文件上
+// 各方法并发安全，可由多个探测 worker 同时调用
+type scanProgress struct {
+	mu         sync.Mutex
+	w          io.Writer
+	tty        bool
+	discovered int
+	probed     int
+	current    string
+	stop       chan struct{}
+}
+
+func newScanProgress() *scanProgress {
+	p := &scanProgress{
+		w:    os.Stderr,
+		tty:  term.IsTerminal(int(os.Stderr.Fd())),
+		stop: make(chan struct{}),
+	}
+	if !p.tty {
+		go p.summaryLoop()
+	}
+	return p
+}
+
+// discover 记录发现一个待分析的文件
+func (p *scanProgress) discover(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.discovered++
+	p.current = path
+	p.render()
+}
+
+// done 记录一个文件分析完毕
+func (p *scanProgress) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.probed++
+	p.render()
+}
+
+// clear 清除原地刷新的进度行，在打印警告或提示前调用
+func (p *scanProgress) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+}
+
+// finish 结束进度显示
+func (p *scanProgress) finish() {
+	close(p.stop)
+	p.clear()
+}
+
+func (p *scanProgress) render() {
+	if p.tty {
+		fmt.Fprintf(p.w, "\r\033[K🔍 已发现 %d | 已分析 %d | %s", p.discovered, p.probed, filepath.Base(p.current))
+	}
+}
+
+func (p *scanProgress) summaryLoop() {
+	ticker := time.NewTicker(scanSummaryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			fmt.Fprintf(p.w, "🔍 扫描中: 已发现 %d | 已分析 %d | 当前: %s\n", p.discovered, p.probed, p.current)
+			p.mu.Unlock()
+		}
+	}
+}
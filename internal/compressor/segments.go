@@ -0,0 +1,130 @@
+package compressor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"video-compress/internal/config"
+	"video-compress/internal/ffmpeg"
+)
+
+// segmentStateFile 记录分段续传的进度，位于任务工作目录的 segments/ 下
+const segmentStateFile = "segments.json"
+
+// segmentState 是分段续传的状态
+type segmentState struct {
+	Settings string          `json:"settings"` // 编码参数指纹，变化后已完成的分段全部作废
+	Length   float64         `json:"length"`   // 分段时长 (秒)
+	Done     map[string]bool `json:"done"`     // 已完成的分段 (键为起始毫秒数)
+}
+
+// ProcessWithSegmentResume 按 cfg.SegmentSeconds 分段编码 j 后无损拼接到 j.OutputFile
+// 已完成的分段记录在任务工作目录中，中断后重跑只编码缺失的分段；cfg.DisableSegResume 时从头编码
+// opts 原样用于每个分段的 ffmpeg.Run (CPU 绑定、stderr 缓冲等)，其中 OnProgress 收到的是相对整个输入的进度，
+// 跳过的分段立即计入；MaxOutputBytes 按已写入的全部分段计算
+// 返回实际执行的分段命令，供运行日志记录
+func ProcessWithSegmentResume(j Job, cfg config.Config, opts ffmpeg.RunOptions) (commands []string, err error) {
+	jobDir, err := JobWorkDir(cfg, j)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(jobDir, "segments")
+
+	// 读取速率随并发数变化，不应使已完成的分段作废
+	key := j
+	key.Info.ReadRate = 0
+	settings := key.SettingsHash(cfg)
+
+	state := loadSegmentState(dir)
+	if cfg.DisableSegResume || state.Settings != settings || state.Length != cfg.SegmentSeconds {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+		state = segmentState{Settings: settings, Length: cfg.SegmentSeconds, Done: make(map[string]bool)}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	args := j.BuildArgs(cfg)
+	ext := filepath.Ext(j.OutputFile)
+	onProgress := opts.OnProgress
+	var files []string
+	for _, seg := range ffmpeg.SplitSegments(j.DurationSec, cfg.SegmentSeconds) {
+		file := filepath.Join(dir, fmt.Sprintf("%05d%s", seg.Index, ext))
+		files = append(files, file)
+		endUs := int64((seg.Start + seg.Length) * 1e6)
+
+		if info, err := os.Stat(file); err == nil && info.Size() > 0 && state.Done[seg.Key()] {
+			if onProgress != nil {
+				onProgress(ffmpeg.Progress{OutTimeUs: endUs})
+			}
+			continue
+		}
+
+		startUs := int64(seg.Start * 1e6)
+		segOpts := opts
+		segOpts.OnProgress = func(p ffmpeg.Progress) {
+			if onProgress != nil {
+				p.OutTimeUs = min(startUs+p.OutTimeUs, endUs)
+				onProgress(p)
+			}
+		}
+		if opts.MaxOutputBytes > 0 {
+			// 最终输出在拼接前并不存在，体积上限按已写入的分段 (含当前分段) 计算
+			written := slices.Clone(files)
+			segOpts.OutputSize = func() int64 { return totalSize(written) }
+		}
+		segArgs := ffmpeg.SegmentArgs(args, seg, file)
+		commands = append(commands, fmt.Sprintf("ffmpeg %s", strings.Join(segArgs, " ")))
+		if err := ffmpeg.Run(segArgs, segOpts); err != nil {
+			_ = os.Remove(file)
+			return commands, fmt.Errorf("分段 %d (%.0fs 起) 编码失败: %w", seg.Index, seg.Start, err)
+		}
+		state.Done[seg.Key()] = true
+		if err := saveSegmentState(dir, state); err != nil {
+			return commands, err
+		}
+	}
+
+	if err := ffmpeg.ConcatSegments(files, j.OutputFile); err != nil {
+		return commands, fmt.Errorf("拼接分段失败: %w", err)
+	}
+	// 拼接成功后分段不再需要
+	return commands, os.RemoveAll(dir)
+}
+
+// totalSize 返回 files 中已存在文件的总大小
+func totalSize(files []string) int64 {
+	var n int64
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			n += fi.Size()
+		}
+	}
+	return n
+}
+
+// loadSegmentState 读取分段状态，不存在或无法解析时返回空状态
+func loadSegmentState(dir string) segmentState {
+	var s segmentState
+	if data, err := os.ReadFile(filepath.Join(dir, segmentStateFile)); err == nil {
+		_ = json.Unmarshal(data, &s)
+	}
+	if s.Done == nil {
+		s.Done = make(map[string]bool)
+	}
+	return s
+}
+
+// saveSegmentState 写回分段状态
+func saveSegmentState(dir string, s segmentState) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, segmentStateFile), data, 0644)
+}
@@ -0,0 +1,70 @@
+package compressor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"video-compress/internal/config"
+	"video-compress/internal/ffmpeg"
+)
+
+// 分段续传：RunOptions 原样传给每个分段的 ffmpeg.Run，返回的是实际执行的分段命令
+func TestSegmentResumeRunOptions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// 从 -ss 10 开始的分段在 FAIL 存在时失败，并刷出大量 stderr
+	fail := filepath.Join(dir, "FAIL")
+	script := `#!/bin/sh
+for a in "$@"; do out="$a"; done
+case "$*" in *"-ss 10.000"*) if [ -e '` + fail + `' ]; then head -c 100000 /dev/zero | tr '\0' e >&2; exit 1; fi;; esac
+printf data > "$out"
+`
+	if err := os.WriteFile(filepath.Join(bin, "ffmpeg"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if err := os.WriteFile(fail, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{Preset: config.PresetHigh, SegmentSeconds: 10, WorkingDir: filepath.Join(dir, "work")}
+	j := Job{InputFile: filepath.Join(dir, "in.mov"), OutputFile: filepath.Join(dir, "out.mov"), DurationSec: 30,
+		Info: ffmpeg.InputInfo{VideoStream: -1}}
+	opts := ffmpeg.RunOptions{StderrTailBytes: 512}
+
+	commands, err := ProcessWithSegmentResume(j, cfg, opts)
+	var runErr *ffmpeg.RunError
+	if !errors.As(err, &runErr) {
+		t.Fatalf("err = %v, want a RunError from the second segment", err)
+	}
+	if len(runErr.Stderr) > 1024 {
+		t.Errorf("stderr tail is %d bytes, StderrTailBytes was not passed to the segment run", len(runErr.Stderr))
+	}
+	if len(commands) != 2 || !strings.Contains(commands[0], "-ss 0.000") || !strings.Contains(commands[1], "-ss 10.000") {
+		t.Errorf("commands = %q", commands)
+	}
+
+	// 重跑只编码缺失的分段
+	if err := os.Remove(fail); err != nil {
+		t.Fatal(err)
+	}
+	commands, err = ProcessWithSegmentResume(j, cfg, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != 2 || !strings.Contains(commands[0], "-ss 10.000") || !strings.Contains(commands[1], "-ss 20.000") {
+		t.Errorf("resumed commands = %q", commands)
+	}
+	if _, err := os.Stat(j.OutputFile); err != nil {
+		t.Errorf("segments not concatenated: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package compressor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"video-compress/internal/ffmpeg"
+	"video-compress/internal/utils"
+)
+
+// FindOutputs 在 roots 下查找本工具生成的压缩输出 (name.compressed.ext 或冲突序号 name.compressed.N.ext)
+// roots 中直接给出的文件无论是否符合命名都会被收录
+func FindOutputs(roots []string) ([]string, error) {
+	var outputs []string
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			outputs = append(outputs, root)
+			continue
+		}
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			name := strings.TrimSuffix(info.Name(), filepath.Ext(path))
+			if slices.Contains(videoExts, ext) && compressedNameRe.MatchString(name) {
+				outputs = append(outputs, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return outputs, nil
+}
+
+// VerifyOutput 校验一个已有的输出：先用 ffprobe 读取时长，再完整解码一遍 (同 --check-input)
+// 完好的文件记为 Processed，损坏或截断的文件记为 Failed 并附上原因
+func VerifyOutput(path string) ReportItem {
+	item := ReportItem{InputFile: path, OutputFile: path, Status: "Processed"}
+	if fi, err := os.Stat(path); err == nil {
+		item.OriginalSize, item.NewSize = fi.Size(), fi.Size()
+	}
+	if dur, err := utils.GetVideoDuration(path); err != nil {
+		item.Status, item.Reason = "Failed", "ffprobe: "+err.Error()
+	} else if dur <= 0 {
+		item.Status, item.Reason = "Failed", "duration is 0 or unknown"
+	} else if err := ffmpeg.CheckInput(path); err != nil {
+		item.Status, item.Reason = "Failed", "decode error: "+err.Error()
+	}
+	return item
+}
@@ -0,0 +1,75 @@
+package compressor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"video-compress/internal/config"
+	"video-compress/internal/ffmpeg"
+)
+
+// VMAFResult 是 --target-vmaf 对一个任务的质量搜索结果
+type VMAFResult struct {
+	Quality int     // 选定的 --quality (1-100)
+	Score   float64 // 样本在该质量下的 VMAF，未测量时为 0
+	Tries   int     // 实际编码并测量的样本次数
+	Reached bool    // 是否有测过的质量达到目标分数
+}
+
+// String 返回报告中的描述，如 "target 93: quality 62 (VMAF 93.4, 5 samples)"
+func (r VMAFResult) String(target float64) string {
+	s := fmt.Sprintf("target %g: quality %d", target, r.Quality)
+	if r.Score > 0 {
+		s += fmt.Sprintf(" (VMAF %.1f, %d samples)", r.Score, r.Tries)
+	}
+	if !r.Reached {
+		s += ", target not reached"
+	}
+	return s
+}
+
+// SearchVMAFQuality 在 j 中间的一段样本上二分查找达到 cfg.TargetVMAF 的最低 --quality
+// 每次尝试都完整编码一遍样本并计算 VMAF，最多尝试 cfg.VMAFMaxIterations 次；
+// 次数用尽仍未收敛时取已知达标的最低质量，全部未达标时取 100
+// 样本写入 scratch (任务在 --temp-dir 下的子目录，为空时使用系统临时目录)
+func SearchVMAFQuality(j Job, cfg config.Config, scratch string) (VMAFResult, error) {
+	jc := j.Config(cfg)
+	jc.SplitEvery = 0
+
+	length := min(cfg.VMAFSampleSeconds, j.DurationSec)
+	if length <= 0 {
+		return VMAFResult{}, fmt.Errorf("时长未知，无法截取样本")
+	}
+	seg := ffmpeg.Segment{Start: (j.DurationSec - length) / 2, Length: length}
+
+	dir, err := os.MkdirTemp(scratch, "vmaf-*")
+	if err != nil {
+		return VMAFResult{}, err
+	}
+	defer os.RemoveAll(dir)
+	sample := filepath.Join(dir, "sample"+filepath.Ext(j.OutputFile))
+
+	// 不变式：低于 lo 的质量均未达标，hi 为已知达标 (或假定达标的 100) 的最低质量
+	lo, hi := 1, 100
+	scores := make(map[int]float64)
+	for len(scores) < cfg.VMAFMaxIterations && lo < hi {
+		mid := (lo + hi) / 2
+		jc.Quality = mid
+		args := ffmpeg.BuildArgs(j.InputFile, sample, jc, j.Info)
+		if err := ffmpeg.Run(ffmpeg.SegmentArgs(args, seg, sample), ffmpeg.RunOptions{}); err != nil {
+			return VMAFResult{}, fmt.Errorf("样本编码失败 (quality %d): %w", mid, err)
+		}
+		score, err := ffmpeg.MeasureVMAF(j.InputFile, sample, seg.Start, seg.Length, j.Info)
+		if err != nil {
+			return VMAFResult{}, fmt.Errorf("VMAF 计算失败: %w", err)
+		}
+		scores[mid] = score
+		if score >= cfg.TargetVMAF {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	score, measured := scores[hi]
+	return VMAFResult{Quality: hi, Score: score, Tries: len(scores), Reached: measured && score >= cfg.TargetVMAF}, nil
+}
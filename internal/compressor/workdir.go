@@ -0,0 +1,34 @@
+package compressor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"video-compress/internal/config"
+)
+
+// WorkDirMarker 标记由 vc 管理的工作目录，vc clean-work 只删除带有该标记的目录
+const WorkDirMarker = ".vc-workdir"
+
+// PrepareWorkDir 创建工作目录并写入标记文件
+func PrepareWorkDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, WorkDirMarker), nil, 0644)
+}
+
+// IsWorkDir 判断目录是否为 vc 创建的工作目录
+func IsWorkDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, WorkDirMarker))
+	return err == nil
+}
+
+// JobWorkDir 返回任务在 WorkingDir 下的专属目录 (按输入路径与版本名哈希)，并确保其存在
+// 同一文件在多次运行之间使用相同的目录，便于找到上次的诊断日志
+func JobWorkDir(cfg config.Config, j Job) (string, error) {
+	sum := sha256.Sum256([]byte(j.InputFile + "\x00" + j.Rendition))
+	dir := filepath.Join(cfg.WorkingDir, hex.EncodeToString(sum[:6]))
+	return dir, os.MkdirAll(dir, 0755)
+}
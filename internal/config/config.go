@@ -1,15 +1,391 @@
 package config
 
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
 const (
 	PresetHigh     = "high"
 	PresetStandard = "standard"
 	PresetLow      = "low"
+	PresetScreen   = "screen"  // 屏幕录制 (锐利文字、静止画面为主)
+	PresetArchive  = "archive" // 冷存储归档：慢速 10-bit AV1 软件编码，体积最小
+	PresetAuto     = "auto"    // 按文件元数据自动选择 screen 或 standard
+)
+
+// ResolutionSource 表示保持原分辨率
+const ResolutionSource = "source"
+
+// Resolution 描述一个分辨率档位，Long×Short 为横屏时的宽×高
+type Resolution struct {
+	Name        string
+	Long, Short int
+}
+
+// Resolutions 列出 --resolution 支持的档位 (从低到高)
+var Resolutions = []Resolution{
+	{"480p", 854, 480},
+	{"720p", 1280, 720},
+	{"1080p", 1920, 1080},
+	{"1440p", 2560, 1440},
+	{"4k", 3840, 2160},
+}
+
+// LookupResolution 按名称 (忽略大小写，2160p 等同 4k) 查找分辨率档位
+func LookupResolution(name string) (Resolution, bool) {
+	name = strings.ToLower(name)
+	if name == "2160p" {
+		name = "4k"
+	}
+	for _, r := range Resolutions {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Resolution{}, false
+}
+
+// DurationTolerance 是输出与源文件时长允许的偏差，两者都设置时取较大者
+// 容器取整、末尾多出或少掉几帧都会造成细微差异，明显更短的输出多半是被截断了
+type DurationTolerance struct {
+	Seconds float64 // 绝对偏差 (秒)
+	Percent float64 // 相对源文件时长的偏差 (百分比)
+}
+
+// DefaultDurationTolerance 是 --duration-tolerance 的默认值
+const DefaultDurationTolerance = "0.5s,1%"
+
+// ParseDurationTolerance 解析 --duration-tolerance：以逗号分隔的 0.5s (或 0.5，秒) 与 1% 形式
+func ParseDurationTolerance(s string) (DurationTolerance, error) {
+	var t DurationTolerance
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if pct, ok := strings.CutSuffix(part, "%"); ok {
+			v, err := strconv.ParseFloat(pct, 64)
+			if err != nil || v < 0 {
+				return t, fmt.Errorf("无效的百分比 %q", part)
+			}
+			t.Percent = v
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSuffix(part, "s"), 64)
+		if err != nil || v < 0 {
+			return t, fmt.Errorf("无效的时长偏差 %q (应为 0.5s 或 1%% 这样的形式)", part)
+		}
+		t.Seconds = v
+	}
+	return t, nil
+}
+
+// Allows 判断输出时长 out 与源文件时长 src 的偏差是否在容许范围内
+func (t DurationTolerance) Allows(src, out float64) bool {
+	limit := max(t.Seconds, src*t.Percent/100)
+	return math.Abs(out-src) <= limit
+}
+
+// DefaultHWMaxResolution 是 --hw-max-resolution 的默认值
+// 多数机型的 hevc_videotoolbox 无法编码 8K 与 GoPro 5.3K 等超出 4096x2304 的画面
+const DefaultHWMaxResolution = "4096x2304"
+
+// ParseDimensions 解析 WxH 形式的尺寸 (如 4096x2304)，返回按长边×短边排列的 Resolution
+func ParseDimensions(s string) (Resolution, error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if !ok || err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return Resolution{}, fmt.Errorf("无效的尺寸 %q (应为 WxH，如 4096x2304)", s)
+	}
+	return Resolution{Name: s, Long: max(width, height), Short: min(width, height)}, nil
+}
+
+// 报告样式
+const (
+	ReportStylePlain   = "plain"   // 逐文件的详细块 (原有格式)
+	ReportStyleWide    = "wide"    // 表格，不截断 (适合重定向到文件)
+	ReportStyleCompact = "compact" // 表格，按终端宽度截断路径与原因
 )
 
+// ReportStyles 列出所有合法的报告样式
+var ReportStyles = []string{ReportStylePlain, ReportStyleWide, ReportStyleCompact}
+
+// 判断 "已压缩" 的依据 (--skip-compressed-by)
+const (
+	SkipByName  = "name"  // 文件名带 .compressed 标记
+	SkipByCodec = "codec" // 视频已是目标编码且码率足够低，不依赖文件名
+	SkipByBoth  = "both"  // 满足任一条件即跳过
+)
+
+// BurnSubsAuto 表示 --burn-subs 未指定路径，按输入文件名查找外挂字幕
+const BurnSubsAuto = "auto"
+
+// 同一优先级内的调度顺序
+const (
+	OrderScan         = "scan"          // 按扫描顺序
+	OrderNewestFirst  = "newest-first"  // 按修改时间，最新的文件先处理
+	OrderOldestFirst  = "oldest-first"  // 按修改时间，最旧的文件先处理
+	OrderLargestFirst = "largest-first" // 按源文件大小，最大的文件先处理
+)
+
+// AudioSampleRates 是 --audio-sample-rate 支持的标准采样率
+var AudioSampleRates = []int{8000, 11025, 16000, 22050, 32000, 44100, 48000}
+
+// Orders 是 --order 支持的取值
+var Orders = []string{OrderScan, OrderNewestFirst, OrderOldestFirst, OrderLargestFirst}
+
 type Config struct {
-	InputPath  string
+	InputPaths []string
+	Extensions []string // 目录扫描时接受的视频扩展名 (小写、带点)，为空表示使用默认列表
 	OutputPath string
+	Routes     []Route // 按输入扩展名分流输出目录，未匹配的扩展名使用 OutputPath
 	Preset     string
 	Quality    int
-	Workers    int
+
+	DateDirs string // 输出目录下按源文件拍摄日期再分的子目录 (Go 时间格式，如 2006/01)，为空表示不分日期
+
+	// InputFormat 强制指定输入容器格式 (ffmpeg -f，如 mpegts)，对本批所有文件生效
+	// 指定后目录扫描不再按扩展名过滤，适合处理一整个扩展名标错的目录
+	InputFormat string
+
+	// MetadataKeys 非空时不再复制全部元数据 (-map_metadata 0)，只保留这些容器级标签 (如 creation_time)
+	MetadataKeys []string
+
+	PresetFile string                      // --preset-file 路径
+	Presets    map[string]PresetDefinition // 自定义预设，同名时覆盖内置预设
+
+	Workers int
+	RampUp  time.Duration // 相邻 worker 启动首个任务的间隔
+
+	FFmpegThreads int  // 每个 ffmpeg 进程的线程数 (-threads / x265 pools)，0 表示由 ffmpeg 决定
+	PinCores      bool // 软件编码时将各 worker 的 ffmpeg 绑定到互不重叠的 CPU 核心 (仅 Linux 生效)
+
+	HWAccelDevice string // 多 GPU 机器上的硬件加速设备序号 (cuda/vaapi)，videotoolbox 忽略
+
+	ScannerBufferBytes int // 解析 ffmpeg 进度输出时单行的最大长度
+
+	StatsPeriod time.Duration // ffmpeg 输出进度的间隔 (-stats_period)，0 表示使用 ffmpeg 默认值
+
+	TempDir string // 中间文件目录 (命令行默认 os.TempDir())；非空时输出先写入其中的任务子目录，成功后再移动到目标位置
+
+	WorkingDir string // 诊断日志等中间文件的根目录，每个任务使用其下的 <任务哈希>/ 子目录
+
+	RunDir string // 本次运行的记录目录 (报告、事件日志、logs/ 下的任务日志)，为空表示不保存
+
+	// 队列优先级
+	PriorityFirst bool     // 命令行中显式指定的文件优先处理
+	PriorityGlobs []string // 匹配这些 glob 的文件优先处理
+	Order         string   // 同一优先级内的顺序: scan, newest-first, oldest-first, largest-first
+	GroupByDir    bool     // 按目录连续调度：一个目录的文件全部领取后才开始下一个目录 (目录内仍并行)
+
+	SpaceBudget int64 // 累计节省达到该字节数后不再调度新任务 (进行中的任务继续完成)，0 表示不限
+
+	Dedupe bool // 批处理结束后将内容相同的输出替换为硬链接
+
+	IncludeAudioOnly   bool   // 同时处理纯音频文件 (播客、音乐)
+	AudioOnlyIfNoVideo bool   // 扫描到无视频流的文件时按音频转码，而不是跳过
+	AudioBitrate       string // 转码音频时的码率 (如 160k)，指定后视频任务的音频也转码为 AAC；为空时按声道数选择
+	AudioCodec         string // 覆盖预设的音频编码器 (如 aac_at、libopus)，为空表示沿用预设
+	CopyAudio          bool   // 强制流复制音频，忽略预设及旧容器的音频转码
+	AudioFilter        string // 自定义音频滤镜链 (-af)，指定后音频需转码
+	AudioChannels      int    // 输出声道数 (-ac)，0 表示保持原声道数；与输入不同时音频需转码
+	AudioChannelsAuto  bool   // 仅在输入声道数多于 AudioChannels 时改变声道数 (只降混，不升混)
+
+	AudioSampleRate     int  // 输出采样率 (-ar)，0 表示保持原采样率；指定后音频需转码
+	AudioSampleRateAuto bool // 视频任务转码音频时统一为 48kHz，纯音频任务保持原采样率
+
+	SplitEvery time.Duration // 按固定时长将输出切分为多个独立文件，0 表示不切分
+
+	// 分段续传：按 SegmentSeconds 分段编码后无损拼接，中断后重跑只编码缺失的分段
+	SegmentSeconds   float64 // 每段时长 (秒)，0 表示整体编码
+	DisableSegResume bool    // 忽略已完成的分段，从头重新编码
+
+	// 字幕
+	KeepSubtitles  bool   // 保留输入中的字幕流
+	SubtitleFormat string // 输出为 MP4/MOV 时字幕转码的目标格式 (如 mov_text)
+	BurnSubs       string // 烧录外挂字幕: BurnSubsAuto 表示查找与输入同名的 .ass/.srt，否则为字幕文件路径；为空表示不烧录
+
+	// 水印
+	Watermark         string  // 水印图片路径，为空表示不叠加
+	WatermarkPosition string  // top-left, top-right, bottom-left, bottom-right
+	WatermarkOpacity  float64 // 不透明度 (0, 1]
+	WatermarkPadding  int     // 距画面边缘的像素
+
+	SkipSpherical bool // 跳过带 360°/全景元数据的文件
+
+	KeepDataStreams bool // 流复制数据轨 (GoPro gpmd 遥测、时间码等)，仅 MP4/MOV 输出支持
+
+	AllowCollision bool // 允许多个输入映射到同一输出路径 (后者覆盖前者)
+	SkipExisting   bool // 输出已存在且非空时直接跳过，不再询问是否覆盖
+	CheckInput     bool // 扫描时完整解码一遍输入，损坏的文件直接跳过
+	ScanHidden     bool // 扫描目录时包含隐藏文件与目录 (默认跳过以 . 开头的项与 __MACOSX)
+
+	// 判断输入是否已压缩过
+	SkipCompressedBy     string // SkipByName、SkipByCodec 或 SkipByBoth
+	CompressedMaxBitrate int64  // SkipByCodec 时视为已压缩的码率上限 (bit/s)，0 表示按分辨率自动选择
+
+	MarkSource string // 编码成功后在源文件上写入的扩展属性名，扫描时跳过带有该属性的文件；为空表示不标记
+
+	VideoStream int    // 指定要编码的视频流绝对序号，-1 表示自动选择主视频流 (排除封面图)
+	MaxHeight   int    // 输出最大高度 (等比缩放，不放大)，0 表示保持原分辨率
+	Resolution  string // 目标分辨率档位 (见 Resolutions)，限制长边与短边，竖屏与变形宽银幕按显示尺寸计算；为空或 source 表示不限制
+	VideoFilter string // 自定义视频滤镜链 (并入 -vf，位于缩放之后)
+	Tonemap     bool   // HDR (PQ/HLG) 输入映射为 SDR BT.709 输出，SDR 输入不受影响
+	AutoRotate  bool   // 按旋转元数据以 transpose 转正画面并清除旋转标记 (部分播放器忽略该标记)
+
+	MaxBitrateFromInput float64 // 视频码率上限 = 输入码率 × 该系数，1 表示不限制
+	MaxBitrateKbps      int64   // 视频码率上限 (-maxrate，kbit/s)，由任务按 MaxBitrateFromInput 计算，0 表示不限制
+
+	// --target-vmaf: 每个文件先在样本上二分查找达到该 VMAF 的最低 --quality，0 表示不启用
+	TargetVMAF        float64
+	VMAFSampleSeconds float64 // 样本时长 (秒)，取自文件中段
+	VMAFMaxIterations int     // 每个文件最多编码并测量的样本次数
+
+	// 硬件编码器支持的最大输出尺寸 (长边×短边，竖屏按转置比较)，超出的任务直接改用 libx265；零值表示不检查
+	HWMaxResolution Resolution
+
+	// 位深
+	ColorDepthPassthrough bool // 输出位深跟随源文件 (8-bit 源编码为 8-bit)，而不是统一使用预设默认值
+	BitDepth              int  // 显式指定输出位深 (8 或 10)，0 表示不指定，优先于 ColorDepthPassthrough
+
+	Renditions []Rendition // 每个输入生成多个版本
+
+	WaitForSpace bool // 输出磁盘写满时暂停等待空间释放，而不是终止剩余任务
+
+	IOLimit int64 // 所有并发任务合计的输入读取速率上限 (字节/秒)，0 表示不限制
+
+	MaxOutputBytes int64   // 单个输出超过该体积时终止编码并删除残留文件，0 表示不限制
+	MinOutputRatio float64 // 输出小于原文件该比例时在报告中提醒检查画质，0 表示不检查
+
+	DurationTolerance DurationTolerance // 编码后核对输出时长，偏差超出时按失败处理 (零值表示不核对)
+
+	DeleteOriginal  bool   // 压缩成功后将源文件移入废纸篓 (可用 vc restore 恢复)
+	MoveOriginalsTo string // 压缩成功后将源文件移入该目录 (保留相对输入目录的结构)，为空表示不移动
+
+	QuarantineDir   string // 连续失败的源文件移入该目录，不再参与之后的扫描；为空表示不隔离
+	QuarantineAfter int    // 连续失败多少次后隔离
+
+	ChecksumOutput bool // 压缩成功后在输出旁写入 .sha256 校验文件
+
+	OutputMode os.FileMode // 压缩成功后对输出文件执行 chmod (新建的输出目录使用对应的目录权限)，0 表示不修改
+
+	Diagnose bool // 首个任务失败时以详细日志与软件解码重跑并打印诊断
+
+	VisualCheck bool // 编码后抽帧对比源文件的亮度/色度，画面严重偏色时按失败处理 (硬件编码时改用软件编码重试)
+
+	DryRun bool // 只扫描并打印计划，不编码、不创建输出目录、不询问是否覆盖
+
+	// 报告
+	ReportThreshold float64 // 只显示体积比 (新/原) 高于该值的文件，0 表示全部显示
+	ReportShowAll   bool    // 忽略 ReportThreshold
+	ReportStyle     string  // 报告样式，见 ReportStyles；为空时终端使用 compact，否则使用 plain
+	ReportFormat    string  // 最终报告的格式 (text/json/csv/markdown/html)，为空按 text 处理
+	ReportFile      string  // 最终报告写入该文件而不是标准输出
+}
+
+// Rendition 描述同一输入的一个输出版本，如 "web:standard:720"
+type Rendition struct {
+	Name      string
+	Preset    string
+	MaxHeight int // 0 表示保持原分辨率
+}
+
+// ParseRenditions 解析 --renditions 参数，格式为 "name:preset[:height],..."
+// custom 为 --preset-file 中定义的预设，可在版本中引用
+func ParseRenditions(s string, custom map[string]PresetDefinition) ([]Rendition, error) {
+	var result []Rendition
+	seen := make(map[string]bool)
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		parts := strings.Split(spec, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("无效的版本定义 %q，格式应为 name:preset[:height]", spec)
+		}
+		r := Rendition{Name: parts[0], Preset: strings.ToLower(parts[1])}
+		if _, ok := custom[r.Preset]; !ok && !slices.Contains(BuiltinPresets, r.Preset) {
+			return nil, fmt.Errorf("版本 %q 使用了未知预设 %q", r.Name, parts[1])
+		}
+		if len(parts) == 3 && parts[2] != "" && parts[2] != "source" {
+			h, err := strconv.Atoi(strings.TrimSuffix(parts[2], "p"))
+			if err != nil || h <= 0 {
+				return nil, fmt.Errorf("版本 %q 的高度 %q 无效", r.Name, parts[2])
+			}
+			r.MaxHeight = h
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("版本名 %q 重复", r.Name)
+		}
+		seen[r.Name] = true
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// ParseExtensions 解析 --extensions 参数 ("mp4,.MKV,mov")，统一为小写并补全前导点
+func ParseExtensions(list []string) []string {
+	var exts []string
+	for _, e := range list {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		if !slices.Contains(exts, e) {
+			exts = append(exts, e)
+		}
+	}
+	return exts
+}
+
+// Route 将指定扩展名的输入输出到独立目录，如 "mov,mp4=/out/camera"
+type Route struct {
+	Exts []string // 小写、带点
+	Dir  string
+}
+
+// ParseRoutes 解析 --route 参数 ("ext,ext=dir")
+// 同一扩展名出现在多条路由中 (无论目录是否相同) 均视为冲突并报错
+func ParseRoutes(specs []string) ([]Route, error) {
+	var result []Route
+	owner := make(map[string]string)
+	for _, spec := range specs {
+		exts, dir, ok := strings.Cut(spec, "=")
+		dir = strings.TrimSpace(dir)
+		if !ok || dir == "" {
+			return nil, fmt.Errorf("无效的路由 %q，格式应为 ext[,ext...]=dir", spec)
+		}
+		r := Route{Exts: ParseExtensions(strings.Split(exts, ",")), Dir: dir}
+		if len(r.Exts) == 0 {
+			return nil, fmt.Errorf("路由 %q 未指定扩展名", spec)
+		}
+		for _, e := range r.Exts {
+			if prev, dup := owner[e]; dup {
+				return nil, fmt.Errorf("扩展名 %s 同时出现在路由 %q 与 %q 中", e, prev, spec)
+			}
+			owner[e] = spec
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// OutputDirFor 返回扩展名为 ext 的输入的输出目录：匹配的路由优先，其次为 OutputPath
+// 返回空字符串表示输出到源文件旁
+func (c Config) OutputDirFor(ext string) string {
+	ext = strings.ToLower(ext)
+	for _, r := range c.Routes {
+		if slices.Contains(r.Exts, ext) {
+			return r.Dir
+		}
+	}
+	return c.OutputPath
 }
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BuiltinPresets 是内置的压缩预设 (不含 auto)
+var BuiltinPresets = []string{PresetHigh, PresetStandard, PresetLow, PresetScreen, PresetArchive}
+
+// PresetDefinition 是 --preset-file 中定义的一个自定义预设
+type PresetDefinition struct {
+	Name         string   `yaml:"name"`
+	Codec        string   `yaml:"codec"`         // 视频编码器，如 libx265、hevc_videotoolbox
+	Profile      string   `yaml:"profile"`       // -profile:v，为空则不指定
+	Quality      int      `yaml:"quality"`       // 编码器原生质量值 (libx265 为 CRF，videotoolbox 为 q:v)，0 表示使用编码器默认值
+	AudioCodec   string   `yaml:"audio_codec"`   // 为空表示流复制
+	AudioBitrate string   `yaml:"audio_bitrate"` // 如 128k，仅在 AudioCodec 非空时生效
+	ExtraArgs    []string `yaml:"extra_args"`    // 追加在视频编码参数之后的原始 ffmpeg 参数
+}
+
+// presetFile 是预设文件的顶层结构
+//
+//	presets:
+//	  - name: slow-x265
+//	    codec: libx265
+//	    quality: 20
+//	    extra_args: ["-preset", "slow"]
+type presetFile struct {
+	Presets []PresetDefinition `yaml:"presets"`
+}
+
+// LoadPresets 读取 YAML 预设文件，返回以小写预设名为键的定义
+// 与内置预设同名的定义会覆盖内置预设
+func LoadPresets(path string) (map[string]PresetDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f presetFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("解析预设文件 %s 失败: %w", path, err)
+	}
+
+	presets := make(map[string]PresetDefinition, len(f.Presets))
+	for i, p := range f.Presets {
+		p.Name = strings.ToLower(strings.TrimSpace(p.Name))
+		switch {
+		case p.Name == "":
+			return nil, fmt.Errorf("预设文件 %s 第 %d 个预设缺少 name", path, i+1)
+		case p.Name == PresetAuto:
+			return nil, fmt.Errorf("预设名 %q 为保留名称", p.Name)
+		case p.Codec == "":
+			return nil, fmt.Errorf("预设 %q 缺少 codec", p.Name)
+		case p.Quality < 0:
+			return nil, fmt.Errorf("预设 %q 的 quality 无效: %d", p.Name, p.Quality)
+		}
+		if _, dup := presets[p.Name]; dup {
+			return nil, fmt.Errorf("预设名 %q 重复", p.Name)
+		}
+		presets[p.Name] = p
+	}
+	return presets, nil
+}
+
+// KnownPreset 判断预设名是否为内置预设或自定义预设
+func (c Config) KnownPreset(name string) bool {
+	if _, ok := c.Presets[name]; ok {
+		return true
+	}
+	return slices.Contains(BuiltinPresets, name)
+}
+
+// CustomPreset 返回当前预设对应的自定义定义 (若有)
+func (c Config) CustomPreset() (PresetDefinition, bool) {
+	p, ok := c.Presets[c.Preset]
+	return p, ok
+}
@@ -0,0 +1,112 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// 事件类型
+const (
+	RunStarted  = "run_started"
+	JobQueued   = "job_queued"
+	JobStarted  = "job_started"
+	JobProgress = "progress"
+	JobFinished = "job_finished"
+	RunFinished = "run_finished"
+
+	// QueuePosition 在任务开始或重新排队后为仍在排队的每个任务发出一次，Position 为实时位置
+	QueuePosition = "queue_position"
+)
+
+// Clock 提供当前时间，测试中可替换为固定时钟以获得确定的输出
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock 使用系统时间 (带单调时钟读数)
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// Event 是 JSON 事件流中的一条记录
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`      // RFC3339 墙钟时间
+	OffsetMs int64     `json:"offset_ms"` // 相对运行开始的单调偏移 (毫秒)
+
+	Job       string  `json:"job,omitempty"`
+	OutTimeUs int64   `json:"out_time_us,omitempty"`
+	Bytes     int64   `json:"bytes,omitempty"`
+	Percent   float64 `json:"percent,omitempty"`
+	Status    string  `json:"status,omitempty"`
+	Reason    string  `json:"reason,omitempty"`
+	Total     int     `json:"total,omitempty"`
+	// Position 在 job_queued 中为初始排队位置，在 queue_position 中为队列变化后的实时位置 (均以 1 表示下一个开始，
+	// Position-1 即前面还有多少个任务)；在 job_started 中为开始的先后序号
+	Position int `json:"position,omitempty"`
+	Data     any `json:"data,omitempty"`
+}
+
+// Emitter 以 JSON Lines 格式写出事件，并发安全
+// nil Emitter 的所有方法均为空操作，调用方无需判断是否启用
+type Emitter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	clock Clock
+	start time.Time
+}
+
+// New 创建事件输出器，clock 为 nil 时使用系统时间
+func New(w io.Writer, clock Clock) *Emitter {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &Emitter{w: w, clock: clock, start: clock.Now()}
+}
+
+// Now 返回事件时钟的当前时间；nil Emitter 返回系统时间
+func (e *Emitter) Now() time.Time {
+	if e == nil {
+		return time.Now()
+	}
+	return e.clock.Now()
+}
+
+// Emit 补全时间戳并写出一条事件
+func (e *Emitter) Emit(ev Event) {
+	if e == nil {
+		return
+	}
+	now := e.clock.Now()
+	ev.Time = now
+	ev.OffsetMs = now.Sub(e.start).Milliseconds()
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.w.Write(append(data, '\n'))
+}
+
+// multiWriter 将事件写出到所有目标，忽略单个目标的错误
+type multiWriter []io.Writer
+
+func (m multiWriter) Write(p []byte) (int, error) {
+	for _, w := range m {
+		_, _ = w.Write(p)
+	}
+	return len(p), nil
+}
+
+// MultiWriter 将事件同时写出到多个目标
+// 与 io.MultiWriter 不同，某个目标出错 (如监控程序关闭了 FIFO) 不影响其余目标
+func MultiWriter(ws ...io.Writer) io.Writer {
+	if len(ws) == 1 {
+		return ws[0]
+	}
+	return multiWriter(ws)
+}
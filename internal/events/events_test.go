@@ -0,0 +1,70 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock 每次调用 Now 前进固定步长，作为确定的事件时钟
+type fakeClock struct {
+	t    time.Time
+	step time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	now := c.t
+	c.t = c.t.Add(c.step)
+	return now
+}
+
+func TestEmitterTimestamps(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	e := New(&buf, &fakeClock{t: start, step: 1500 * time.Millisecond})
+
+	e.Emit(Event{Type: RunStarted, Total: 2})
+	e.Emit(Event{Type: JobQueued, Job: "a.mp4", Position: 1})
+	e.Emit(Event{Type: JobStarted, Job: "a.mp4", Position: 1})
+	e.Emit(Event{Type: RunFinished, Total: 1})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []struct {
+		typ    string
+		time   time.Time
+		offset int64
+	}{
+		// New 读取一次时钟作为起点，因此首个事件的偏移已是一个步长
+		{RunStarted, start.Add(1500 * time.Millisecond), 1500},
+		{JobQueued, start.Add(3000 * time.Millisecond), 3000},
+		{JobStarted, start.Add(4500 * time.Millisecond), 4500},
+		{RunFinished, start.Add(6000 * time.Millisecond), 6000},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d events, want %d:\n%s", len(lines), len(want), buf.String())
+	}
+	for i, line := range lines {
+		var ev Event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("event %d: %v", i, err)
+		}
+		if ev.Type != want[i].typ || !ev.Time.Equal(want[i].time) || ev.OffsetMs != want[i].offset {
+			t.Errorf("event %d = %s at %s (+%dms), want %s at %s (+%dms)",
+				i, ev.Type, ev.Time.Format(time.RFC3339Nano), ev.OffsetMs,
+				want[i].typ, want[i].time.Format(time.RFC3339Nano), want[i].offset)
+		}
+	}
+	if !strings.Contains(lines[0], `"time":"2024-05-01T12:00:01.5Z"`) {
+		t.Errorf("time not encoded as RFC3339: %s", lines[0])
+	}
+}
+
+func TestNilEmitter(t *testing.T) {
+	var e *Emitter
+	e.Emit(Event{Type: RunStarted}) // 不应 panic
+	if e.Now().IsZero() {
+		t.Error("nil Emitter should fall back to the system clock")
+	}
+}
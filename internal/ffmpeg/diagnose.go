@@ -0,0 +1,106 @@
+package ffmpeg
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// failureCauses 是诊断时在 ffmpeg 日志中查找的常见失败原因
+var failureCauses = []struct {
+	signatures []string
+	cause      string
+}{
+	{[]string{"Unknown encoder", "Encoder not found"}, "编码器缺失: 当前 ffmpeg 未编译所需编码器 (运行 vc check-deps 确认)"},
+	{[]string{"Incompatible pixel format", "Impossible to convert between the formats", "is not supported by the encoder", "Unsupported pixel format"},
+		"像素格式不受支持: 源像素格式无法直接送入编码器 (可尝试 --bit-depth 8 或 -p high)"},
+	{[]string{"drms", "DRM", "encrypted", "Encryption"}, "DRM 保护: 文件受版权保护 (如 iTunes 购买的影片)，无法转码"},
+	{[]string{"moov atom not found", "partial file", "Invalid data found when processing input", "Truncating packet", "End of file"},
+		"文件截断或损坏: 源文件不完整 (下载/拷贝中断?)，可先用播放器确认能否完整播放"},
+}
+
+// Diagnosis 是对一次失败任务的诊断结果
+type Diagnosis struct {
+	Causes           []string // 识别出的可能原因
+	SoftwareDecodeOK bool     // 关闭硬件解码后重跑是否成功
+	LogFile          string   // 详细日志的保存位置
+}
+
+// Summary 返回一段可读的诊断结论
+func (d Diagnosis) Summary() string {
+	var parts []string
+	if d.SoftwareDecodeOK {
+		parts = append(parts, "关闭硬件解码后重跑成功: 问题出在 videotoolbox 硬件解码")
+	}
+	parts = append(parts, d.Causes...)
+	if len(parts) == 0 {
+		parts = append(parts, "未识别出常见原因，请查看详细日志")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Diagnose 以 -v verbose 和软件解码重跑失败的命令，并结合原始错误检查常见失败原因
+// 重跑的输出写入 scratchDir 下的子目录并在结束后删除；详细日志保留在 workDir 下的子目录中供进一步排查
+// 两者为空时使用系统临时目录
+func Diagnose(args []string, runErr error, workDir, scratchDir string) Diagnosis {
+	tmpDir, err := os.MkdirTemp(workDir, "vc-diagnose-")
+	if err != nil {
+		return Diagnosis{Causes: matchCauses(stderrOf(runErr))}
+	}
+	outDir, err := os.MkdirTemp(scratchDir, "diagnose-")
+	if err != nil {
+		return Diagnosis{Causes: matchCauses(stderrOf(runErr))}
+	}
+	defer os.RemoveAll(outDir)
+
+	diagArgs := diagnoseArgs(args, filepath.Join(outDir, filepath.Base(args[len(args)-1])))
+	// -v verbose 完整重编码的日志可达数百 MB：直接写入日志文件，内存中只保留末尾用于查找失败原因
+	var d Diagnosis
+	tail := NewTailBuffer(0)
+	var log io.Writer = tail
+	logFile := filepath.Join(tmpDir, "ffmpeg-verbose.log")
+	if f, err := os.Create(logFile); err == nil {
+		defer f.Close()
+		log = io.MultiWriter(f, tail)
+		d.LogFile = logFile
+	}
+	cmd := exec.Command("ffmpeg", diagArgs...)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	d.SoftwareDecodeOK = cmd.Run() == nil
+
+	d.Causes = matchCauses(stderrOf(runErr) + "\n" + string(tail.Bytes()))
+	return d
+}
+
+// diagnoseArgs 去掉硬件解码并输出详细日志，同时把输出重定向到 output
+func diagnoseArgs(args []string, output string) []string {
+	diag := []string{"-v", "verbose"}
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == "-hwaccel" && i+1 < len(args) {
+			i++
+			continue
+		}
+		if args[i] == "-progress" && i+1 < len(args) {
+			// 诊断时不解析进度
+			i++
+			continue
+		}
+		diag = append(diag, args[i])
+	}
+	return append(diag, output)
+}
+
+// matchCauses 在日志中查找 failureCauses 的特征
+func matchCauses(log string) []string {
+	var causes []string
+	for _, c := range failureCauses {
+		if slices.ContainsFunc(c.signatures, func(sig string) bool { return strings.Contains(log, sig) }) {
+			causes = append(causes, c.cause)
+		}
+	}
+	return causes
+}
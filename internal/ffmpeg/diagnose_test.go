@@ -0,0 +1,66 @@
+package ffmpeg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 诊断重跑的输出写入临时目录 (scratchDir) 并在结束后删除，详细日志保留在工作目录 (workDir)
+func TestDiagnoseDirs(t *testing.T) {
+	workDir, scratchDir := t.TempDir(), t.TempDir()
+	seen := filepath.Join(t.TempDir(), "output")
+	fakeFFmpeg(t, `for a in "$@"; do out="$a"; done
+printf '%s' "$out" > '`+seen+`'
+printf data > "$out"
+echo 'Stream #0:0: Video: h264, yuv420p' >&2
+`)
+	d := Diagnose([]string{"-y", "-hwaccel", "videotoolbox", "-i", "in.mov", "-c:v", "hevc_videotoolbox", "/videos/out.mp4"}, nil, workDir, scratchDir)
+	if !d.SoftwareDecodeOK {
+		t.Error("SoftwareDecodeOK = false, want true")
+	}
+
+	out, err := os.ReadFile(seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), scratchDir+string(filepath.Separator)) {
+		t.Errorf("re-run wrote %s, want a file under %s", out, scratchDir)
+	}
+	if entries, _ := os.ReadDir(scratchDir); len(entries) != 0 {
+		t.Errorf("scratch dir not cleaned up: %v", entries)
+	}
+
+	if !strings.HasPrefix(d.LogFile, workDir+string(filepath.Separator)) {
+		t.Fatalf("LogFile = %q, want it under %s", d.LogFile, workDir)
+	}
+	if log, err := os.ReadFile(d.LogFile); err != nil || !strings.Contains(string(log), "Video: h264") {
+		t.Errorf("verbose log = %q, %v", log, err)
+	}
+}
+
+// 数 MB 的 verbose 输出完整写入日志文件，失败原因从保留的末尾中识别
+func TestDiagnoseLargeLog(t *testing.T) {
+	workDir := t.TempDir()
+	fakeFFmpeg(t, `i=0
+while [ $i -lt 4 ]; do head -c 2097152 /dev/zero | tr '\0' 'w' >&2; i=$((i+1)); done
+echo >&2
+echo '[hevc_videotoolbox @ 0x1] Unsupported pixel format yuv422p10le' >&2
+exit 1
+`)
+	d := Diagnose([]string{"-y", "-i", "in.mov", "out.mp4"}, nil, workDir, t.TempDir())
+	if d.SoftwareDecodeOK {
+		t.Error("SoftwareDecodeOK = true, want false")
+	}
+	fi, err := os.Stat(d.LogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(4<<20*2 + 1 + len("[hevc_videotoolbox @ 0x1] Unsupported pixel format yuv422p10le\n")); fi.Size() != want {
+		t.Errorf("log file is %d bytes, want the full %d", fi.Size(), want)
+	}
+	if len(d.Causes) != 1 || !strings.Contains(d.Causes[0], "像素格式") {
+		t.Errorf("Causes = %q, want the pixel format cause", d.Causes)
+	}
+}
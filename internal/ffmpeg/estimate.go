@@ -0,0 +1,45 @@
+package ffmpeg
+
+import (
+	"slices"
+	"time"
+	"video-compress/internal/config"
+)
+
+// EstimateEncodingTime 估算单个文件的编码墙钟时间
+// resolution 为输出高度 (像素)，介于表中两点之间时线性插值，超出范围时取边界值
+func EstimateEncodingTime(durationSec float64, preset string, resolution int) time.Duration {
+	if durationSec <= 0 {
+		return 0
+	}
+	// 自定义预设没有实测数据，沿用 standard
+	table := builtinPresets[config.PresetStandard].EncodeSpeeds
+	if p, ok := builtinPresets[preset]; ok && len(p.EncodeSpeeds) > 0 {
+		table = p.EncodeSpeeds
+	}
+	ratio := interpolateRatio(table, resolution)
+	return time.Duration(durationSec / ratio * float64(time.Second))
+}
+
+func interpolateRatio(table map[int]float64, resolution int) float64 {
+	heights := make([]int, 0, len(table))
+	for h := range table {
+		heights = append(heights, h)
+	}
+	slices.Sort(heights)
+
+	if resolution <= 0 {
+		resolution = 1080
+	}
+	if resolution <= heights[0] {
+		return table[heights[0]]
+	}
+	for i := 1; i < len(heights); i++ {
+		lo, hi := heights[i-1], heights[i]
+		if resolution <= hi {
+			t := float64(resolution-lo) / float64(hi-lo)
+			return table[lo] + t*(table[hi]-table[lo])
+		}
+	}
+	return table[heights[len(heights)-1]]
+}
@@ -0,0 +1,226 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"video-compress/internal/config"
+)
+
+// 水印位置
+const (
+	WatermarkTopLeft     = "top-left"
+	WatermarkTopRight    = "top-right"
+	WatermarkBottomLeft  = "bottom-left"
+	WatermarkBottomRight = "bottom-right"
+)
+
+// WatermarkPositions 列出所有合法的水印位置
+var WatermarkPositions = []string{WatermarkTopLeft, WatermarkTopRight, WatermarkBottomLeft, WatermarkBottomRight}
+
+// FilterChain 按添加顺序组装以逗号连接的滤镜链
+// ffmpeg 只采用最后一个 -vf，所有来源的滤镜必须合并为同一条链；
+// BuildArgs 按 旋转 (--auto-rotate) → 缩放 → 色调映射 → 字幕烧录 → 用户滤镜 的顺序添加，
+// 水印叠加与像素格式转换由 buildVideoFilter 接在其后
+type FilterChain struct {
+	filters []string
+}
+
+// Add 追加一个滤镜，空字符串被忽略
+func (c *FilterChain) Add(filter string) *FilterChain {
+	if filter != "" {
+		c.filters = append(c.filters, filter)
+	}
+	return c
+}
+
+// Build 返回合并后的滤镜链，没有滤镜时返回空字符串
+func (c *FilterChain) Build() string {
+	return strings.Join(c.filters, ",")
+}
+
+// ParseSAR 解析 ffprobe 的 sample_aspect_ratio (如 "4:3")，未知或非法时返回 0
+func ParseSAR(s string) float64 {
+	num, den, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0
+	}
+	n, err1 := strconv.Atoi(num)
+	d, err2 := strconv.Atoi(den)
+	if err1 != nil || err2 != nil || n <= 0 || d <= 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// resolutionFilter 构建 --resolution 的缩放滤镜，不需要缩放时返回空字符串
+// 按显示尺寸 (编码宽度 × SAR) 比较：长边不超过 r.Long、短边不超过 r.Short，竖屏视频即限制高度为 r.Long；
+// 需要缩放时输出方形像素 (setsar=1)，不放大
+func resolutionFilter(r config.Resolution, in InputInfo) string {
+	if in.Width <= 0 || in.Height <= 0 {
+		// 未知分辨率：按横屏限制高度 (与 --max-height 相同)
+		return fmt.Sprintf("scale=-2:'min(%d,ih)'", r.Short)
+	}
+	w, h, ok := resolutionSize(r, in)
+	if !ok {
+		return "" // 已在范围内，保留原始编码尺寸 (变形宽银幕同样不重新采样)
+	}
+	return fmt.Sprintf("scale=%d:%d,setsar=1", w, h)
+}
+
+// resolutionSize 返回按档位 r 缩放后的输出尺寸 (按显示尺寸计算)，已在范围内时 ok 为 false
+func resolutionSize(r config.Resolution, in InputInfo) (w, h int, ok bool) {
+	sar := in.SAR
+	if sar <= 0 {
+		sar = 1
+	}
+	dispW, dispH := float64(in.Width)*sar, float64(in.Height)
+	maxW, maxH := float64(r.Long), float64(r.Short)
+	if dispH > dispW {
+		maxW, maxH = maxH, maxW
+	}
+	scale := min(maxW/dispW, maxH/dispH)
+	if scale >= 1 {
+		return 0, 0, false
+	}
+	return evenSize(dispW * scale), evenSize(dispH * scale), true
+}
+
+// evenSize 将尺寸取整为偶数 (编码器要求)
+func evenSize(v float64) int { return max(2, int(v/2+0.5)*2) }
+
+// transposeFilter 返回将画面顺时针旋转 deg 度的滤镜
+func transposeFilter(deg int) string {
+	switch deg {
+	case 90:
+		return "transpose=1"
+	case 180:
+		return "transpose=2,transpose=2"
+	case 270:
+		return "transpose=2"
+	}
+	return ""
+}
+
+// Displayed 返回按 Rotation 转正后的画面信息：旋转 90°/270° 时交换宽高与像素宽高比
+func (in InputInfo) Displayed() InputInfo {
+	if in.Rotation == 90 || in.Rotation == 270 {
+		in.Width, in.Height = in.Height, in.Width
+		if in.SAR > 0 {
+			in.SAR = 1 / in.SAR
+		}
+	}
+	return in
+}
+
+// OutputDimensions 估算输出画面的编码尺寸 (应用 --auto-rotate / --max-height / --resolution 之后)，输入分辨率未知时返回 0, 0
+func OutputDimensions(cfg config.Config, in InputInfo) (int, int) {
+	if cfg.AutoRotate {
+		in = in.Displayed()
+	}
+	if in.Width <= 0 || in.Height <= 0 {
+		return 0, 0
+	}
+	if cfg.MaxHeight > 0 {
+		if in.Height <= cfg.MaxHeight {
+			return in.Width, in.Height
+		}
+		return evenSize(float64(in.Width) * float64(cfg.MaxHeight) / float64(in.Height)), cfg.MaxHeight
+	}
+	if r, ok := config.LookupResolution(cfg.Resolution); ok {
+		if w, h, ok := resolutionSize(r, in); ok {
+			return w, h
+		}
+	}
+	return in.Width, in.Height
+}
+
+// buildVideoFilter 组装 -vf 滤镜图
+// base 为叠加水印之前的滤镜 (如缩放)，水印在最终分辨率上叠加，保证 logo 大小不随缩放变化；
+// post 为叠加之后的滤镜 (如像素格式转换)
+func buildVideoFilter(base *FilterChain, post []string, cfg config.Config) string {
+	if cfg.Watermark == "" {
+		return strings.Join(append(base.filters, post...), ",")
+	}
+
+	chain := "null"
+	if b := base.Build(); b != "" {
+		chain = b
+	}
+	graph := fmt.Sprintf("movie=%s,format=rgba,colorchannelmixer=aa=%s[wm];[in]%s[base];[base][wm]overlay=%s",
+		escapeFilterValue(cfg.Watermark),
+		strconv.FormatFloat(cfg.WatermarkOpacity, 'f', -1, 64),
+		chain,
+		watermarkPosition(cfg.WatermarkPosition, cfg.WatermarkPadding),
+	)
+	if len(post) > 0 {
+		graph += "," + strings.Join(post, ",")
+	}
+	return graph
+}
+
+// watermarkPosition 返回 overlay 的 x:y 表达式，padding 为距边缘的像素
+func watermarkPosition(position string, padding int) string {
+	p := strconv.Itoa(padding)
+	switch position {
+	case WatermarkTopLeft:
+		return p + ":" + p
+	case WatermarkTopRight:
+		return "W-w-" + p + ":" + p
+	case WatermarkBottomLeft:
+		return p + ":H-h-" + p
+	default:
+		return "W-w-" + p + ":H-h-" + p
+	}
+}
+
+// escapeFilterValue 转义滤镜参数中的特殊字符 (路径中的冒号、逗号、方括号等)
+func escapeFilterValue(v string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`:`, `\:`,
+		`'`, `\'`,
+		`,`, `\,`,
+		`;`, `\;`,
+		`[`, `\[`,
+		`]`, `\]`,
+	).Replace(v)
+}
+
+// HDR 传输特性 (ffprobe 的 color_transfer)
+const (
+	TransferPQ  = "smpte2084"    // HDR10 / Dolby Vision 基础层
+	TransferHLG = "arib-std-b67" // HLG (广播、iPhone 录制)
+)
+
+// IsHDR 判断输入是否为 PQ 或 HLG 编码的 HDR 视频
+func (in InputInfo) IsHDR() bool {
+	return in.ColorTransfer == TransferPQ || in.ColorTransfer == TransferHLG
+}
+
+// tonemapFilter 返回 HDR → SDR (BT.709) 的色调映射滤镜链，输入不是 HDR 时返回空
+//  1. 按探测到的传输特性/色域显式指定输入属性 (部分文件的帧属性缺失)，转换到线性光；
+//     npl=100 将 SDR 参考白定为 100 nit (取值过高会使画面整体偏暗)
+//  2. 在 32 位浮点下转换色域到 BT.709，再用 hable 压缩高光；desat=0 关闭高光去饱和，避免画面发灰
+//  3. 转回 BT.709 传输特性与 TV range，输出位深与编码器一致
+func tonemapFilter(in InputInfo, depth int) string {
+	if !in.IsHDR() {
+		return ""
+	}
+	primaries := in.ColorPrimaries
+	if primaries == "" {
+		primaries = "bt2020"
+	}
+	pixFmt := "yuv420p"
+	if depth == 10 || (depth == 0 && in.BitDepth > 8) {
+		pixFmt = "yuv420p10le"
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("zscale=tin=%s:pin=%s:min=bt2020nc:t=linear:npl=100", in.ColorTransfer, primaries),
+		"format=gbrpf32le",
+		"zscale=p=bt709",
+		"tonemap=tonemap=hable:desat=0",
+		"zscale=t=bt709:m=bt709:r=tv",
+		"format=" + pixFmt,
+	}, ",")
+}
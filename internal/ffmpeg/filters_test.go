@@ -0,0 +1,74 @@
+package ffmpeg
+
+import (
+	"testing"
+	"video-compress/internal/config"
+)
+
+// 旋转、缩放、用户滤镜与水印必须合并为一个 -vf，且按 转正 → 缩放 → 用户滤镜 → 水印 → 像素格式 的顺序
+func TestVideoFiltersCombined(t *testing.T) {
+	cfg := config.Config{
+		Preset:            config.PresetHigh,
+		AutoRotate:        true,
+		MaxHeight:         720,
+		VideoFilter:       "hflip",
+		Watermark:         "logo.png",
+		WatermarkOpacity:  0.5,
+		WatermarkPosition: WatermarkBottomRight,
+		WatermarkPadding:  20,
+	}
+	in := InputInfo{VideoStream: -1, Width: 1920, Height: 1080, PixFmt: "yuv420p", BitDepth: 8, AudioChannels: 2, Rotation: 90}
+	args := BuildArgs("in.mp4", "out.mp4", cfg, in)
+
+	want := "movie=logo.png,format=rgba,colorchannelmixer=aa=0.5[wm];" +
+		"[in]transpose=1,scale=-2:'min(720,ih)',hflip[base];" +
+		"[base][wm]overlay=W-w-20:H-h-20,format=yuv420p"
+	if got := argValues(args, "-vf"); len(got) != 1 || got[0] != want {
+		t.Errorf("-vf = %q\nwant [%q]", got, want)
+	}
+	if got := argValues(args, "-filter_complex"); len(got) != 0 {
+		t.Errorf("unexpected -filter_complex %q", got)
+	}
+}
+
+func TestVideoFiltersWithoutWatermark(t *testing.T) {
+	cfg := config.Config{Preset: config.PresetHigh, AutoRotate: true, MaxHeight: 720, VideoFilter: "hflip"}
+	in := InputInfo{VideoStream: -1, Width: 1920, Height: 1080, PixFmt: "yuv420p", BitDepth: 8, Rotation: 270}
+	want := "transpose=2,scale=-2:'min(720,ih)',hflip,format=yuv420p"
+	if got := argValues(BuildArgs("in.mp4", "out.mp4", cfg, in), "-vf"); len(got) != 1 || got[0] != want {
+		t.Errorf("-vf = %q, want [%q]", got, want)
+	}
+}
+
+func TestResolutionFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		resolution string
+		in         InputInfo
+		want       string
+	}{
+		// 1440x1080 变形宽银幕 (SAR 4:3) 显示为 1920x1080
+		{"anamorphic to 720p", "720p", InputInfo{Width: 1440, Height: 1080, SAR: 4.0 / 3}, "scale=1280:720,setsar=1"},
+		{"anamorphic within 1080p", "1080p", InputInfo{Width: 1440, Height: 1080, SAR: 4.0 / 3}, ""},
+		{"anamorphic to 480p", "480p", InputInfo{Width: 1440, Height: 1080, SAR: 4.0 / 3}, "scale=854:480,setsar=1"},
+		// 竖屏：长边 (高度) 按 Long 限制，而不是被压到 Short
+		{"portrait to 720p", "720p", InputInfo{Width: 1080, Height: 1920}, "scale=720:1280,setsar=1"},
+		{"portrait within 1080p", "1080p", InputInfo{Width: 1080, Height: 1920}, ""},
+		{"landscape to 720p", "720p", InputInfo{Width: 1920, Height: 1080}, "scale=1280:720,setsar=1"},
+		{"never upscale", "4k", InputInfo{Width: 1920, Height: 1080}, ""},
+		{"odd result rounded to even", "480p", InputInfo{Width: 1920, Height: 800}, "scale=854:356,setsar=1"},
+		{"unknown size", "720p", InputInfo{}, "scale=-2:'min(720,ih)'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := config.LookupResolution(tt.resolution)
+			if !ok {
+				t.Fatalf("unknown resolution %q", tt.resolution)
+			}
+			if got := resolutionFilter(r, tt.in); got != tt.want {
+				t.Errorf("resolutionFilter(%s, %dx%d SAR %.3f) = %q, want %q",
+					tt.resolution, tt.in.Width, tt.in.Height, tt.in.SAR, got, tt.want)
+			}
+		})
+	}
+}
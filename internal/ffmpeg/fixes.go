@@ -0,0 +1,45 @@
+package ffmpeg
+
+import (
+	"slices"
+	"strings"
+)
+
+// 已知可通过追加参数自动修复的复用 (mux) 错误
+// 这些参数会增加内存占用，因此只在检测到对应错误后重试时才加入
+var muxingFixes = []struct {
+	signature string
+	input     bool // true: 输入参数，插入到 -i 之前；false: 输出参数，插入到输出文件之前
+	args      []string
+}{
+	{"Too many packets buffered for output stream", false, []string{"-max_muxing_queue_size", "4096"}},
+	{"non monotonically increasing dts", true, []string{"-fflags", "+genpts"}},
+	{"Non-monotonous DTS", true, []string{"-fflags", "+genpts"}},
+}
+
+// MuxingFix 根据失败时的 stderr 判断是否属于可自动修复的复用错误
+// 可修复时返回追加了修复参数的新参数列表及修复说明；否则 note 为空
+func MuxingFix(args []string, err error) (fixed []string, note string) {
+	stderr := stderrOf(err)
+	if stderr == "" {
+		return nil, ""
+	}
+
+	fixed = slices.Clone(args)
+	var notes []string
+	for _, fix := range muxingFixes {
+		if !strings.Contains(stderr, fix.signature) || slices.Contains(fixed, fix.args[1]) {
+			continue
+		}
+		pos := len(fixed) - 1 // 输出文件
+		if fix.input {
+			pos = slices.Index(fixed, "-i")
+		}
+		fixed = slices.Insert(fixed, pos, fix.args...)
+		notes = append(notes, strings.Join(fix.args, " "))
+	}
+	if len(notes) == 0 {
+		return nil, ""
+	}
+	return fixed, strings.Join(notes, ", ")
+}
@@ -0,0 +1,39 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HWAccel 是本工具用于解码的硬件加速方式
+const HWAccel = "videotoolbox"
+
+// buildHWAccelDeviceArgs 返回在多 GPU 机器上选择硬件加速设备的参数 (需放在 -i 之前)
+// 支持设备选择的加速方式:
+//   - cuda / nvdec: -hwaccel_device <idx> (NVENC/NVDEC 所在 GPU 序号)
+//   - vaapi: -vaapi_device /dev/dri/renderD<128+idx>，也可直接给出设备路径
+//
+// videotoolbox 只有一个设备，返回 nil；device 为空、不是非负序号 (vaapi 的设备路径除外) 或加速方式无法识别时同样返回 nil
+func buildHWAccelDeviceArgs(accel, device string) []string {
+	if device == "" {
+		return nil
+	}
+	idx, err := strconv.Atoi(device)
+	valid := err == nil && idx >= 0
+	switch accel {
+	case "cuda", "nvdec":
+		if valid {
+			return []string{"-hwaccel_device", strconv.Itoa(idx)}
+		}
+	case "vaapi":
+		if valid {
+			return []string{"-vaapi_device", fmt.Sprintf("/dev/dri/renderD%d", 128+idx)}
+		}
+		if strings.HasPrefix(device, "/") {
+			// 已经是设备路径
+			return []string{"-vaapi_device", device}
+		}
+	}
+	return nil
+}
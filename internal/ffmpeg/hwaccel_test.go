@@ -0,0 +1,51 @@
+package ffmpeg
+
+import (
+	"slices"
+	"testing"
+	"video-compress/internal/config"
+)
+
+// 只有 cuda/nvdec 与 vaapi 支持设备选择；无效或非数字的序号不生成参数
+func TestBuildHWAccelDeviceArgs(t *testing.T) {
+	tests := []struct {
+		accel, device string
+		want          []string
+	}{
+		{"videotoolbox", "0", nil},
+		{"videotoolbox", "1", nil},
+		{"cuda", "", nil},
+		{"cuda", "0", []string{"-hwaccel_device", "0"}},
+		{"cuda", "1", []string{"-hwaccel_device", "1"}},
+		{"cuda", "gpu1", nil},
+		{"cuda", "-1", nil},
+		{"nvdec", "2", []string{"-hwaccel_device", "2"}},
+		{"nvdec", "x", nil},
+		{"vaapi", "0", []string{"-vaapi_device", "/dev/dri/renderD128"}},
+		{"vaapi", "1", []string{"-vaapi_device", "/dev/dri/renderD129"}},
+		{"vaapi", "/dev/dri/renderD130", []string{"-vaapi_device", "/dev/dri/renderD130"}},
+		{"vaapi", "-1", nil},
+		{"vaapi", "intel", nil},
+		{"qsv", "0", nil},
+		{"", "0", nil},
+	}
+	for _, tt := range tests {
+		if got := buildHWAccelDeviceArgs(tt.accel, tt.device); !slices.Equal(got, tt.want) {
+			t.Errorf("buildHWAccelDeviceArgs(%q, %q) = %q, want %q", tt.accel, tt.device, got, tt.want)
+		}
+	}
+}
+
+// 本工具使用 videotoolbox 解码，--hwaccel-device 不会出现在命令中
+func TestBuildArgsIgnoresHWAccelDevice(t *testing.T) {
+	cfg := config.Config{Preset: config.PresetHigh, HWAccelDevice: "1"}
+	args := BuildArgs("in.mp4", "out.mp4", cfg, InputInfo{VideoStream: -1, Width: 1920, Height: 1080})
+	if got := argValues(args, "-hwaccel"); !slices.Equal(got, []string{HWAccel}) {
+		t.Errorf("-hwaccel = %q, want [%q]", got, HWAccel)
+	}
+	for _, flag := range []string{"-hwaccel_device", "-vaapi_device"} {
+		if got := argValues(args, flag); len(got) != 0 {
+			t.Errorf("unexpected %s %q", flag, got)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package ffmpeg
+
+import (
+	"strings"
+	"video-compress/internal/config"
+)
+
+// 预设的像素格式处理方式
+const (
+	PixelsNone       = ""               // 不处理，由自定义预设的 extra_args 自行决定
+	PixelsHardware   = "hardware"       // videotoolbox：按位深选择 profile 与 -pix_fmt，编码器不接受的源格式先转换
+	PixelsSoftware   = "software"       // 软件编码：编码前以 format 滤镜转换为 yuv420p/yuv420p10le
+	PixelsSoftware10 = "software-10bit" // 同 software，但默认输出 10-bit (--bit-depth 8 可覆盖)
+)
+
+// Preset 描述一个预设的视频编码方式
+// 内置预设与 --preset-file 中的自定义预设都归结为此结构，BuildArgs、质量映射、编码器判断与耗时估算均以此为准
+type Preset struct {
+	Name        string
+	Description string // 供 --list-presets / vc presets show 展示
+
+	Encoder        string          // 视频编码器
+	QualityFlag    string          // 原生质量参数名: crf 或 q:v
+	DefaultQuality int             // 未指定 --quality 时的原生质量值，0 表示不传质量参数
+	MapQuality     func(q int) int // 将 --quality (1-100，越大画质越高) 映射为原生质量值
+	Args           []string        // 紧随质量参数之后的编码器参数
+	X265Params     []string        // 并入 -x265-params 的参数
+	Pixels         string          // 像素格式处理方式 (Pixels*)
+	EncodeSpeeds   map[int]float64 // 不同输出高度下的经验编码速度 (编码速度 / 实时)，为空时沿用 standard
+
+	// 自定义预设指定的音频编码与码率，为空表示按默认策略 (流复制，必要时转码为 AAC)
+	AudioCodec   string
+	AudioBitrate string
+}
+
+// x265CRF 将 --quality 映射为 libx265 的 CRF (0-51，越小画质越高)
+func x265CRF(q int) int { return max(0, 51-q/2) }
+
+// av1CRF 将 --quality 映射为 SVT-AV1 的 CRF (0-63)
+func av1CRF(q int) int { return max(0, 63-(q*63/100)) }
+
+// videotoolboxQ 即 videotoolbox 的 -q:v (1-100，越大画质越高)，与 --quality 刻度一致
+func videotoolboxQ(q int) int { return q }
+
+// builtinPresets 是内置预设的定义
+// 编码速度基于 Apple Silicon M2 Max 的实测，其他机器仅作参考
+var builtinPresets = map[string]Preset{
+	config.PresetHigh: {
+		Name:           config.PresetHigh,
+		Description:    "-preset medium，软件编码，画质优先",
+		Encoder:        SoftwareEncoder,
+		QualityFlag:    "crf",
+		DefaultQuality: 24,
+		MapQuality:     x265CRF,
+		Args:           []string{"-preset", "medium", "-tag:v", "hvc1"},
+		Pixels:         PixelsSoftware,
+		EncodeSpeeds:   map[int]float64{720: 0.6, 1080: 0.3, 2160: 0.12, 4320: 0.08},
+	},
+	config.PresetStandard: {
+		Name:           config.PresetStandard,
+		Description:    "硬件编码，速度与体积均衡",
+		Encoder:        HardwareEncoder,
+		QualityFlag:    "q:v",
+		DefaultQuality: 50,
+		MapQuality:     videotoolboxQ,
+		Pixels:         PixelsHardware,
+		EncodeSpeeds:   map[int]float64{720: 4.0, 1080: 2.0, 2160: 0.9, 4320: 0.5},
+	},
+	config.PresetLow: {
+		Name:           config.PresetLow,
+		Description:    "硬件编码，体积优先",
+		Encoder:        HardwareEncoder,
+		QualityFlag:    "q:v",
+		DefaultQuality: 40,
+		MapQuality:     videotoolboxQ,
+		Pixels:         PixelsHardware,
+		EncodeSpeeds:   map[int]float64{720: 4.5, 1080: 2.2, 2160: 1.0, 4320: 0.55},
+	},
+	// 屏幕录制：文字锐利、画面大多静止
+	// tune animation 保留锐利边缘，长 GOP 充分利用静止画面，帧率上限 30
+	config.PresetScreen: {
+		Name:           config.PresetScreen,
+		Description:    "-tune animation -fpsmax 30，长 GOP，适合录屏",
+		Encoder:        SoftwareEncoder,
+		QualityFlag:    "crf",
+		DefaultQuality: 28,
+		MapQuality:     x265CRF,
+		Args:           []string{"-preset", "medium", "-tune", "animation", "-fpsmax", "30", "-tag:v", "hvc1"},
+		X265Params:     []string{"keyint=600", "min-keyint=30"},
+		Pixels:         PixelsSoftware,
+		EncodeSpeeds:   map[int]float64{720: 0.9, 1080: 0.45, 2160: 0.18, 4320: 0.1},
+	},
+	// 冷存储归档：SVT-AV1 慢速预设，只求体积最小，耗时通常是 high 的数倍
+	// 默认 10-bit (8-bit 源也能减少色带并略微提高压缩率)
+	config.PresetArchive: {
+		Name:           config.PresetArchive,
+		Description:    "SVT-AV1 -preset 4，10-bit，极慢，体积最小 (冷存储)",
+		Encoder:        ArchiveEncoder,
+		QualityFlag:    "crf",
+		DefaultQuality: 35,
+		MapQuality:     av1CRF,
+		Args:           []string{"-preset", "4", "-g", "300"},
+		Pixels:         PixelsSoftware10,
+		EncodeSpeeds:   map[int]float64{720: 0.3, 1080: 0.12, 2160: 0.04, 4320: 0.02},
+	},
+}
+
+// BuiltinPreset 返回内置预设的定义
+func BuiltinPreset(name string) (Preset, bool) {
+	p, ok := builtinPresets[name]
+	return p, ok
+}
+
+// ResolvePreset 返回 cfg 当前生效的预设：自定义预设优先于同名内置预设，未知名称 (含 auto) 按 standard 处理
+func ResolvePreset(cfg config.Config) Preset {
+	if def, ok := cfg.CustomPreset(); ok {
+		return customPreset(cfg.Preset, def)
+	}
+	if p, ok := builtinPresets[cfg.Preset]; ok {
+		return p
+	}
+	return builtinPresets[config.PresetStandard]
+}
+
+// customPreset 将 --preset-file 中的定义转换为 Preset
+// 质量参数按编码器推断：videotoolbox 使用 q:v，其余使用 CRF (按 x265 刻度映射 --quality)
+func customPreset(name string, def config.PresetDefinition) Preset {
+	p := Preset{
+		Name:           name,
+		Encoder:        def.Codec,
+		QualityFlag:    "crf",
+		DefaultQuality: def.Quality,
+		MapQuality:     x265CRF,
+		AudioCodec:     def.AudioCodec,
+		AudioBitrate:   def.AudioBitrate,
+	}
+	if strings.HasSuffix(def.Codec, "_videotoolbox") {
+		p.QualityFlag, p.MapQuality = "q:v", videotoolboxQ
+	}
+	if def.Profile != "" {
+		p.Args = append(p.Args, "-profile:v", def.Profile)
+	}
+	if def.Codec == SoftwareEncoder || def.Codec == HardwareEncoder {
+		p.Args = append(p.Args, "-tag:v", "hvc1")
+	}
+	p.Args = append(p.Args, def.ExtraArgs...)
+	return p
+}
@@ -0,0 +1,45 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"video-compress/internal/config"
+)
+
+// testdata/preset_args.golden 由预设改为数据结构 (vc presets show) 之前的 BuildArgs 生成，
+// 内置预设的参数必须与之逐字一致
+func TestBuiltinPresetArgsGolden(t *testing.T) {
+	inputs := []struct {
+		name string
+		in   InputInfo
+	}{
+		{"1080p8", InputInfo{VideoStream: -1, Width: 1920, Height: 1080, PixFmt: "yuv420p", BitDepth: 8, AudioChannels: 2}},
+		{"2160p10", InputInfo{VideoStream: 0, Width: 3840, Height: 2160, PixFmt: "yuv420p10le", BitDepth: 10, AudioChannels: 6}},
+	}
+	var got strings.Builder
+	for _, p := range []string{config.PresetHigh, config.PresetStandard, config.PresetLow, config.PresetScreen, config.PresetArchive} {
+		for _, in := range inputs {
+			for _, q := range []int{0, 70} {
+				args := BuildArgs("in.mov", "out.mp4", config.Config{Preset: p, Quality: q}, in.in)
+				fmt.Fprintf(&got, "%s %s q%d: %s\n", p, in.name, q, strings.Join(args, " "))
+			}
+		}
+	}
+
+	want, err := os.ReadFile(filepath.Join("testdata", "preset_args.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotLines, wantLines := strings.Split(got.String(), "\n"), strings.Split(string(want), "\n")
+	if len(gotLines) != len(wantLines) {
+		t.Fatalf("got %d lines, golden has %d", len(gotLines), len(wantLines))
+	}
+	for i := range wantLines {
+		if gotLines[i] != wantLines[i] {
+			t.Errorf("args changed:\n got: %s\nwant: %s", gotLines[i], wantLines[i])
+		}
+	}
+}
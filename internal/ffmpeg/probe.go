@@ -0,0 +1,185 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// HardwareEncoder 是 standard/low 预设使用的硬件编码器
+	HardwareEncoder = "hevc_videotoolbox"
+	// SoftwareEncoder 是 high 预设使用的软件编码器
+	SoftwareEncoder = "libx265"
+	// ArchiveEncoder 是 archive 预设使用的 AV1 编码器 (SVT-AV1)
+	ArchiveEncoder = "libsvtav1"
+
+	// MinVersion 是本工具要求的最低 FFmpeg 版本 (major.minor)
+	MinVersion = "5.0"
+)
+
+// Version 返回本机 ffmpeg 的版本号，如 "6.1.1"
+// 自行编译的开发版 (如 "N-113000-g...") 原样返回
+func Version() (string, error) {
+	out, err := exec.Command("ffmpeg", "-hide_banner", "-version").Output()
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != "ffmpeg" || fields[1] != "version" {
+		return "", fmt.Errorf("无法解析版本信息: %q", line)
+	}
+	return fields[2], nil
+}
+
+// VersionAtLeast 判断版本号 v 是否不低于 min (仅比较 major.minor)
+// 无法解析的版本 (开发版) 视为满足要求
+func VersionAtLeast(v, min string) bool {
+	parse := func(s string) (int, int, bool) {
+		s = strings.TrimPrefix(s, "n")
+		parts := strings.SplitN(s, ".", 3)
+		major, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, 0, false
+		}
+		minor := 0
+		if len(parts) > 1 {
+			// 去掉诸如 "1-tessus" 的后缀
+			digits := strings.TrimRightFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' })
+			minor, _ = strconv.Atoi(digits)
+		}
+		return major, minor, true
+	}
+
+	vMajor, vMinor, ok := parse(v)
+	if !ok {
+		return true
+	}
+	mMajor, mMinor, _ := parse(min)
+	if vMajor != mMajor {
+		return vMajor > mMajor
+	}
+	return vMinor >= mMinor
+}
+
+var (
+	encodersOnce sync.Once
+	encoders     map[string]string
+	encodersErr  error
+)
+
+// Encoders 返回本机 ffmpeg 支持的编码器 (名称 -> 能力标记，如 "V....D")
+// 结果在进程内缓存，只调用一次 ffmpeg -encoders
+func Encoders() (map[string]string, error) {
+	encodersOnce.Do(func() {
+		out, err := exec.Command("ffmpeg", "-hide_banner", "-encoders").Output()
+		if err != nil {
+			encodersErr = err
+			return
+		}
+		encoders = parseEncoders(out)
+	})
+	return encoders, encodersErr
+}
+
+// HasEncoder 判断本机 ffmpeg 是否包含指定编码器
+func HasEncoder(name string) (bool, error) {
+	list, err := Encoders()
+	if err != nil {
+		return false, err
+	}
+	_, ok := list[name]
+	return ok, nil
+}
+
+var (
+	codecsOnce sync.Once
+	codecs     map[string]string
+	codecsErr  error
+)
+
+// CanDecode 判断本机 ffmpeg 能否解码指定编码 (ffprobe 的 codec_name)
+// 结果在进程内缓存，只调用一次 ffmpeg -codecs；其能力标记首位为 D 表示支持解码
+func CanDecode(codec string) (bool, error) {
+	codecsOnce.Do(func() {
+		out, err := exec.Command("ffmpeg", "-hide_banner", "-codecs").Output()
+		if err != nil {
+			codecsErr = err
+			return
+		}
+		// -codecs 与 -encoders 的列表格式相同：能力标记、名称、说明
+		codecs = parseEncoders(out)
+	})
+	if codecsErr != nil {
+		return false, codecsErr
+	}
+	return strings.HasPrefix(codecs[codec], "D"), nil
+}
+
+// parseEncoders 解析 ffmpeg -encoders 的输出
+// 列表以 " ------" 分隔行之后开始，每行格式为 " V....D libx265   libx265 H.265 / HEVC"
+func parseEncoders(out []byte) map[string]string {
+	result := make(map[string]string)
+	started := false
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !started {
+			started = strings.HasPrefix(line, "---")
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			result[fields[1]] = fields[0]
+		}
+	}
+	return result
+}
+
+// CheckInput 完整解码一遍输入 (ffmpeg -v error -i <path> -f null -)，发现任何错误输出即视为损坏
+// 有效文件返回 nil；错误信息只保留前几行，完整内容可手动运行同一命令查看
+func CheckInput(path string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostdin", "-v", "error", "-i", path, "-f", "null", "-")
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	msg := strings.TrimSpace(stderr.String())
+	if msg == "" {
+		return runErr
+	}
+	if lines := strings.Split(msg, "\n"); len(lines) > 3 {
+		msg = strings.Join(lines[:3], "; ") + fmt.Sprintf(" ... (共 %d 行)", len(lines))
+	} else {
+		msg = strings.Join(lines, "; ")
+	}
+	return errors.New(msg)
+}
+
+// GetMetadataTags 读取输入中指定的容器级元数据标签，不存在的键不出现在结果中
+func GetMetadataTags(path string, keys []string) (map[string]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	out, err := exec.Command("ffprobe", "-v", "error",
+		"-show_entries", "format_tags="+strings.Join(keys, ","), "-of", "json", path).Output()
+	if err != nil {
+		return nil, err
+	}
+	var probe struct {
+		Format struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, fmt.Errorf("解析元数据失败: %w", err)
+	}
+	return probe.Format.Tags, nil
+}
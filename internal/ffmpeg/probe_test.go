@@ -0,0 +1,86 @@
+package ffmpeg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"video-compress/internal/config"
+)
+
+// fakeFFprobe 让 ffprobe 输出 testdata 中的 fixture，并把收到的参数记录到返回的文件中
+func fakeFFprobe(t *testing.T, fixture string) (argsFile string) {
+	t.Helper()
+	abs, err := filepath.Abs(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	argsFile = filepath.Join(t.TempDir(), "args")
+	fakeTool(t, "ffprobe", `printf '%s\n' "$@" > '`+argsFile+`'
+cat '`+abs+`'
+`)
+	return argsFile
+}
+
+func TestGetMetadataTags(t *testing.T) {
+	argsFile := fakeFFprobe(t, "format_tags.json")
+	tags, err := GetMetadataTags("in.mp4", []string{"creation_time", "title", "artist", "comment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"creation_time": "2024-05-01T08:30:00.000000Z",
+		"title":         "家庭聚会 = 2024",
+		"artist":        `O'Brien "Bob"`,
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := strings.Fields(string(data)); !reflect.DeepEqual(argValues(args, "-show_entries"), []string{"format_tags=creation_time,title,artist,comment"}) {
+		t.Errorf("ffprobe args = %q", args)
+	}
+}
+
+func TestGetMetadataTagsNoKeys(t *testing.T) {
+	// 未指定键时不调用 ffprobe
+	fakeTool(t, "ffprobe", "exit 1\n")
+	if tags, err := GetMetadataTags("in.mp4", nil); tags != nil || err != nil {
+		t.Errorf("GetMetadataTags(nil) = %v, %v", tags, err)
+	}
+}
+
+func TestMetadataArgs(t *testing.T) {
+	in := InputInfo{MetadataTags: map[string]string{
+		"creation_time": "2024-05-01T08:30:00.000000Z",
+		"title":         "家庭聚会 = 2024",
+	}}
+	tests := []struct {
+		keys []string
+		want []string
+	}{
+		{nil, []string{"-map_metadata", "0"}},
+		// 按 --map-metadata-keys 的顺序写回，输入中不存在的键 (artist) 跳过
+		{[]string{"title", "artist", "creation_time"}, []string{"-map_metadata", "-1",
+			"-metadata", "title=家庭聚会 = 2024", "-metadata", "creation_time=2024-05-01T08:30:00.000000Z"}},
+		{[]string{"artist"}, []string{"-map_metadata", "-1"}},
+	}
+	for _, tt := range tests {
+		if got := metadataArgs(config.Config{MetadataKeys: tt.keys}, in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("metadataArgs(%q) = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+
+	args := BuildArgs("in.mp4", "out.mp4", config.Config{Preset: config.PresetStandard, MetadataKeys: []string{"title"}}, in)
+	if got := argValues(args, "-map_metadata"); !reflect.DeepEqual(got, []string{"-1"}) {
+		t.Errorf("BuildArgs -map_metadata = %q, want [-1]", got)
+	}
+	if got := argValues(args, "-metadata"); !reflect.DeepEqual(got, []string{"title=家庭聚会 = 2024"}) {
+		t.Errorf("BuildArgs -metadata = %q", got)
+	}
+}
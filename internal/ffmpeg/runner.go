@@ -3,98 +3,708 @@ package ffmpeg
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 	"video-compress/internal/config"
+	"video-compress/internal/utils"
+)
+
+const (
+	// AudioOnlyCodec/AudioOnlyBitrate 用于纯音频输入的编码
+	AudioOnlyCodec   = "libopus"
+	AudioOnlyBitrate = "128k" // 声道数未知时的默认码率
+	// AudioOnlyExt 是纯音频输出的扩展名 (Ogg Opus)
+	AudioOnlyExt = ".opus"
 
-	"github.com/schollz/progressbar/v3"
+	// LegacyAudioCodec 用于旧容器 (wmv/avi 等) 迁移到 MP4 时的音频转码
+	LegacyAudioCodec = "aac"
 )
 
+// InputInfo 是单个输入文件的探测结果，BuildArgs 据此按文件调整参数
+type InputInfo struct {
+	AudioOnly      bool     // 不含视频流 (封面图除外)
+	VideoStream    int      // 要编码的视频流绝对序号，-1 表示交给 ffmpeg 默认选择
+	Width, Height  int      // 所选视频流的分辨率，未知时为 0
+	PixFmt         string   // 所选视频流的像素格式，未知时为空
+	SAR            float64  // 所选视频流的像素宽高比，0 表示未知 (按方形像素处理)
+	BitDepth       int      // 所选视频流的位深，未知时为 0
+	TranscodeAudio bool     // 音频无法流复制 (旧容器的 WMA 等)，需转码为 LegacyAudioCodec
+	AudioChannels  int      // 第一条音频流的声道数，未知时为 0
+	SubtitleCodecs []string // 字幕流编码，仅在 KeepSubtitles 时探测
+	BurnSubtitles  string   // 要烧录进画面的外挂字幕文件 (.srt/.ass)，为空表示不烧录
+	Spherical      bool     // 携带 360°/全景元数据
+	DataStreams    []string // 数据流的编码标签 (如 gpmd)，仅在 KeepDataStreams 时探测
+	ReadRate       float64  // 输入读取速率 (相对实时播放的倍数，-readrate)，0 表示不限制
+
+	MetadataTags map[string]string // --map-metadata-keys 选中的容器级标签值，仅在指定该选项时探测
+
+	Rotation int // 需要顺时针旋转的角度 (0/90/180/270)，仅在 AutoRotate 时探测
+
+	// 所选视频流的色彩属性 (ffprobe 的 color_transfer / color_primaries)，仅在 --tonemap 时探测
+	ColorTransfer  string
+	ColorPrimaries string
+}
+
+// imageSubtitleCodecs 是基于图像的字幕格式，无法转换为 mov_text 等文本格式
+var imageSubtitleCodecs = []string{"hdmv_pgs_subtitle", "dvd_subtitle", "dvb_subtitle", "xsub"}
+
+// pixFmtDepthRe 匹配像素格式名末尾的位深，如 yuv420p10le、p010le
+var pixFmtDepthRe = regexp.MustCompile(`(\d+)[lb]e$`)
+
+// PixFmtBitDepth 根据像素格式名推断每分量位深，无法识别时返回 0
+func PixFmtBitDepth(pixFmt string) int {
+	if pixFmt == "" || pixFmt == "unknown" {
+		return 0
+	}
+	m := pixFmtDepthRe.FindStringSubmatch(pixFmt)
+	if m == nil {
+		return 8 // yuv420p、nv12 等未标注位深的格式均为 8-bit
+	}
+	n, _ := strconv.Atoi(m[1])
+	if n > 16 {
+		// rgb48le、rgba64le 等按整像素计位，折算为每分量 16-bit
+		return 16
+	}
+	return n
+}
+
+// outputBitDepth 决定输出位深 (8 或 10)，返回 0 表示沿用预设默认值
+// --bit-depth 优先；否则在 ColorDepthPassthrough 时跟随源文件，避免把 8-bit 源无谓地扩展为 10-bit
+func outputBitDepth(cfg config.Config, in InputInfo) int {
+	if cfg.BitDepth > 0 {
+		return cfg.BitDepth
+	}
+	if !cfg.ColorDepthPassthrough || in.BitDepth == 0 {
+		return 0
+	}
+	if in.BitDepth > 8 {
+		return 10
+	}
+	return 8
+}
+
+// encoderPixFmts 是 videotoolbox 可直接接收的像素格式，其他格式需先经 format 滤镜转换
+var encoderPixFmts = []string{"nv12", "p010le", "yuv420p", "yuv420p10le", "videotoolbox_vld"}
+
+// hardwarePixelFilter 返回源像素格式不被 videotoolbox 接受时的转换滤镜，无需转换时返回空
+// 旧容器中常见 yuv410p、pal8 等格式，直接送入编码器会失败
+func hardwarePixelFilter(depth int, in InputInfo) string {
+	if in.PixFmt == "" || slices.Contains(encoderPixFmts, in.PixFmt) {
+		return ""
+	}
+	if depth == 10 || (depth == 0 && in.BitDepth > 8) {
+		return "format=p010le"
+	}
+	return "format=nv12"
+}
+
+// hardwarePixelArgs 返回 videotoolbox 编码的 profile 与像素格式
+func hardwarePixelArgs(depth int) []string {
+	if depth == 8 {
+		return []string{"-profile:v", "main", "-tag:v", "hvc1", "-pix_fmt", "nv12"}
+	}
+	return []string{"-profile:v", "main10", "-tag:v", "hvc1", "-pix_fmt", "p010le"}
+}
+
+// softwarePixelFilter 返回 libx265 编码前的像素格式转换滤镜
+func softwarePixelFilter(depth int) string {
+	if depth == 10 {
+		return "format=yuv420p10le"
+	}
+	return "format=yuv420p"
+}
+
+// IsMP4Family 判断输出容器是否属于 MP4/MOV 系列 (只支持 mov_text 文本字幕)
+func IsMP4Family(outputFile string) bool {
+	switch strings.ToLower(filepath.Ext(outputFile)) {
+	case ".mp4", ".m4v", ".mov":
+		return true
+	}
+	return false
+}
+
+// ImageSubtitles 返回输入中无法转为文本格式的图像字幕编码
+func (in InputInfo) ImageSubtitles() []string {
+	var found []string
+	for _, c := range in.SubtitleCodecs {
+		if slices.Contains(imageSubtitleCodecs, c) {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
 // BuildArgs 构建 FFmpeg 参数
-func BuildArgs(inputFile, outputFile string, cfg config.Config) []string {
+func BuildArgs(inputFile, outputFile string, cfg config.Config, in InputInfo) []string {
 	// 1. 基础参数
 	args := []string{"-y"}
 
+	// 纯音频输入：不涉及视频编码，直接转码音频
+	if in.AudioOnly {
+		args = append(args, readRateArgs(in)...)
+		args = append(args, inputFormatArgs(cfg)...)
+		args = append(args,
+			"-i", inputFile,
+			"-progress", "pipe:1", "-nostats", "-hide_banner",
+		)
+		args = append(args, statsPeriodArgs(cfg)...)
+		args = append(args, metadataArgs(cfg, in)...)
+		codec, bitrate := AudioPlan(cfg, in)
+		args = append(args, "-vn", "-c:a", codec, "-b:a", bitrate)
+		args = append(args, audioChannelArgs(cfg, in)...)
+		args = append(args, sampleRateArgs(cfg, in, codec)...)
+		if chain := audioFilterChain(cfg, in); chain != "" {
+			args = append(args, "-af", chain)
+		}
+		if cfg.SplitEvery > 0 {
+			args = append(args, "-f", "segment", "-segment_time", splitSeconds(cfg), "-reset_timestamps", "1")
+		}
+		args = append(args, outputFile)
+		return args
+	}
+
 	// 2. 硬件加速策略
 	// 尝试启用 videotoolbox 硬件解码。
 	// 注意：对于某些损坏严重的视频，FFmpeg 可能会自动回退到 h264(native) 软件解码，
 	// 因此后续的滤镜链必须能同时处理硬件和软件两种输出。
-	args = append(args, "-hwaccel", "videotoolbox")
+	args = append(args, "-hwaccel", HWAccel)
+	args = append(args, buildHWAccelDeviceArgs(HWAccel, cfg.HWAccelDevice)...)
 
 	// 3. 通用输入参数
+	args = append(args, readRateArgs(in)...)
+	args = append(args, inputFormatArgs(cfg)...)
+	rotate := cfg.AutoRotate && in.Rotation != 0
+	if rotate {
+		// 由下面的 transpose 显式旋转，关闭 ffmpeg 的自动旋转以免转两次
+		args = append(args, "-noautorotate")
+	}
 	args = append(args,
 		"-i", inputFile,
 		"-progress", "pipe:1", "-nostats", "-hide_banner",
-		"-map_metadata", "0", "-movflags", "+faststart",
+	)
+	args = append(args, statsPeriodArgs(cfg)...)
+	args = append(args, metadataArgs(cfg, in)...)
+	if rotate {
+		// 画面已经转正，清除旋转标记，否则遵循标记的播放器会再旋转一次
+		args = append(args, "-metadata:s:v", "rotate=")
+	}
+	args = append(args,
 		"-ignore_unknown",           // 忽略无效流
 		"-err_detect", "ignore_err", // [新增] 遇到数据损坏时尝试继续，而不是立即崩溃
 	)
 
-	// 计算质量参数
-	qValue := "50"
-	if cfg.Quality > 0 {
-		qValue = strconv.Itoa(cfg.Quality)
-	} else if cfg.Preset == config.PresetLow {
-		qValue = "40"
-	} else if cfg.Preset == config.PresetStandard {
-		qValue = "50"
-	}
-
-	// 4. 视频编码配置
-	switch cfg.Preset {
-	case config.PresetHigh:
-		// [High 模式] 混合流水线 (兼容模式)
-		crf := "24"
-		if cfg.Quality > 0 {
-			mappedCRF := 51 - (cfg.Quality / 2)
-			if mappedCRF < 0 {
-				mappedCRF = 0
-			}
-			crf = strconv.Itoa(mappedCRF)
+	// 4. 视频编码配置 (编码器、质量与预设参数见 presets.go)
+	// postFilters 为叠加水印等处理之后、送入编码器之前的滤镜
+	// x265Params 汇总各处需要的 -x265-params，最后合并为一个参数
+	var postFilters, x265Params []string
+	depth := outputBitDepth(cfg, in)
+	preset := ResolvePreset(cfg)
+	args = append(args, "-c:v", preset.Encoder)
+	if name, q := NativeQuality(cfg); q > 0 || cfg.Quality > 0 {
+		args = append(args, "-"+name, strconv.Itoa(q))
+	}
+	args = append(args, preset.Args...)
+	x265Params = append(x265Params, preset.X265Params...)
+	switch preset.Pixels {
+	case PixelsHardware:
+		args = append(args, hardwarePixelArgs(depth)...)
+		if f := hardwarePixelFilter(depth, in); f != "" {
+			postFilters = append(postFilters, f)
 		}
+	case PixelsSoftware:
+		// 不使用 hwdownload，仅使用 format=yuv420p：
+		// 硬件解码失败回退到软件解码 (nv12) 时，显式的 hwdownload 会导致崩溃；
+		// format 滤镜对硬件流会自动插入下载步骤，对软件流直接转换格式
+		postFilters = append(postFilters, softwarePixelFilter(depth))
+	case PixelsSoftware10:
+		d := 10
+		if cfg.BitDepth == 8 {
+			d = 8
+		}
+		postFilters = append(postFilters, softwarePixelFilter(d))
+	}
+
+	// 码率上限：避免高质量重编码后的码率反而超过输入 (VBV 缓冲取上限的两倍)
+	if cfg.MaxBitrateKbps > 0 {
+		args = append(args, "-maxrate", fmt.Sprintf("%dk", cfg.MaxBitrateKbps), "-bufsize", fmt.Sprintf("%dk", cfg.MaxBitrateKbps*2))
+	}
+
+	// 线程限制：多 worker 并发时避免每个 ffmpeg 都占满所有 CPU
+	if cfg.FFmpegThreads > 0 {
+		args = append(args, "-threads", strconv.Itoa(cfg.FFmpegThreads))
+		if usesSoftwareEncoder(cfg) {
+			x265Params = append(x265Params, fmt.Sprintf("pools=%d", cfg.FFmpegThreads))
+		}
+	}
+	if len(x265Params) > 0 {
+		args = append(args, "-x265-params", strings.Join(x265Params, ":"))
+	}
+
+	// 缩放：限制最大高度，保持宽高比且不放大 (宽度取偶数以满足编码器要求)
+	// --auto-rotate: 旋转位于缩放之前，缩放按转正后的画面计算 (与 ffmpeg 自动旋转一致)
+	var baseFilters FilterChain
+	if rotate {
+		baseFilters.Add(transposeFilter(in.Rotation))
+	}
+	if cfg.MaxHeight > 0 {
+		baseFilters.Add(fmt.Sprintf("scale=-2:'min(%d,ih)'", cfg.MaxHeight))
+	} else if r, ok := config.LookupResolution(cfg.Resolution); ok {
+		baseFilters.Add(resolutionFilter(r, in.Displayed()))
+	}
+	// --tonemap: HDR 源在缩放之后映射为 SDR (缩放在浮点转换之前进行，开销更小)
+	tonemap := cfg.Tonemap && in.IsHDR()
+	if tonemap {
+		baseFilters.Add(tonemapFilter(in, depth))
+	}
+	// 字幕在缩放之后渲染，字号按输出分辨率计算；subtitles 滤镜会保留 .ass 的样式
+	if in.BurnSubtitles != "" {
+		baseFilters.Add("subtitles=" + escapeFilterValue(in.BurnSubtitles))
+	}
+	// 用户滤镜作用于缩放后的画面，位于水印与像素格式转换之前
+	baseFilters.Add(cfg.VideoFilter)
+
+	if vf := buildVideoFilter(&baseFilters, postFilters, cfg); vf != "" {
+		args = append(args, "-vf", vf)
+	}
+	if tonemap {
+		// 覆盖从输入继承的 HDR 色彩标签，否则播放器仍会按 PQ/HLG 解释画面
+		args = append(args, "-color_primaries", "bt709", "-color_trc", "bt709", "-colorspace", "bt709")
+	}
+
+	// 5. 音频处理
+	codec, bitrate := AudioPlan(cfg, in)
+	args = append(args, "-c:a", codec)
+	if bitrate != "" {
+		args = append(args, "-b:a", bitrate)
+	}
+	if codec != "copy" {
+		args = append(args, audioChannelArgs(cfg, in)...)
+		args = append(args, sampleRateArgs(cfg, in, codec)...)
+	}
+	if chain := audioFilterChain(cfg, in); chain != "" && codec != "copy" {
+		args = append(args, "-af", chain)
+	}
+
+	// mov 复用器仅在 unofficial 兼容级别下才写入 sv3d (Spherical Video V2) box
+	if in.Spherical && IsMP4Family(outputFile) {
+		args = append(args, "-strict", "unofficial")
+	}
+
+	// 6. 流选择与字幕处理
+	args = append(args, streamMapArgs(outputFile, cfg, in)...)
+
+	// 7. 输出
+	if cfg.SplitEvery > 0 {
+		// 按时长切分：outputFile 为 printf 风格的模板 (如 name-%03d.compressed.mp4)
+		// 在切分点强制关键帧，保证每段都能独立播放
+		sec := splitSeconds(cfg)
 		args = append(args,
-			"-c:v", "libx265",
-			"-crf", crf,
-			"-preset", "medium",
-			// [关键修改]
-			// 移除 hwdownload，仅使用 format=yuv420p。
-			// 原因：如果硬件解码失败回退到软件解码(nv12)，显式的 hwdownload 会导致崩溃。
-			// format=yuv420p 更加智能：
-			// 1. 若是硬件流，它会自动插入下载步骤。
-			// 2. 若是软件流，它直接转换格式。
-			"-vf", "format=yuv420p",
-			"-tag:v", "hvc1",
-		)
-	case config.PresetLow:
-		args = append(args,
-			"-c:v", "hevc_videotoolbox", "-q:v", qValue,
-			"-profile:v", "main10", "-tag:v", "hvc1", "-pix_fmt", "p010le",
+			"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%s)", sec),
+			"-f", "segment", "-segment_time", sec, "-reset_timestamps", "1",
+			"-segment_format_options", "movflags=+faststart",
 		)
+	} else {
+		args = append(args, "-movflags", "+faststart")
+	}
+
+	args = append(args, outputFile)
+	return args
+}
+
+// AudioBitrateForChannels 是按声道数选择音频码率的默认策略
+// 单声道语音用 128k 浪费，5.1 用 128k 又太单薄
+func AudioBitrateForChannels(channels int) string {
+	switch {
+	case channels <= 0:
+		return AudioOnlyBitrate
+	case channels == 1:
+		return "64k"
+	case channels == 2:
+		return "128k"
+	case channels <= 6:
+		return "256k"
 	default:
-		// Standard 模式
-		args = append(args,
-			"-c:v", "hevc_videotoolbox", "-q:v", qValue,
-			"-profile:v", "main10", "-tag:v", "hvc1", "-pix_fmt", "p010le",
-		)
+		return "384k"
 	}
+}
 
-	// 5. 音频处理
-	// 统一使用流复制，避免解码错误并保持原音质
-	args = append(args, "-c:a", "copy")
+// audioBitrate 决定转码音频时的码率：--audio-bitrate > 自定义预设的 audio_bitrate > 按声道数的默认策略
+func audioBitrate(cfg config.Config, in InputInfo) string {
+	if cfg.AudioBitrate != "" {
+		return cfg.AudioBitrate
+	}
+	if p := ResolvePreset(cfg); p.AudioBitrate != "" {
+		return p.AudioBitrate
+	}
+	if ch := TargetAudioChannels(cfg, in); ch > 0 {
+		return AudioBitrateForChannels(ch)
+	}
+	return AudioBitrateForChannels(in.AudioChannels)
+}
 
-	args = append(args, outputFile)
+// TargetAudioChannels 返回需要输出的声道数，无需改变时返回 0
+// 输入声道数与目标相同时不改变；--audio-channels-auto 时只在输入声道更多 (且已知) 时降混
+func TargetAudioChannels(cfg config.Config, in InputInfo) int {
+	if cfg.AudioChannels <= 0 || in.AudioChannels == cfg.AudioChannels {
+		return 0
+	}
+	if cfg.AudioChannelsAuto && in.AudioChannels <= cfg.AudioChannels {
+		return 0
+	}
+	return cfg.AudioChannels
+}
+
+// videoSampleRate 是 --audio-sample-rate-auto 时视频任务使用的采样率 (广播标准)
+const videoSampleRate = 48000
+
+// opusSampleRates 是 libopus 支持的采样率，其他采样率由编码器内部重采样
+var opusSampleRates = []int{8000, 12000, 16000, 24000, 48000}
+
+// TargetSampleRate 返回需要输出的采样率，保持原采样率时返回 0
+func TargetSampleRate(cfg config.Config, in InputInfo) int {
+	if cfg.AudioSampleRate > 0 {
+		return cfg.AudioSampleRate
+	}
+	if cfg.AudioSampleRateAuto && !in.AudioOnly {
+		return videoSampleRate
+	}
+	return 0
+}
+
+// sampleRateArgs 构建重采样参数，流复制时无法重采样
+// libopus 不接受 44.1kHz 等采样率，此时省略 -ar 交给编码器处理 (Opus 内部固定为 48kHz)
+func sampleRateArgs(cfg config.Config, in InputInfo, codec string) []string {
+	rate := TargetSampleRate(cfg, in)
+	if rate == 0 || codec == "copy" || (codec == AudioOnlyCodec && !slices.Contains(opusSampleRates, rate)) {
+		return nil
+	}
+	return []string{"-ar", strconv.Itoa(rate)}
+}
+
+// surroundDownmix 是 5.1 降混为立体声的矩阵 (兼容 Dolby Pro Logic)：中置 -3dB，环绕 -6dB，丢弃 LFE
+const surroundDownmix = "pan=stereo|c0=c0+0.7*c2+0.5*c4|c1=c1+0.7*c2+0.5*c5"
+
+// audioOnlyExts 是 --audio-codec 指定的编码对应的纯音频输出扩展名，未列出的编码使用 Matroska 音频 (.mka)
+var audioOnlyExts = map[string]string{
+	"libopus":    AudioOnlyExt,
+	"opus":       AudioOnlyExt,
+	"aac":        ".m4a",
+	"aac_at":     ".m4a",
+	"libfdk_aac": ".m4a",
+	"alac":       ".m4a",
+	"libmp3lame": ".mp3",
+	"flac":       ".flac",
+}
+
+// AudioOnlyOutputExt 返回纯音频输出的扩展名：默认 Opus 为 .opus，--audio-codec 改变编码时换用能容纳该编码的容器
+func AudioOnlyOutputExt(cfg config.Config) string {
+	codec, _ := AudioPlan(cfg, InputInfo{AudioOnly: true})
+	if ext, ok := audioOnlyExts[codec]; ok {
+		return ext
+	}
+	return ".mka"
+}
+
+// downmixFilter 返回 5.1 降混为立体声时的滤镜，其他情况由 -ac 使用 ffmpeg 的默认矩阵
+func downmixFilter(cfg config.Config, in InputInfo) string {
+	if in.AudioChannels == 6 && TargetAudioChannels(cfg, in) == 2 {
+		return surroundDownmix
+	}
+	return ""
+}
+
+// audioChannelArgs 构建改变声道数的参数
+func audioChannelArgs(cfg config.Config, in InputInfo) []string {
+	if ch := TargetAudioChannels(cfg, in); ch > 0 {
+		return []string{"-ac", strconv.Itoa(ch)}
+	}
+	return nil
+}
+
+// AudioPlan 返回任务的音频编码器与码率 (流复制时码率为空)
+// 音频设置独立于预设的视频部分：--copy-audio / --audio-codec / --audio-bitrate 只覆盖音频
+// 默认流复制，避免解码错误并保持原音质；以下情况转码：
+// 自定义预设指定了音频编码、旧容器的 WMA 等音频无法放入 MP4、或显式指定了 --audio-bitrate / --audio-filter、
+// 或需要改变声道数 (--audio-channels) / 采样率 (--audio-sample-rate)
+func AudioPlan(cfg config.Config, in InputInfo) (codec, bitrate string) {
+	if in.AudioOnly {
+		// 纯音频任务本身就是转码，--copy-audio 不适用
+		if cfg.AudioCodec != "" {
+			return cfg.AudioCodec, audioBitrate(cfg, in)
+		}
+		return AudioOnlyCodec, audioBitrate(cfg, in)
+	}
+	if cfg.CopyAudio {
+		return "copy", ""
+	}
+	if cfg.AudioCodec != "" {
+		return cfg.AudioCodec, audioBitrate(cfg, in)
+	}
+	if p := ResolvePreset(cfg); p.AudioCodec != "" {
+		return p.AudioCodec, audioBitrate(cfg, in)
+	}
+	if in.TranscodeAudio || cfg.AudioBitrate != "" || cfg.AudioFilter != "" || TargetAudioChannels(cfg, in) > 0 || cfg.AudioSampleRate > 0 {
+		return LegacyAudioCodec, audioBitrate(cfg, in)
+	}
+	return "copy", ""
+}
+
+// readRateArgs 构建输入读取限速参数 (-readrate 需位于 -i 之前，FFmpeg 5.0+)
+func readRateArgs(in InputInfo) []string {
+	if in.ReadRate <= 0 {
+		return nil
+	}
+	return []string{"-readrate", strconv.FormatFloat(in.ReadRate, 'f', 2, 64)}
+}
+
+// metadataArgs 构建元数据参数：默认复制全部元数据；指定 --map-metadata-keys 时不复制，
+// 只按顺序写回选中且输入中存在的标签
+func metadataArgs(cfg config.Config, in InputInfo) []string {
+	if len(cfg.MetadataKeys) == 0 {
+		return []string{"-map_metadata", "0"}
+	}
+	args := []string{"-map_metadata", "-1"}
+	for _, key := range cfg.MetadataKeys {
+		if v, ok := in.MetadataTags[key]; ok {
+			args = append(args, "-metadata", key+"="+v)
+		}
+	}
+	return args
+}
+
+// inputFormatArgs 构建强制输入格式参数，跳过 ffmpeg 的容器自动识别
+func inputFormatArgs(cfg config.Config) []string {
+	if cfg.InputFormat == "" {
+		return nil
+	}
+	return []string{"-f", cfg.InputFormat}
+}
+
+// audioFilterChain 合并所有音频滤镜为一条 -af 链 (以逗号连接)
+// ffmpeg 对同一输出流只采用最后一个 -af，分开传入会静默丢弃前面的滤镜
+// 降混位于用户滤镜之前，用户滤镜处理的是输出声道
+func audioFilterChain(cfg config.Config, in InputInfo) string {
+	var filters []string
+	if f := downmixFilter(cfg, in); f != "" {
+		filters = append(filters, f)
+	}
+	if cfg.AudioFilter != "" {
+		filters = append(filters, cfg.AudioFilter)
+	}
+	return strings.Join(filters, ",")
+}
+
+// streamMapArgs 构建流映射参数
+// 显式映射主视频流 (排除封面图)，避免封面被当作视频编码；一旦使用 -map，音频 (以及要保留的数据流) 也需显式映射
+func streamMapArgs(outputFile string, cfg config.Config, in InputInfo) []string {
+	keepData := cfg.KeepDataStreams && len(in.DataStreams) > 0 && IsMP4Family(outputFile)
+	if in.VideoStream < 0 && !cfg.KeepSubtitles && !keepData {
+		return nil
+	}
+
+	video := "0:v:0"
+	if in.VideoStream >= 0 {
+		video = fmt.Sprintf("0:%d", in.VideoStream)
+	}
+	args := []string{"-map", video, "-map", "0:a?"}
+	if cfg.KeepSubtitles {
+		args = append(args, subtitleArgs(outputFile, cfg, in)...)
+	}
+	if keepData {
+		// mov 复用器按编码标签写出 gpmd/tmcd 等数据轨，其他容器通常不支持
+		args = append(args, "-map", "0:d?", "-c:d", "copy")
+	}
 	return args
 }
 
-// Run 执行 FFmpeg 命令并更新进度条 (保持不变)
-func Run(cmdArgs []string, globalBar *progressbar.ProgressBar) error {
+// subtitleArgs 构建保留字幕所需的映射与编码参数
+// MP4/MOV 只能容纳文本字幕，需转码为 SubtitleFormat；若输入含图像字幕则无法转换，放弃字幕以免整个任务失败
+func subtitleArgs(outputFile string, cfg config.Config, in InputInfo) []string {
+	codec := "copy"
+	if IsMP4Family(outputFile) {
+		if cfg.SubtitleFormat == "mov_text" && len(in.ImageSubtitles()) > 0 {
+			return []string{"-sn"}
+		}
+		if cfg.SubtitleFormat != "" {
+			codec = cfg.SubtitleFormat
+		}
+	}
+	return []string{"-map", "0:s?", "-c:s", codec}
+}
+
+func splitSeconds(cfg config.Config) string {
+	return strconv.FormatFloat(cfg.SplitEvery.Seconds(), 'f', -1, 64)
+}
+
+// SegmentOutputs 返回按模板切分后实际生成的文件 (按序号排序)
+func SegmentOutputs(pattern string) []string {
+	parts := strings.Split(filepath.Base(pattern), "%03d")
+	for i, p := range parts {
+		parts[i] = unescapeTemplate(p)
+	}
+	glob := strings.Join(parts, "[0-9][0-9][0-9]")
+	matches, _ := filepath.Glob(filepath.Join(OutputDir(pattern, true), glob))
+	sort.Strings(matches)
+	return matches
+}
+
+// EscapeTemplate 转义 s 中的 %，使其在切分模板中按字面使用 (ffmpeg 将 %% 解析为 %)
+func EscapeTemplate(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+func unescapeTemplate(s string) string {
+	return strings.ReplaceAll(s, "%%", "%")
+}
+
+// OutputDir 返回输出文件所在的目录；split 时 outputFile 为切分模板，还原其中转义的 %
+func OutputDir(outputFile string, split bool) string {
+	dir := filepath.Dir(outputFile)
+	if split {
+		dir = unescapeTemplate(dir)
+	}
+	return dir
+}
+
+// UsesHardwareEncoder 判断当前预设是否使用 videotoolbox 硬件编码
+func UsesHardwareEncoder(cfg config.Config) bool {
+	return VideoEncoder(cfg) == HardwareEncoder
+}
+
+// ExceedsHardwareLimit 判断该输入的输出尺寸是否超出 cfg.HWMaxResolution (仅对硬件编码预设检查)
+// 返回估算的输出尺寸；输入分辨率未知时不视为超出
+func ExceedsHardwareLimit(cfg config.Config, in InputInfo) (w, h int, exceeded bool) {
+	limit := cfg.HWMaxResolution
+	if limit.Long <= 0 || in.AudioOnly || !UsesHardwareEncoder(cfg) {
+		return 0, 0, false
+	}
+	w, h = OutputDimensions(cfg, in)
+	if w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+	return w, h, max(w, h) > limit.Long || min(w, h) > limit.Short
+}
+
+// VideoEncoder 返回当前预设使用的视频编码器
+func VideoEncoder(cfg config.Config) string {
+	return ResolvePreset(cfg).Encoder
+}
+
+// TargetCodec 返回当前预设输出的视频编码名称 (与 ffprobe 的 codec_name 一致)，如 hevc
+func TargetCodec(cfg config.Config) string {
+	switch c := VideoEncoder(cfg); {
+	case c == SoftwareEncoder || strings.HasPrefix(c, "hevc"):
+		return "hevc"
+	case c == "libx264" || strings.HasPrefix(c, "h264"):
+		return "h264"
+	case strings.Contains(c, "av1"):
+		return "av1"
+	case strings.Contains(c, "vp9"):
+		return "vp9"
+	default:
+		return c
+	}
+}
+
+// SoftwareFallback 返回硬件编码失败后改用 libx265 重试的配置 (即 high 预设)
+// 软件编码路径会先转换为 yuv420p，能处理 videotoolbox 拒绝的少见像素格式
+func SoftwareFallback(cfg config.Config) config.Config {
+	cfg.Preset = config.PresetHigh
+	return cfg
+}
+
+// usesSoftwareEncoder 判断当前预设是否使用 libx265 软件编码
+func usesSoftwareEncoder(cfg config.Config) bool {
+	return VideoEncoder(cfg) == SoftwareEncoder
+}
+
+// NativeQuality 返回当前预设下编码器实际使用的质量参数名及其数值
+// libx265 使用 -crf (0-51, 越小画质越高)，videotoolbox 使用 -q:v (1-100, 越大画质越高)
+// 自定义预设的默认值取自其 quality 字段，0 表示不传质量参数
+func NativeQuality(cfg config.Config) (string, int) {
+	p := ResolvePreset(cfg)
+	if cfg.Quality <= 0 {
+		return p.QualityFlag, p.DefaultQuality
+	}
+	return p.QualityFlag, p.MapQuality(cfg.Quality)
+}
+
+// QualityWarning 检查 --quality 映射后的原生参数是否处于异常区间
+// 返回空字符串表示无需提醒
+func QualityWarning(cfg config.Config) string {
+	if cfg.Quality <= 0 {
+		return ""
+	}
+	name, v := NativeQuality(cfg)
+	switch name {
+	case "crf":
+		lossless, degraded := 12, 40
+		if cfg.Preset == config.PresetArchive {
+			lossless, degraded = 15, 50 // AV1 的 CRF 刻度比 x265 更宽
+		}
+		if v <= lossless {
+			return fmt.Sprintf("--quality %d 映射为 CRF %d，接近无损，输出文件可能比原文件还大", cfg.Quality, v)
+		}
+		if v >= degraded {
+			return fmt.Sprintf("--quality %d 映射为 CRF %d，画质将严重劣化", cfg.Quality, v)
+		}
+	case "q:v":
+		if v >= 85 {
+			return fmt.Sprintf("--quality %d 即 q:v %d，码率极高，输出文件可能比原文件还大", cfg.Quality, v)
+		}
+		if v <= 20 {
+			return fmt.Sprintf("--quality %d 即 q:v %d，画质将严重劣化", cfg.Quality, v)
+		}
+	}
+	return ""
+}
+
+// Progress 是 ffmpeg -progress 输出中一个进度块的快照
+type Progress struct {
+	OutTimeUs int64 // 已输出的媒体时长 (微秒)
+	TotalSize int64 // 已写出的字节数
+}
+
+// DefaultScannerBufferBytes 是进度输出单行允许的默认最大长度
+const DefaultScannerBufferBytes = 1 << 20
+
+// RunOptions 控制 Run 的行为
+type RunOptions struct {
+	ScannerBufferBytes int            // 进度输出单行的最大长度，0 表示使用默认值
+	OnProgress         func(Progress) // 每解析到一个完整的进度块调用一次
+	StderrTailBytes    int            // 失败时保留的标准错误末尾字节数，0 表示使用 DefaultStderrTailBytes
+	CPUs               []int          // ffmpeg 绑定的 CPU 核心，为空表示不绑定 (不支持的平台忽略)
+
+	// 输出体积上限：每 OutputPollInterval 调用一次 OutputSize，超过 MaxOutputBytes 时终止 ffmpeg
+	MaxOutputBytes int64
+	OutputSize     func() int64
+}
+
+// OutputPollInterval 是检查输出体积的间隔
+const OutputPollInterval = 5 * time.Second
+
+// ErrOutputTooLarge 表示输出体积超过 RunOptions.MaxOutputBytes，ffmpeg 已被终止
+var ErrOutputTooLarge = errors.New("output exceeded max size")
+
+// Run 执行 FFmpeg 命令并回调进度
+func Run(cmdArgs []string, opts RunOptions) error {
 	cmd := exec.Command("ffmpeg", cmdArgs...)
 
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	// 只保留标准错误的末尾，损坏的文件刷出大量警告时内存占用依然有界
+	stderr := NewTailBuffer(opts.StderrTailBytes)
+	cmd.Stderr = stderr
 
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -103,27 +713,143 @@ func Run(cmdArgs []string, globalBar *progressbar.ProgressBar) error {
 	if err := cmd.Start(); err != nil {
 		return err
 	}
+	if len(opts.CPUs) > 0 {
+		// 尽早设置，ffmpeg 随后创建的编码线程都会继承；失败时照常编码
+		_ = utils.SetAffinity(cmd.Process.Pid, opts.CPUs)
+	}
 
+	var tooLarge atomic.Bool
+	if opts.MaxOutputBytes > 0 && opts.OutputSize != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			ticker := time.NewTicker(OutputPollInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					if opts.OutputSize() > opts.MaxOutputBytes {
+						tooLarge.Store(true)
+						_ = cmd.Process.Kill()
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	// 部分平台上流较多时单行进度可能超过 bufio 默认的 64KB 上限
+	bufSize := opts.ScannerBufferBytes
+	if bufSize <= 0 {
+		bufSize = DefaultScannerBufferBytes
+	}
 	scanner := bufio.NewScanner(stdoutPipe)
-	var lastTimeUs int64 = 0
+	scanner.Buffer(make([]byte, 0, 64*1024), bufSize)
+	var cur Progress
 
 	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "out_time_us=") {
-			usStr := strings.TrimPrefix(line, "out_time_us=")
-			currentUs, _ := strconv.ParseInt(usStr, 10, 64)
-
-			if currentUs > lastTimeUs {
-				delta := currentUs - lastTimeUs
-				_ = globalBar.Add64(delta)
-				lastTimeUs = currentUs
+		// 直接在扫描缓冲区上匹配，不为每一行分配字符串 (并发任务多时进度输出量可观)
+		line := scanner.Bytes()
+		switch {
+		case bytes.HasPrefix(line, outTimeKey):
+			if us, ok := parseProgressInt(line[len(outTimeKey):]); ok {
+				cur.OutTimeUs = us
+			}
+		case bytes.HasPrefix(line, totalSizeKey):
+			if n, ok := parseProgressInt(line[len(totalSizeKey):]); ok {
+				cur.TotalSize = n
+			}
+		case bytes.HasPrefix(line, progressKey):
+			// 每个进度块以 progress=continue/end 结尾
+			if opts.OnProgress != nil {
+				opts.OnProgress(cur)
 			}
 		}
 	}
+	// 扫描中途出错 (如行过长) 时继续读空管道，防止 ffmpeg 因管道写满而阻塞
+	if scanner.Err() != nil {
+		_, _ = io.Copy(io.Discard, stdoutPipe)
+	}
 
 	if err := cmd.Wait(); err != nil {
+		if tooLarge.Load() {
+			return ErrOutputTooLarge
+		}
 		fmt.Fprintf(os.Stderr, "\n\n❌ FFmpeg 运行错误日志:\n%s\n", stderr.String())
-		return err
+		return &RunError{Err: err, Stderr: stderr.String()}
 	}
 	return nil
 }
+
+// -progress 输出中用到的键
+var (
+	outTimeKey   = []byte("out_time_us=")
+	totalSizeKey = []byte("total_size=")
+	progressKey  = []byte("progress=")
+)
+
+// parseProgressInt 解析 -progress 输出中的整数值，N/A 等非数字返回 false
+func parseProgressInt(b []byte) (int64, bool) {
+	neg := len(b) > 0 && b[0] == '-'
+	if neg {
+		b = b[1:]
+	}
+	if len(b) == 0 || len(b) > 18 {
+		return 0, false
+	}
+	var n int64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, true
+}
+
+// statsPeriodArgs 设置 ffmpeg 输出进度的间隔 (-stats_period 同时作用于 -progress)，0 表示使用 ffmpeg 默认值 (0.5s)
+func statsPeriodArgs(cfg config.Config) []string {
+	if cfg.StatsPeriod <= 0 {
+		return nil
+	}
+	return []string{"-stats_period", strconv.FormatFloat(cfg.StatsPeriod.Seconds(), 'f', -1, 64)}
+}
+
+// RunError 表示 ffmpeg 以非零状态退出，附带捕获到的 stderr 以便诊断
+type RunError struct {
+	Err    error
+	Stderr string
+}
+
+func (e *RunError) Error() string { return e.Err.Error() }
+func (e *RunError) Unwrap() error { return e.Err }
+
+// IsNoSpace 判断失败是否因为输出磁盘空间不足 (ENOSPC)
+func IsNoSpace(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, syscall.ENOSPC) || strings.Contains(stderrOf(err), "No space left on device")
+}
+
+// hardwareLimitRe 匹配 videotoolbox 因尺寸或 level 超出上限而无法创建编码会话时的错误输出
+var hardwareLimitRe = regexp.MustCompile(`(?i)cannot create compression session|kVTParameterErr|-12902|-12908|level .*(not supported|exceed)`)
+
+// IsHardwareLimit 判断硬件编码失败是否因为画面尺寸或 level 超出编码器上限
+func IsHardwareLimit(err error) bool {
+	return err != nil && hardwareLimitRe.MatchString(stderrOf(err))
+}
+
+// stderrOf 提取 err 中捕获的 ffmpeg stderr，不是 RunError 时返回空字符串
+func stderrOf(err error) string {
+	var runErr *RunError
+	if errors.As(err, &runErr) {
+		return runErr.Stderr
+	}
+	return ""
+}
@@ -0,0 +1,229 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"video-compress/internal/config"
+)
+
+// fakeFFmpeg 在临时目录中放一个名为 ffmpeg 的 shell 脚本并将其置于 PATH 最前，Run 执行的即是该脚本
+func fakeFFmpeg(t *testing.T, script string) {
+	t.Helper()
+	fakeTool(t, "ffmpeg", script)
+}
+
+// fakeTool 同 fakeFFmpeg，用于 ffprobe 等其他命令
+func fakeTool(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake " + name + " is a shell script")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// 进度块中夹杂超过 bufio 默认 64KiB 上限的长行 (流很多时的 stream_*_q 等) 时进度仍能正常解析
+func TestRunLongProgressLines(t *testing.T) {
+	fakeFFmpeg(t, `
+printf 'out_time_us=1000000\n'
+printf 'stream_0_0_q='; head -c 200000 /dev/zero | tr '\0' 'x'; printf '\n'
+printf 'total_size=2048\nprogress=continue\n'
+printf 'out_time_us=2000000\n'
+printf 'stream_0_0_q='; head -c 200000 /dev/zero | tr '\0' 'x'; printf '\n'
+printf 'total_size=4096\nprogress=end\n'
+`)
+	var got []Progress
+	err := Run(nil, RunOptions{OnProgress: func(p Progress) { got = append(got, p) }})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []Progress{{OutTimeUs: 1000000, TotalSize: 2048}, {OutTimeUs: 2000000, TotalSize: 4096}}
+	if len(got) != len(want) {
+		t.Fatalf("progress = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("progress[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// 单行超过 ScannerBufferBytes 时停止解析进度，但仍读空管道，ffmpeg 不会因写满管道而卡住
+func TestRunLineExceedsBuffer(t *testing.T) {
+	fakeFFmpeg(t, `
+printf 'out_time_us=1000000\nprogress=continue\n'
+printf 'stream_0_0_q='; head -c 1000000 /dev/zero | tr '\0' 'x'; printf '\n'
+printf 'out_time_us=2000000\nprogress=end\n'
+`)
+	var got []Progress
+	err := Run(nil, RunOptions{
+		ScannerBufferBytes: 64 << 10,
+		OnProgress:         func(p Progress) { got = append(got, p) },
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(got) != 1 || got[0].OutTimeUs != 1000000 {
+		t.Fatalf("progress = %+v, want only the block before the long line", got)
+	}
+}
+
+func TestAudioBitrateForChannels(t *testing.T) {
+	tests := []struct {
+		channels int
+		want     string
+	}{
+		{0, AudioOnlyBitrate},
+		{1, "64k"},
+		{2, "128k"},
+		{3, "256k"},
+		{6, "256k"},
+		{8, "384k"},
+	}
+	for _, tt := range tests {
+		if got := AudioBitrateForChannels(tt.channels); got != tt.want {
+			t.Errorf("AudioBitrateForChannels(%d) = %q, want %q", tt.channels, got, tt.want)
+		}
+	}
+}
+
+func TestAudioPlan(t *testing.T) {
+	voice := map[string]config.PresetDefinition{
+		"voice": {Name: "voice", Codec: SoftwareEncoder, AudioCodec: "libopus", AudioBitrate: "48k"},
+	}
+	tests := []struct {
+		name        string
+		cfg         config.Config
+		in          InputInfo
+		codec, rate string
+	}{
+		{"copy by default", config.Config{}, InputInfo{AudioChannels: 2}, "copy", ""},
+		{"legacy mono", config.Config{}, InputInfo{AudioChannels: 1, TranscodeAudio: true}, LegacyAudioCodec, "64k"},
+		{"legacy stereo", config.Config{}, InputInfo{AudioChannels: 2, TranscodeAudio: true}, LegacyAudioCodec, "128k"},
+		{"legacy 5.1", config.Config{}, InputInfo{AudioChannels: 6, TranscodeAudio: true}, LegacyAudioCodec, "256k"},
+		{"legacy unknown channels", config.Config{}, InputInfo{TranscodeAudio: true}, LegacyAudioCodec, AudioOnlyBitrate},
+		{"--audio-bitrate overrides policy", config.Config{AudioBitrate: "96k"}, InputInfo{AudioChannels: 6}, LegacyAudioCodec, "96k"},
+		{"downmix uses target channels", config.Config{AudioChannels: 2}, InputInfo{AudioChannels: 6}, LegacyAudioCodec, "128k"},
+		{"--audio-channels-auto never upmixes", config.Config{AudioChannels: 2, AudioChannelsAuto: true}, InputInfo{AudioChannels: 1}, "copy", ""},
+		{"--audio-filter forces transcode", config.Config{AudioFilter: "volume=2"}, InputInfo{AudioChannels: 1}, LegacyAudioCodec, "64k"},
+		{"--copy-audio wins", config.Config{CopyAudio: true, AudioBitrate: "96k"}, InputInfo{AudioChannels: 2, TranscodeAudio: true}, "copy", ""},
+		{"--audio-codec keeps policy bitrate", config.Config{AudioCodec: "aac_at"}, InputInfo{AudioChannels: 1}, "aac_at", "64k"},
+		{"preset audio settings", config.Config{Preset: "voice", Presets: voice}, InputInfo{AudioChannels: 2}, "libopus", "48k"},
+		{"--audio-bitrate overrides preset", config.Config{Preset: "voice", Presets: voice, AudioBitrate: "80k"}, InputInfo{AudioChannels: 2}, "libopus", "80k"},
+		{"audio-only mono", config.Config{}, InputInfo{AudioOnly: true, AudioChannels: 1}, AudioOnlyCodec, "64k"},
+		{"audio-only ignores --copy-audio", config.Config{CopyAudio: true}, InputInfo{AudioOnly: true, AudioChannels: 2}, AudioOnlyCodec, "128k"},
+		{"audio-only --audio-codec", config.Config{AudioCodec: "aac_at"}, InputInfo{AudioOnly: true, AudioChannels: 2}, "aac_at", "128k"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, rate := AudioPlan(tt.cfg, tt.in)
+			if codec != tt.codec || rate != tt.rate {
+				t.Errorf("AudioPlan = (%q, %q), want (%q, %q)", codec, rate, tt.codec, tt.rate)
+			}
+			// 报告中的音频编码必须与实际传给 ffmpeg 的一致
+			args := BuildArgs("in.mov", "out.mp4", tt.cfg, tt.in)
+			if got := argValues(args, "-c:a"); len(got) != 1 || got[0] != codec {
+				t.Errorf("BuildArgs -c:a = %q, want [%q]", got, codec)
+			}
+			if got := argValues(args, "-b:a"); rate != "" && (len(got) != 1 || got[0] != rate) {
+				t.Errorf("BuildArgs -b:a = %q, want [%q]", got, rate)
+			}
+		})
+	}
+}
+
+// argValues 返回 args 中 flag 之后的所有取值 (flag 可能出现多次)
+func argValues(args []string, flag string) []string {
+	var values []string
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag {
+			values = append(values, args[i+1])
+		}
+	}
+	return values
+}
+
+// 音量、响度标准化 (--audio-filter) 与 5.1 降混必须合并为一个 -af：ffmpeg 只采用最后一个 -af
+func TestAudioFiltersCombined(t *testing.T) {
+	cfg := config.Config{Preset: config.PresetStandard, AudioFilter: "volume=1.5,loudnorm=I=-16:TP=-1.5", AudioChannels: 2}
+	want := surroundDownmix + ",volume=1.5,loudnorm=I=-16:TP=-1.5"
+	for _, in := range []InputInfo{
+		{VideoStream: -1, Width: 1920, Height: 1080, PixFmt: "yuv420p", BitDepth: 8, AudioChannels: 6},
+		{AudioOnly: true, AudioChannels: 6},
+	} {
+		args := BuildArgs("in.mkv", "out.mkv", cfg, in)
+		if got := argValues(args, "-af"); len(got) != 1 || got[0] != want {
+			t.Errorf("audio-only=%v: -af = %q, want [%q]", in.AudioOnly, got, want)
+		}
+	}
+
+	// --audio-codec 同样作用于纯音频任务，滤镜不变
+	cfg.AudioCodec = "aac_at"
+	args := BuildArgs("in.flac", "out.m4a", cfg, InputInfo{AudioOnly: true, AudioChannels: 6})
+	if got := argValues(args, "-c:a"); len(got) != 1 || got[0] != "aac_at" {
+		t.Errorf("audio-only -c:a = %q, want [aac_at]", got)
+	}
+	if got := argValues(args, "-af"); len(got) != 1 || got[0] != want {
+		t.Errorf("audio-only aac_at: -af = %q, want [%q]", got, want)
+	}
+}
+
+func TestAudioOnlyOutputExt(t *testing.T) {
+	tests := []struct {
+		codec, want string
+	}{
+		{"", ".opus"},
+		{"libopus", ".opus"},
+		{"aac_at", ".m4a"},
+		{"libmp3lame", ".mp3"},
+		{"flac", ".flac"},
+		{"pcm_s16le", ".mka"},
+	}
+	for _, tt := range tests {
+		if got := AudioOnlyOutputExt(config.Config{AudioCodec: tt.codec}); got != tt.want {
+			t.Errorf("AudioOnlyOutputExt(%q) = %q, want %q", tt.codec, got, tt.want)
+		}
+	}
+}
+
+// 流复制音频时不能附加 -af
+func TestAudioFilterSkippedForCopy(t *testing.T) {
+	cfg := config.Config{Preset: config.PresetStandard, AudioFilter: "volume=2", CopyAudio: true}
+	args := BuildArgs("in.mp4", "out.mp4", cfg, InputInfo{VideoStream: -1, AudioChannels: 2})
+	if got := argValues(args, "-af"); len(got) != 0 {
+		t.Errorf("-af = %q with --copy-audio, want none", got)
+	}
+}
+
+// 切分模板中转义的 % 按字面匹配实际生成的分段
+func TestSegmentOutputsEscapedPercent(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "50% off")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, name := range []string{"100%-000.compressed.mp4", "100%-001.compressed.mp4"} {
+		want = append(want, filepath.Join(dir, name))
+		if err := os.WriteFile(want[len(want)-1], []byte("seg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pattern := filepath.Join(EscapeTemplate(dir), EscapeTemplate("100%")+"-%03d.compressed.mp4")
+
+	if got := fmt.Sprintf(pattern, 0); got != want[0] {
+		t.Errorf("first segment = %q, want %q", got, want[0])
+	}
+	if got := SegmentOutputs(pattern); !slices.Equal(got, want) {
+		t.Errorf("SegmentOutputs = %q, want %q", got, want)
+	}
+	if got := OutputDir(pattern, true); got != dir {
+		t.Errorf("OutputDir = %q, want %q", got, dir)
+	}
+}
@@ -0,0 +1,90 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Segment 是分段编码中的一段
+type Segment struct {
+	Index  int
+	Start  float64 // 起始时间 (秒)，同时作为续传状态中的键
+	Length float64 // 时长 (秒)，最后一段可能较短
+}
+
+// Key 返回该段在续传状态中的键 (起始毫秒数)，分段长度改变后旧记录不会被误用
+func (s Segment) Key() string {
+	return strconv.FormatInt(int64(s.Start*1000), 10)
+}
+
+// SplitSegments 将时长为 duration 的输入按 every 秒切分
+func SplitSegments(duration, every float64) []Segment {
+	if duration <= 0 || every <= 0 {
+		return nil
+	}
+	var segs []Segment
+	for start := 0.0; start < duration; start += every {
+		segs = append(segs, Segment{Index: len(segs), Start: start, Length: min(every, duration-start)})
+	}
+	return segs
+}
+
+// SegmentArgs 将完整任务的参数改写为只编码 seg 一段：-ss 位于 -i 之前 (重新编码时仍是精确定位)，
+// -t 位于输出之前，输出写入 output
+func SegmentArgs(args []string, seg Segment, output string) []string {
+	i := slices.Index(args, "-i")
+	if i < 0 || len(args) < 2 {
+		return args
+	}
+	ss := strconv.FormatFloat(seg.Start, 'f', 3, 64)
+	t := strconv.FormatFloat(seg.Length, 'f', 3, 64)
+
+	out := make([]string, 0, len(args)+4)
+	out = append(out, args[:i]...)
+	out = append(out, "-ss", ss)
+	out = append(out, args[i:len(args)-1]...)
+	return append(out, "-t", t, output)
+}
+
+// ConcatSegments 以 concat 分离器无损拼接各段到 output
+// 列表文件写在第一段所在目录，结束后删除
+func ConcatSegments(files []string, output string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("没有可拼接的分段")
+	}
+	var list strings.Builder
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return err
+		}
+		// concat 列表中的单引号需写作 '\''
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(abs, "'", `'\''`))
+	}
+	listFile := filepath.Join(filepath.Dir(files[0]), "concat.txt")
+	if err := os.WriteFile(listFile, []byte(list.String()), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(listFile)
+
+	args := []string{"-y", "-hide_banner", "-nostdin", "-v", "error",
+		"-f", "concat", "-safe", "0", "-i", listFile,
+		"-map", "0", "-c", "copy"}
+	if IsMP4Family(output) {
+		args = append(args, "-movflags", "+faststart")
+	}
+	args = append(args, output)
+
+	stderr := NewTailBuffer(0)
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return &RunError{Err: err, Stderr: stderr.String()}
+	}
+	return nil
+}
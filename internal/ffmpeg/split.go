@@ -0,0 +1,37 @@
+package ffmpeg
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+)
+
+// Split 不重新编码 (-c copy)，将 input 按 segmentSec 秒切分为多个文件，返回实际生成的文件
+// outputPattern 为 printf 风格的模板 (如 dir/video_%03d.mp4)，序号从 1 开始；
+// 流复制只能在关键帧处切分，各段时长会略长于 segmentSec
+func Split(input, outputPattern string, segmentSec int, atKeyframes bool) ([]string, error) {
+	if segmentSec <= 0 {
+		return nil, errors.New("切分时长必须大于 0")
+	}
+	args := []string{"-hide_banner", "-nostdin", "-v", "error", "-i", input,
+		"-map", "0:V?", "-map", "0:a?", "-c", "copy",
+		"-f", "segment", "-segment_time", strconv.Itoa(segmentSec),
+		"-segment_start_number", "1", "-reset_timestamps", "1",
+	}
+	if atKeyframes {
+		// 允许切分点比目标时刻提前 50ms，落在目标时刻附近的关键帧即可切分，避免顺延到下一个关键帧
+		args = append(args, "-segment_time_delta", "0.05")
+	}
+	if IsMP4Family(outputPattern) {
+		args = append(args, "-segment_format_options", "movflags=+faststart")
+	}
+	args = append(args, outputPattern)
+
+	cmd := exec.Command("ffmpeg", args...)
+	stderr := NewTailBuffer(0)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return SegmentOutputs(outputPattern), &RunError{Err: err, Stderr: stderr.String()}
+	}
+	return SegmentOutputs(outputPattern), nil
+}
@@ -0,0 +1,77 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultStderrTailBytes 是捕获 ffmpeg 标准错误时默认保留的末尾字节数
+const DefaultStderrTailBytes = 64 << 10
+
+// TailBuffer 是只保留最后 Size 字节的 io.Writer (环形缓冲区)
+// ffmpeg 遇到损坏的文件可能输出数百 MB 警告，而诊断只需要结尾部分，内存占用不随输出增长
+type TailBuffer struct {
+	buf     []byte
+	pos     int   // 下一次写入的位置
+	full    bool  // 缓冲区是否已写满并开始覆盖
+	dropped int64 // 被覆盖的字节数
+}
+
+// NewTailBuffer 创建保留最后 size 字节的缓冲区，size <= 0 时使用 DefaultStderrTailBytes
+func NewTailBuffer(size int) *TailBuffer {
+	if size <= 0 {
+		size = DefaultStderrTailBytes
+	}
+	return &TailBuffer{buf: make([]byte, size)}
+}
+
+// Write 实现 io.Writer，总是写入成功
+func (t *TailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if n >= len(t.buf) {
+		// 单次写入就超过容量：只保留其结尾
+		if t.full {
+			t.dropped += int64(len(t.buf))
+		} else {
+			t.dropped += int64(t.pos)
+		}
+		t.dropped += int64(n - len(t.buf))
+		copy(t.buf, p[n-len(t.buf):])
+		t.pos, t.full = 0, true
+		return n, nil
+	}
+	for len(p) > 0 {
+		c := copy(t.buf[t.pos:], p)
+		if t.full {
+			t.dropped += int64(c)
+		}
+		t.pos += c
+		p = p[c:]
+		if t.pos == len(t.buf) {
+			t.pos, t.full = 0, true
+		}
+	}
+	return n, nil
+}
+
+// Bytes 按写入顺序返回保留的内容
+func (t *TailBuffer) Bytes() []byte {
+	if !t.full {
+		return append([]byte(nil), t.buf[:t.pos]...)
+	}
+	return append(append([]byte(nil), t.buf[t.pos:]...), t.buf[:t.pos]...)
+}
+
+// String 返回保留的内容；发生截断时丢弃不完整的首行，并注明省略的字节数
+func (t *TailBuffer) String() string {
+	s := string(t.Bytes())
+	if t.dropped == 0 {
+		return s
+	}
+	dropped := t.dropped
+	if i := strings.IndexByte(s, '\n'); i >= 0 && i+1 < len(s) {
+		dropped += int64(i + 1)
+		s = s[i+1:]
+	}
+	return fmt.Sprintf("... (省略前 %d 字节)\n%s", dropped, s)
+}
@@ -0,0 +1,70 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// 持续写入数 MB 的 stderr：内存占用固定为缓冲区大小，只保留最后 size 字节
+func TestTailBufferKeepsTail(t *testing.T) {
+	const size = 4 << 10
+	tb := NewTailBuffer(size)
+	var all bytes.Buffer
+	for i := 0; all.Len() < 4<<20; i++ {
+		line := fmt.Sprintf("[h264 @ 0x1234] error while decoding MB %d\n", i)
+		all.WriteString(line)
+		if _, err := tb.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cap(tb.buf) != size {
+		t.Fatalf("buffer grew to %d bytes, want %d", cap(tb.buf), size)
+	}
+	want := all.Bytes()[all.Len()-size:]
+	if got := tb.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("Bytes() does not match the last %d bytes written", size)
+	}
+	if tb.dropped != int64(all.Len()-size) {
+		t.Errorf("dropped = %d, want %d", tb.dropped, all.Len()-size)
+	}
+
+	// String 丢弃不完整的首行并注明省略的字节数
+	s := tb.String()
+	first := bytes.IndexByte(want, '\n') + 1
+	if wantStr := fmt.Sprintf("... (省略前 %d 字节)\n%s", all.Len()-size+first, want[first:]); s != wantStr {
+		t.Errorf("String() = %q...", s[:min(len(s), 80)])
+	}
+}
+
+func TestTailBufferLargeWrite(t *testing.T) {
+	tb := NewTailBuffer(8)
+	_, _ = tb.Write([]byte("abc"))
+	_, _ = tb.Write([]byte(strings.Repeat("x", 1<<20) + "12345678"))
+	if got := string(tb.Bytes()); got != "12345678" {
+		t.Errorf("Bytes() = %q, want %q", got, "12345678")
+	}
+	if want := int64(3 + 1<<20); tb.dropped != want {
+		t.Errorf("dropped = %d, want %d", tb.dropped, want)
+	}
+}
+
+func TestTailBufferShort(t *testing.T) {
+	tb := NewTailBuffer(0) // 使用 DefaultStderrTailBytes
+	_, _ = tb.Write([]byte("line 1\nline 2\n"))
+	if got := tb.String(); got != "line 1\nline 2\n" {
+		t.Errorf("String() = %q", got)
+	}
+	if len(tb.buf) != DefaultStderrTailBytes {
+		t.Errorf("default size = %d, want %d", len(tb.buf), DefaultStderrTailBytes)
+	}
+}
+
+func TestTailBufferWriteDoesNotAllocate(t *testing.T) {
+	tb := NewTailBuffer(1 << 10)
+	line := []byte("[aac @ 0x1] Queue input is backward in time\n")
+	if n := testing.AllocsPerRun(1000, func() { _, _ = tb.Write(line) }); n != 0 {
+		t.Errorf("Write allocates %.1f times per call, want 0", n)
+	}
+}
@@ -0,0 +1,90 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+)
+
+// ErrVisuallyCorrupt 表示输出画面与源文件严重不符 (如硬件编码器输出整体偏绿)，但 ffmpeg 正常退出
+var ErrVisuallyCorrupt = errors.New("output visually corrupt")
+
+// 抽样帧缩小到该尺寸后比较，裁剪、缩放与轻微的画质损失对均值几乎没有影响
+const (
+	sampleWidth  = 64
+	sampleHeight = 36
+)
+
+// 判定为损坏的阈值 (8 位取值 0-255)，取值保守以免误报：
+// 正常压缩的色度均值偏差通常在 2 以内，偏绿的输出 U/V 往往偏离 30 以上
+const (
+	chromaMismatch = 24.0
+	lumaMismatch   = 48.0
+)
+
+// FrameStats 是一帧画面各平面的平均值
+type FrameStats struct {
+	Y, U, V float64
+}
+
+// SampleFrame 解码 path 在 at 秒处的一帧，缩小后返回各平面的平均值
+func SampleFrame(path string, at float64) (FrameStats, error) {
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostdin", "-v", "error",
+		"-ss", strconv.FormatFloat(at, 'f', 3, 64), "-i", path,
+		"-map", "0:v:0", "-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:%d,format=yuv444p", sampleWidth, sampleHeight),
+		"-f", "rawvideo", "-")
+	stderr := NewTailBuffer(4 << 10)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return FrameStats{}, &RunError{Err: err, Stderr: stderr.String()}
+	}
+	plane := sampleWidth * sampleHeight
+	if len(out) < 3*plane {
+		return FrameStats{}, fmt.Errorf("%.1fs 处未能解码出画面", at)
+	}
+	mean := func(p []byte) float64 {
+		sum := 0
+		for _, v := range p {
+			sum += int(v)
+		}
+		return float64(sum) / float64(len(p))
+	}
+	return FrameStats{
+		Y: mean(out[:plane]),
+		U: mean(out[plane : 2*plane]),
+		V: mean(out[2*plane : 3*plane]),
+	}, nil
+}
+
+// CheckVisual 在 25%/50%/75% 处各抽一帧，比较输出与源文件的亮度、色度均值
+// 只有所有抽样帧都严重偏离时才返回 ErrVisuallyCorrupt；skipLuma 用于色调映射等会整体改变亮度的输出
+// 抽帧失败时返回其他错误，调用方不应据此判定输出损坏
+func CheckVisual(source, output string, duration float64, skipLuma bool) error {
+	if duration <= 0 {
+		return fmt.Errorf("时长未知，无法抽帧")
+	}
+	var detail string
+	for _, pos := range []float64{0.25, 0.5, 0.75} {
+		at := duration * pos
+		src, err := SampleFrame(source, at)
+		if err != nil {
+			return fmt.Errorf("源文件抽帧失败: %w", err)
+		}
+		out, err := SampleFrame(output, at)
+		if err != nil {
+			return fmt.Errorf("输出抽帧失败: %w", err)
+		}
+		dy, du, dv := math.Abs(out.Y-src.Y), math.Abs(out.U-src.U), math.Abs(out.V-src.V)
+		if du < chromaMismatch && dv < chromaMismatch && (skipLuma || dy < lumaMismatch) {
+			return nil
+		}
+		if detail == "" {
+			detail = fmt.Sprintf("at %.0fs: Y %.0f->%.0f, U %.0f->%.0f, V %.0f->%.0f", at, src.Y, out.Y, src.U, out.U, src.V, out.V)
+		}
+	}
+	return fmt.Errorf("%w (%s)", ErrVisuallyCorrupt, detail)
+}
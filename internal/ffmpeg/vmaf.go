@@ -0,0 +1,47 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// vmafScoreRe 匹配 libvmaf 结束时输出的均分，如 "VMAF score: 93.412345"
+var vmafScoreRe = regexp.MustCompile(`VMAF score: ([\d.]+)`)
+
+// HasFilter 判断本机 ffmpeg 是否包含指定滤镜 (如 libvmaf)
+func HasFilter(name string) (bool, error) {
+	out, err := exec.Command("ffmpeg", "-hide_banner", "-filters").Output()
+	if err != nil {
+		return false, err
+	}
+	// -filters 与 -encoders 的列表格式相同：能力标记、名称、说明
+	_, ok := parseEncoders(out)[name]
+	return ok, nil
+}
+
+// MeasureVMAF 计算 distorted 相对 reference 中 [start, start+length) 一段的 VMAF 均分 (0-100)
+// distorted 为从该段起点编码出的样本；尺寸不同 (如 --max-height) 时先缩放到参考画面的尺寸再比较
+func MeasureVMAF(reference, distorted string, start, length float64, in InputInfo) (float64, error) {
+	refStream := "1:v:0"
+	if in.VideoStream >= 0 {
+		refStream = fmt.Sprintf("1:%d", in.VideoStream)
+	}
+	graph := fmt.Sprintf("[0:v:0]setpts=PTS-STARTPTS[d];[%s]setpts=PTS-STARTPTS[r];"+
+		"[d][r]scale2ref=flags=bicubic[d2][r2];[d2][r2]libvmaf", refStream)
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostdin",
+		"-i", distorted,
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64), "-t", strconv.FormatFloat(length, 'f', 3, 64), "-i", reference,
+		"-lavfi", graph, "-f", "null", "-")
+	stderr := NewTailBuffer(0)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return 0, &RunError{Err: err, Stderr: stderr.String()}
+	}
+	m := vmafScoreRe.FindStringSubmatch(stderr.String())
+	if m == nil {
+		return 0, fmt.Errorf("未能从 libvmaf 输出中读取 VMAF 分数")
+	}
+	return strconv.ParseFloat(m[1], 64)
+}
@@ -0,0 +1,146 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// nameRe 限制配置名只含字母、数字与 . _ -，便于在命令行中直接引用
+var nameRe = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Path 返回保存命名配置的文件 (~/.vc/profiles.json)
+func Path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".vc", "profiles.json"), nil
+}
+
+// ValidName 判断 name 能否用作配置名
+func ValidName(name string) bool {
+	return nameRe.MatchString(name)
+}
+
+// All 返回全部命名配置 (配置名 -> 保存的命令行参数)，文件不存在时返回空表
+func All() (map[string][]string, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string][]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	profiles := map[string][]string{}
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败 %s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// Names 返回按名称排序的配置名
+func Names(profiles map[string][]string) []string {
+	return slices.Sorted(maps.Keys(profiles))
+}
+
+// Load 返回名为 name 的配置保存的命令行参数
+func Load(name string) ([]string, error) {
+	profiles, err := All()
+	if err != nil {
+		return nil, err
+	}
+	args, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的配置 %q (vc profile list 查看全部配置)", name)
+	}
+	return args, nil
+}
+
+// Save 保存 (或覆盖) 名为 name 的配置
+func Save(name string, args []string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("配置名 %q 只能包含字母、数字与 . _ -", name)
+	}
+	profiles, err := All()
+	if err != nil {
+		return err
+	}
+	profiles[name] = args
+	return write(profiles)
+}
+
+// Delete 删除名为 name 的配置
+func Delete(name string) error {
+	profiles, err := All()
+	if err != nil {
+		return err
+	}
+	if _, ok := profiles[name]; !ok {
+		return fmt.Errorf("未知的配置 %q (vc profile list 查看全部配置)", name)
+	}
+	delete(profiles, name)
+	return write(profiles)
+}
+
+func write(profiles map[string][]string) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Expand 展开命令行中的 --profile <name>：将配置保存的参数插入到全部参数之前，
+// 之后命令行中的同名参数会覆盖配置中的值 (可重复的参数如 --route 则追加)
+// args 不含程序名；没有 --profile 时原样返回
+func Expand(args []string) ([]string, error) {
+	name := ""
+	for i, a := range args {
+		if a == "--" {
+			break
+		}
+		if v, ok := strings.CutPrefix(a, "--profile="); ok {
+			name = v
+		} else if a == "--profile" && i+1 < len(args) {
+			name = args[i+1]
+		}
+	}
+	if name == "" {
+		return args, nil
+	}
+	saved, err := Load(name)
+	if err != nil {
+		return nil, err
+	}
+	return append(slices.Clone(saved), args...), nil
+}
+
+// Format 将保存的参数拼接为可复制到命令行的形式，含空格等特殊字符的参数加引号
+func Format(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\"'$*?") {
+			a = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		}
+		quoted[i] = a
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,64 @@
+package report
+
+import "video-compress/internal/compressor"
+
+// 对比结果分类
+const (
+	ChangeImproved  = "improved"  // 体积比下降
+	ChangeDegraded  = "degraded"  // 体积比上升
+	ChangeUnchanged = "unchanged" // 体积比相同
+	ChangeAdded     = "added"     // 仅出现在新报告中
+	ChangeRemoved   = "removed"   // 仅出现在旧报告中
+)
+
+// Change 描述同一输入 (及版本) 在两次运行之间的压缩效果变化
+type Change struct {
+	InputFile string
+	Rendition string
+	Kind      string
+	PrevRatio float64 // 体积比 (新/原)，未成功处理时为 0
+	NewRatio  float64
+}
+
+// Compare 按输入文件与版本名匹配两份报告中的条目，对比体积比变化
+// 结果按新报告的顺序排列，旧报告独有的条目附在末尾
+func Compare(prev, next *Report) []Change {
+	type key struct{ input, rendition string }
+	keyOf := func(item compressor.ReportItem) key { return key{item.InputFile, item.Rendition} }
+
+	prevItems := make(map[key]compressor.ReportItem, len(prev.Items))
+	for _, item := range prev.Items {
+		prevItems[keyOf(item)] = item
+	}
+
+	var changes []Change
+	seen := make(map[key]bool)
+	for _, item := range next.Items {
+		k := keyOf(item)
+		seen[k] = true
+		c := Change{InputFile: item.InputFile, Rendition: item.Rendition, NewRatio: Ratio(item)}
+		old, ok := prevItems[k]
+		if !ok {
+			c.Kind = ChangeAdded
+			changes = append(changes, c)
+			continue
+		}
+		c.PrevRatio = Ratio(old)
+		switch {
+		case c.NewRatio == c.PrevRatio:
+			c.Kind = ChangeUnchanged
+		case c.PrevRatio == 0 || (c.NewRatio != 0 && c.NewRatio < c.PrevRatio):
+			// 之前未成功而这次成功同样视为改善
+			c.Kind = ChangeImproved
+		default:
+			c.Kind = ChangeDegraded
+		}
+		changes = append(changes, c)
+	}
+	for _, item := range prev.Items {
+		if !seen[keyOf(item)] {
+			changes = append(changes, Change{InputFile: item.InputFile, Rendition: item.Rendition, Kind: ChangeRemoved, PrevRatio: Ratio(item)})
+		}
+	}
+	return changes
+}
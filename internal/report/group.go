@@ -0,0 +1,16 @@
+package report
+
+import (
+	"path/filepath"
+	"video-compress/internal/compressor"
+)
+
+// GroupByDirectory 按输入文件所在目录对报告条目分组，组内保持原有顺序
+func GroupByDirectory(items []compressor.ReportItem) map[string][]compressor.ReportItem {
+	groups := make(map[string][]compressor.ReportItem)
+	for _, item := range items {
+		dir := filepath.Dir(item.InputFile)
+		groups[dir] = append(groups[dir], item)
+	}
+	return groups
+}
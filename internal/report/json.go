@@ -0,0 +1,56 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+	"video-compress/internal/compressor"
+)
+
+// Report 是 JSON 报告的顶层结构
+type Report struct {
+	GeneratedAt time.Time               `json:"generated_at"`
+	Items       []compressor.ReportItem `json:"items"`
+}
+
+// New 以本次运行的处理结果 (含跳过的文件) 生成报告
+func New(processed, ignored []compressor.ReportItem) *Report {
+	return &Report{
+		GeneratedAt: time.Now(),
+		Items:       append(append([]compressor.ReportItem{}, processed...), ignored...),
+	}
+}
+
+// WriteJSON 将本次运行的处理结果 (含跳过的文件) 写入 JSON 报告
+func WriteJSON(path string, processed, ignored []compressor.ReportItem) error {
+	data, err := json.MarshalIndent(New(processed, ignored), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadJSON 读取之前写出的 JSON 报告
+func LoadJSON(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("解析报告失败 %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// FailedInputs 返回报告中所有失败条目的输入文件
+func (r *Report) FailedInputs() []string {
+	var files []string
+	for _, item := range r.Items {
+		if item.Status == "Failed" {
+			files = append(files, item.InputFile)
+		}
+	}
+	return files
+}
@@ -0,0 +1,28 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"video-compress/internal/compressor"
+)
+
+// LastRunPath 返回最近一次运行报告的保存位置 (~/.vc/last-run-report.json)
+func LastRunPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".vc", "last-run-report.json"), nil
+}
+
+// SaveLastRun 保存本次运行的报告，供 vc report 在终端关闭后重新查看
+func SaveLastRun(processed, ignored []compressor.ReportItem) error {
+	path, err := LastRunPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return WriteJSON(path, processed, ignored)
+}
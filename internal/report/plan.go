@@ -0,0 +1,102 @@
+package report
+
+import (
+	"os"
+	"video-compress/internal/compressor"
+	"video-compress/internal/config"
+)
+
+// 重跑计划分类 (--dry-run --diff)
+const (
+	PlanNew             = "new"              // 之前的报告中没有该文件
+	PlanSettingsChanged = "settings-changed" // 编码参数与上次不同
+	PlanSourceChanged   = "source-changed"   // 源文件大小或修改时间与上次不同
+	PlanRetry           = "retry"            // 上次失败或被跳过
+	PlanUnchanged       = "unchanged"        // 设置与源文件都未变，重跑只会得到相同的结果
+	PlanSkip            = "skip"             // 本次扫描即被跳过 (如输出已存在)
+)
+
+// PlanEntry 描述某个文件在重跑时会发生什么
+type PlanEntry struct {
+	InputFile string
+	Rendition string
+	Kind      string
+	Reason    string // PlanSkip 时为本次跳过的原因，PlanRetry 时为上次的原因
+}
+
+// Plan 将本次扫描得到的任务与之前的报告逐一比较，按任务顺序返回，本次跳过的文件附在末尾
+// 旧报告缺少参数指纹 (早期版本生成) 时视为设置已变化
+func Plan(prev *Report, jobs []compressor.Job, ignored []compressor.ReportItem, cfg config.Config) []PlanEntry {
+	type key struct{ input, rendition string }
+	prevItems := make(map[key]compressor.ReportItem, len(prev.Items))
+	for _, item := range prev.Items {
+		prevItems[key{item.InputFile, item.Rendition}] = item
+	}
+
+	var entries []PlanEntry
+	for _, j := range jobs {
+		e := PlanEntry{InputFile: j.InputFile, Rendition: j.Rendition}
+		old, ok := prevItems[key{j.InputFile, j.Rendition}]
+		switch {
+		case !ok:
+			e.Kind = PlanNew
+		case old.Status != "Processed":
+			e.Kind = PlanRetry
+			e.Reason = old.Reason
+		case sourceChanged(j, old):
+			e.Kind = PlanSourceChanged
+		case old.Settings == "" || old.Settings != j.SettingsHash(cfg):
+			e.Kind = PlanSettingsChanged
+		default:
+			e.Kind = PlanUnchanged
+		}
+		entries = append(entries, e)
+	}
+	for _, item := range ignored {
+		entries = append(entries, PlanEntry{InputFile: item.InputFile, Rendition: item.Rendition, Kind: PlanSkip, Reason: item.Reason})
+	}
+	return entries
+}
+
+// SkipUnchanged 用于 --since-report 增量处理：上次已成功处理且源文件 (大小、修改时间) 未变的任务
+// 从 jobs 中移除并作为跳过条目返回，新文件、上次失败或已被修改的文件保留
+func (r *Report) SkipUnchanged(jobs []compressor.Job) (kept []compressor.Job, skipped []compressor.ReportItem) {
+	type key struct{ input, rendition string }
+	done := make(map[key]compressor.ReportItem, len(r.Items))
+	for _, item := range r.Items {
+		if item.Status == "Processed" {
+			done[key{item.InputFile, item.Rendition}] = item
+		}
+	}
+	for _, j := range jobs {
+		old, ok := done[key{j.InputFile, j.Rendition}]
+		if !ok || sourceChanged(j, old) {
+			kept = append(kept, j)
+			continue
+		}
+		skipped = append(skipped, compressor.ReportItem{
+			InputFile:  j.InputFile,
+			OutputFile: j.OutputFile,
+			Status:     "Ignored",
+			Reason:     "Unchanged since previous report",
+			Rendition:  j.Rendition,
+		})
+	}
+	return kept, skipped
+}
+
+// sourceChanged 判断源文件自上次运行后是否被修改
+// 上次就地重新压缩 (Superseded) 时源文件已被输出替换，按输出大小比较
+func sourceChanged(j compressor.Job, old compressor.ReportItem) bool {
+	fi, err := os.Stat(j.InputFile)
+	if err != nil {
+		return false
+	}
+	if old.Superseded {
+		return fi.Size() != old.NewSize
+	}
+	if fi.Size() != old.OriginalSize {
+		return true
+	}
+	return !old.SourceModTime.IsZero() && !old.SourceModTime.Equal(j.ModTime)
+}
@@ -0,0 +1,103 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"video-compress/internal/compressor"
+)
+
+// 报告输出格式 (text 由命令行层渲染)
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// Formats 是 --report-format 支持的取值
+var Formats = []string{FormatText, FormatJSON, FormatCSV, FormatMarkdown, FormatHTML}
+
+// Split 将报告条目拆分为处理过的 (成功/失败) 与跳过的文件
+func (r *Report) Split() (processed, ignored []compressor.ReportItem) {
+	for _, item := range r.Items {
+		if item.Status == "Ignored" {
+			ignored = append(ignored, item)
+		} else {
+			processed = append(processed, item)
+		}
+	}
+	return processed, ignored
+}
+
+// Ratio 返回条目的体积比 (新/原)，无法计算时返回 0
+func Ratio(item compressor.ReportItem) float64 {
+	if item.Status != "Processed" || item.OriginalSize <= 0 {
+		return 0
+	}
+	return float64(item.NewSize) / float64(item.OriginalSize)
+}
+
+// Render 以机器可读的格式 (json/csv/markdown/html) 写出报告
+func Render(w io.Writer, r *Report, format string) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"input_file", "output_file", "status", "reason", "original_size", "new_size", "ratio"})
+		for _, item := range r.Items {
+			_ = cw.Write([]string{
+				item.InputFile, item.OutputFile, item.Status, item.Reason,
+				strconv.FormatInt(item.OriginalSize, 10), strconv.FormatInt(item.NewSize, 10),
+				strconv.FormatFloat(Ratio(item), 'f', 4, 64),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatMarkdown:
+		fmt.Fprintf(w, "# 任务处理报告 (%s)\n\n", r.GeneratedAt.Format("2006-01-02 15:04:05"))
+		fmt.Fprintln(w, "| 文件 | 状态 | 原大小 | 新大小 | 体积比 | 原因 |")
+		fmt.Fprintln(w, "| --- | --- | ---: | ---: | ---: | --- |")
+		for _, item := range r.Items {
+			ratio := ""
+			if v := Ratio(item); v > 0 {
+				ratio = fmt.Sprintf("%.1f%%", v*100)
+			}
+			fmt.Fprintf(w, "| %s | %s | %d | %d | %s | %s |\n",
+				markdownEscape(filepath.Base(item.InputFile)), item.Status,
+				item.OriginalSize, item.NewSize, ratio, markdownEscape(item.Reason))
+		}
+		return nil
+	case FormatHTML:
+		title := fmt.Sprintf("任务处理报告 (%s)", r.GeneratedAt.Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", title)
+		fmt.Fprintln(w, "<style>table{border-collapse:collapse}th,td{border:1px solid #ccc;padding:4px 8px}td.num{text-align:right}</style>")
+		fmt.Fprintf(w, "</head>\n<body>\n<h1>%s</h1>\n<table>\n", title)
+		fmt.Fprintln(w, "<tr><th>文件</th><th>状态</th><th>原大小</th><th>新大小</th><th>体积比</th><th>原因</th></tr>")
+		for _, item := range r.Items {
+			ratio := ""
+			if v := Ratio(item); v > 0 {
+				ratio = fmt.Sprintf("%.1f%%", v*100)
+			}
+			fmt.Fprintf(w, "<tr><td title=\"%s\">%s</td><td>%s</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num\">%s</td><td>%s</td></tr>\n",
+				html.EscapeString(item.InputFile), html.EscapeString(filepath.Base(item.InputFile)), item.Status,
+				item.OriginalSize, item.NewSize, ratio, html.EscapeString(item.Reason))
+		}
+		_, err := fmt.Fprintln(w, "</table>\n</body>\n</html>")
+		return err
+	}
+	return fmt.Errorf("未知的报告格式 %q", format)
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,106 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// runDirLayout 是每次运行目录的命名格式，按字典序即按时间排序
+const runDirLayout = "2006-01-02T15-04-05"
+
+// RunReportFile 是运行目录中 JSON 报告的文件名
+const RunReportFile = "report.json"
+
+// RunsDir 返回保存各次运行目录的位置 ($XDG_STATE_HOME/vc/runs，默认 ~/.local/state/vc/runs)
+func RunsDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "vc", "runs"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "vc", "runs"), nil
+}
+
+// NewRunDir 以开始时间为名创建本次运行的目录，同一秒内启动多次时追加序号
+func NewRunDir(start time.Time) (string, error) {
+	root, err := RunsDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+	name := start.Format(runDirLayout)
+	dir := filepath.Join(root, name)
+	for n := 1; ; n++ {
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			return dir, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+		dir = filepath.Join(root, fmt.Sprintf("%s.%d", name, n))
+	}
+}
+
+// listRuns 按时间从旧到新返回已有的运行目录 (只包含符合命名格式的目录)
+func listRuns() ([]string, error) {
+	root, err := RunsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []string
+	for _, e := range entries {
+		if !e.IsDir() || len(e.Name()) < len(runDirLayout) {
+			continue
+		}
+		if _, err := time.Parse(runDirLayout, e.Name()[:len(runDirLayout)]); err != nil {
+			continue
+		}
+		runs = append(runs, filepath.Join(root, e.Name()))
+	}
+	slices.Sort(runs)
+	return runs, nil
+}
+
+// LatestRun 返回最近一次运行的目录
+func LatestRun() (string, error) {
+	runs, err := listRuns()
+	if err != nil {
+		return "", err
+	}
+	if len(runs) == 0 {
+		return "", errors.New("还没有保存过运行记录")
+	}
+	return runs[len(runs)-1], nil
+}
+
+// PruneRuns 只保留最近 keep 次运行的目录，返回删除的目录数
+func PruneRuns(keep int) (int, error) {
+	runs, err := listRuns()
+	if err != nil || len(runs) <= keep {
+		return 0, err
+	}
+	removed := 0
+	for _, dir := range runs[:len(runs)-keep] {
+		if err := os.RemoveAll(dir); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
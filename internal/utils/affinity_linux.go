@@ -0,0 +1,31 @@
+package utils
+
+import "golang.org/x/sys/unix"
+
+// AffinitySupported 表示当前平台能否将进程绑定到指定的 CPU 核心
+const AffinitySupported = true
+
+// SetAffinity 将进程 pid 的主线程绑定到 cpus，之后创建的线程继承该设置
+// 应在进程刚启动、尚未创建工作线程时调用
+func SetAffinity(pid int, cpus []int) error {
+	var set unix.CPUSet
+	for _, c := range cpus {
+		set.Set(c)
+	}
+	return unix.SchedSetaffinity(pid, &set)
+}
+
+// AllowedCPUs 返回当前进程允许使用的 CPU 核心编号 (容器或 taskset 限制后可能不从 0 开始)
+func AllowedCPUs() []int {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		return nil
+	}
+	var cpus []int
+	for c := 0; len(cpus) < set.Count(); c++ {
+		if set.IsSet(c) {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus
+}
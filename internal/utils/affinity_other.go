@@ -0,0 +1,17 @@
+//go:build !linux
+
+package utils
+
+// AffinitySupported 表示当前平台能否将进程绑定到指定的 CPU 核心
+// macOS 只提供线程亲和性提示 (affinity tag)，无法绑定到具体核心
+const AffinitySupported = false
+
+// SetAffinity 在不支持的平台上不做任何事
+func SetAffinity(pid int, cpus []int) error {
+	return nil
+}
+
+// AllowedCPUs 在不支持的平台上返回空，调用方据此不做绑定
+func AllowedCPUs() []int {
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build darwin || linux
+
+package utils
+
+import "syscall"
+
+// FreeSpace 返回 dir 所在文件系统对当前用户可用的字节数
+func FreeSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package utils
+
+import "errors"
+
+// FreeSpace 在不支持的平台上无法查询剩余空间，调用方按未知处理
+func FreeSpace(dir string) (uint64, error) {
+	return 0, errors.New("free space query not supported on this platform")
+}
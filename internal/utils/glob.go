@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// HasGlobMeta 判断字符串是否包含 glob 元字符
+func HasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// ExpandGlob 展开 glob 模式，返回匹配的普通文件 (按路径排序)
+// 支持 ~ 开头的家目录与跨任意层目录的 "**"，其余语法同 filepath.Match；不跟随符号链接目录
+func ExpandGlob(pattern string) ([]string, error) {
+	if rest, ok := strings.CutPrefix(pattern, "~"); ok && (rest == "" || rest[0] == '/') {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		pattern = home + rest
+	}
+	pattern = filepath.Clean(pattern)
+
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return regularFiles(matches), nil
+	}
+
+	// 从第一个含元字符的路径段之前开始遍历，避免扫描整个文件系统
+	segments := strings.Split(pattern, string(filepath.Separator))
+	i := slices.IndexFunc(segments, HasGlobMeta)
+	root := strings.Join(segments[:i], string(filepath.Separator))
+	if root == "" {
+		root = "."
+		if strings.HasPrefix(pattern, string(filepath.Separator)) {
+			root = string(filepath.Separator)
+		}
+	}
+	parts := segments[i:]
+
+	var matches []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			switch {
+			case path == root && errors.Is(err, fs.ErrNotExist):
+				return nil // 起始目录不存在即没有匹配
+			case d != nil && d.IsDir() && path != root:
+				return filepath.SkipDir // 无权限的子目录不影响其他匹配
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		if matchSegments(parts, strings.Split(rel, string(filepath.Separator))) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// matchSegments 逐段匹配路径，"**" 匹配零个或多个目录
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for k := 0; k <= len(path); k++ {
+			if matchSegments(pattern[1:], path[k:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
+
+// regularFiles 过滤出普通文件
+func regularFiles(paths []string) []string {
+	var files []string
+	for _, p := range paths {
+		if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() {
+			files = append(files, p)
+		}
+	}
+	return files
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseFileMode 解析八进制权限 ("0644"、"644")
+func ParseFileMode(s string) (os.FileMode, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 8, 32)
+	if err != nil || n > 0o777 || n == 0 {
+		return 0, fmt.Errorf("无效的权限 %q (示例: 0644)", s)
+	}
+	return os.FileMode(n), nil
+}
+
+// DirMode 返回与文件权限对应的目录权限：有读权限的位同时获得执行 (进入) 权限，如 0644 -> 0755
+func DirMode(mode os.FileMode) os.FileMode {
+	return mode | (mode&0o444)>>2
+}
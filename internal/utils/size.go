@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits 为体积单位对应的字节数 (按 1024 进位，与报告中的显示一致)
+var sizeUnits = map[string]int64{
+	"":  1,
+	"b": 1,
+	"k": 1 << 10, "kb": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40,
+}
+
+// ParseSize 解析 "2GB"、"500M"、"1.5g" 等体积描述为字节数
+func ParseSize(s string) (int64, error) {
+	t := strings.ToLower(strings.TrimSpace(s))
+	i := strings.IndexFunc(t, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(t)
+	}
+	n, err := strconv.ParseFloat(t[:i], 64)
+	unit, ok := sizeUnits[strings.TrimSpace(t[i:])]
+	if err != nil || !ok || n < 0 {
+		return 0, fmt.Errorf("无效的体积 %q (示例: 2GB, 500MB)", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// ParseBitrate 解析 "6M"、"800k"、"2.5m" 等码率描述为 bit/s (按 1000 进位，与 ffmpeg 一致)
+func ParseBitrate(s string) (int64, error) {
+	t := strings.ToLower(strings.TrimSpace(s))
+	t = strings.TrimSuffix(strings.TrimSuffix(t, "bps"), "b")
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(t, "k"):
+		mult, t = 1e3, strings.TrimSuffix(t, "k")
+	case strings.HasSuffix(t, "m"):
+		mult, t = 1e6, strings.TrimSuffix(t, "m")
+	case strings.HasSuffix(t, "g"):
+		mult, t = 1e9, strings.TrimSuffix(t, "g")
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("无效的码率 %q (示例: 6M, 800k)", s)
+	}
+	return int64(n * mult), nil
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// MoveToTrash 将文件移入当前用户的废纸篓，返回其在废纸篓中的路径
+// macOS 使用 ~/.Trash；其他系统遵循 freedesktop 规范 (~/.local/share/Trash)，并写入 .trashinfo
+// 仅在同一文件系统内移动，跨卷时返回错误而不是删除原文件
+func MoveToTrash(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	filesDir := filepath.Join(home, ".Trash")
+	infoDir := ""
+	if runtime.GOOS != "darwin" {
+		filesDir = filepath.Join(home, ".local", "share", "Trash", "files")
+		infoDir = filepath.Join(home, ".local", "share", "Trash", "info")
+		if err := os.MkdirAll(infoDir, 0700); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(filesDir, 0700); err != nil {
+		return "", err
+	}
+
+	// 废纸篓中已有同名文件时追加序号
+	base := filepath.Base(abs)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	dst := filepath.Join(filesDir, base)
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(dst); os.IsNotExist(err) {
+			break
+		}
+		dst = filepath.Join(filesDir, fmt.Sprintf("%s %d%s", stem, n, ext))
+	}
+
+	if infoDir != "" {
+		info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+			(&url.URL{Path: abs}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+		if err := os.WriteFile(trashInfoPath(dst), []byte(info), 0600); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Rename(abs, dst); err != nil {
+		if infoDir != "" {
+			_ = os.Remove(trashInfoPath(dst))
+		}
+		return "", fmt.Errorf("移入废纸篓失败: %w", err)
+	}
+	return dst, nil
+}
+
+// RestoreFromTrash 将废纸篓中的文件移回原位置，目标已存在时拒绝覆盖
+func RestoreFromTrash(trashed, original string) error {
+	if _, err := os.Lstat(trashed); err != nil {
+		return fmt.Errorf("废纸篓中已找不到该文件: %w", err)
+	}
+	if _, err := os.Lstat(original); err == nil {
+		return fmt.Errorf("原位置已存在同名文件: %s", original)
+	}
+	if err := os.MkdirAll(filepath.Dir(original), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(trashed, original); err != nil {
+		return err
+	}
+	_ = os.Remove(trashInfoPath(trashed))
+	return nil
+}
+
+// trashInfoPath 返回 freedesktop 废纸篓中与 files/<name> 对应的 info/<name>.trashinfo
+func trashInfoPath(trashed string) string {
+	trashRoot := filepath.Dir(filepath.Dir(trashed))
+	return filepath.Join(trashRoot, "info", filepath.Base(trashed)+".trashinfo")
+}
@@ -1,21 +1,382 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // GetVideoDuration 获取视频时长（秒）
+// 容器未记录时长 (ffprobe 输出 N/A，如管道、索引损坏的文件) 时返回 0 而非错误
 func GetVideoDuration(filePath string) (float64, error) {
 	out, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", filePath).Output()
 	if err != nil {
 		return 0, err
 	}
-	return strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	s := strings.TrimSpace(string(out))
+	if s == "" || s == "N/A" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// VideoStream 描述一个视频流
+type VideoStream struct {
+	Index       int    // 在文件中的绝对流序号 (对应 -map 0:<Index>)
+	Width       int    // 编码宽度
+	Height      int    // 编码高度
+	PixFmt      string // 像素格式，如 yuv420p、yuv420p10le
+	SAR         string // 像素宽高比，如 "4:3" (变形宽银幕)；"1:1"、"0:1" 或空表示方形像素
+	AttachedPic bool   // 内嵌封面图，并非真正的视频
+	Spherical   bool   // 携带 360°/全景 (Spherical Video V2) 元数据
+
+	Codec   string // 编码名称，如 hevc、h264
+	BitRate int64  // 流码率 (bit/s)，容器未记录时为 0 (MKV 常见)
+}
+
+// Streams 是一次 ffprobe 探测得到的流信息
+type Streams struct {
+	Video         []VideoStream // 所有视频流 (含封面图)
+	AudioChannels int           // 第一条音频流的声道数，没有音频流时为 0
+}
+
+// ProbeStreams 以一次 ffprobe 读取视频流与第一条音频流的声道数
+// 全景元数据 (stream_side_data) 也在同一次探测中读取，扫描时不必为每个文件再调用 IsSpherical
+func ProbeStreams(filePath string) (Streams, error) {
+	out, err := exec.Command("ffprobe", "-v", "error",
+		"-show_entries", "stream=index,codec_type,codec_name,width,height,sample_aspect_ratio,pix_fmt,bit_rate,channels:stream_disposition=attached_pic:stream_side_data=side_data_type",
+		"-of", "json", filePath).Output()
+	if err != nil {
+		return Streams{}, err
+	}
+	return parseStreams(out)
+}
+
+// GetVideoStreams 返回文件中所有视频流 (含封面图)
+func GetVideoStreams(filePath string) ([]VideoStream, error) {
+	s, err := ProbeStreams(filePath)
+	return s.Video, err
+}
+
+// parseStreams 解析 ProbeStreams 的 ffprobe JSON 输出
+func parseStreams(out []byte) (Streams, error) {
+	var probe struct {
+		Streams []struct {
+			Index       int    `json:"index"`
+			CodecType   string `json:"codec_type"`
+			CodecName   string `json:"codec_name"`
+			Width       int    `json:"width"`
+			Height      int    `json:"height"`
+			SAR         string `json:"sample_aspect_ratio"`
+			PixFmt      string `json:"pix_fmt"`
+			BitRate     string `json:"bit_rate"`
+			Channels    int    `json:"channels"`
+			Disposition struct {
+				AttachedPic int `json:"attached_pic"`
+			} `json:"disposition"`
+			SideData []struct {
+				Type string `json:"side_data_type"`
+			} `json:"side_data_list"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return Streams{}, fmt.Errorf("解析流信息失败: %w", err)
+	}
+	var s Streams
+	audioSeen := false
+	for _, st := range probe.Streams {
+		switch st.CodecType {
+		case "audio":
+			if !audioSeen {
+				s.AudioChannels, audioSeen = st.Channels, true
+			}
+		case "video":
+			bitRate, _ := strconv.ParseInt(st.BitRate, 10, 64) // 未记录时为空
+			vs := VideoStream{
+				Index: st.Index, Codec: st.CodecName, Width: st.Width, Height: st.Height, SAR: st.SAR, PixFmt: st.PixFmt,
+				BitRate: bitRate, AttachedPic: st.Disposition.AttachedPic == 1,
+			}
+			for _, sd := range st.SideData {
+				vs.Spherical = vs.Spherical || strings.Contains(strings.ToLower(sd.Type), "spherical")
+			}
+			s.Video = append(s.Video, vs)
+		}
+	}
+	return s, nil
+}
+
+// PrimaryVideoStream 返回第一个非封面图的视频流，不存在时返回 nil
+func PrimaryVideoStream(streams []VideoStream) *VideoStream {
+	for i := range streams {
+		if !streams[i].AttachedPic {
+			return &streams[i]
+		}
+	}
+	return nil
+}
+
+// resolutionCache 缓存 GetVideoResolution 的结果，键含文件大小与修改时间，文件被替换后自动失效
+var resolutionCache sync.Map
+
+// resolution 是 resolutionCache 中的值
+type resolution struct{ width, height int }
+
+// GetVideoResolution 返回第一条视频流的编码宽高，同一文件在进程内只探测一次
+func GetVideoResolution(filePath string) (width, height int, err error) {
+	var key string
+	if fi, err := os.Stat(filePath); err == nil {
+		key = fmt.Sprintf("%s\x00%d\x00%d", filePath, fi.Size(), fi.ModTime().UnixNano())
+		if v, ok := resolutionCache.Load(key); ok {
+			r := v.(resolution)
+			return r.width, r.height, nil
+		}
+	}
+
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=width,height", "-of", "csv=s=x:p=0", filePath).Output()
+	if err != nil {
+		return 0, 0, err
+	}
+	// 输出格式为 <width>x<height>
+	w, h, ok := strings.Cut(strings.TrimSpace(string(out)), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("无法解析分辨率: %q", strings.TrimSpace(string(out)))
+	}
+	if width, err = strconv.Atoi(w); err != nil {
+		return 0, 0, fmt.Errorf("无法解析分辨率: %w", err)
+	}
+	if height, err = strconv.Atoi(strings.TrimSpace(h)); err != nil {
+		return 0, 0, fmt.Errorf("无法解析分辨率: %w", err)
+	}
+	if key != "" {
+		resolutionCache.Store(key, resolution{width, height})
+	}
+	return width, height, nil
+}
+
+// GetSubtitleCodecs 返回文件中所有字幕流的编码名称 (按流顺序)，如 ["ass", "hdmv_pgs_subtitle"]
+func GetSubtitleCodecs(filePath string) ([]string, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "s",
+		"-show_entries", "stream=codec_name", "-of", "csv=p=0", filePath).Output()
+	if err != nil {
+		return nil, err
+	}
+	var codecs []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			codecs = append(codecs, line)
+		}
+	}
+	return codecs, nil
+}
+
+// IsSpherical 判断视频流是否携带 360°/全景 (Spherical Video V2) 元数据
+func IsSpherical(filePath string) (bool, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream_side_data=side_data_type", "-of", "csv=p=0", filePath).Output()
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(strings.ToLower(string(out)), "spherical"), nil
+}
+
+// GetRotation 返回第一条视频流需要顺时针旋转的角度 (0、90、180 或 270)
+// 优先读取显示矩阵 (stream_side_data 的 rotation，逆时针为正)，其次读取旧式的 rotate 标签 (顺时针)
+func GetRotation(filePath string) (int, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream_tags=rotate:stream_side_data=rotation", "-of", "json", filePath).Output()
+	if err != nil {
+		return 0, err
+	}
+	var probe struct {
+		Streams []struct {
+			Tags struct {
+				Rotate string `json:"rotate"`
+			} `json:"tags"`
+			SideData []struct {
+				Rotation *float64 `json:"rotation"`
+			} `json:"side_data_list"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return 0, err
+	}
+	if len(probe.Streams) == 0 {
+		return 0, nil
+	}
+	s := probe.Streams[0]
+	deg := 0
+	for _, sd := range s.SideData {
+		if sd.Rotation != nil {
+			deg = -int(math.Round(*sd.Rotation))
+			break
+		}
+	}
+	if deg == 0 && s.Tags.Rotate != "" {
+		deg, _ = strconv.Atoi(s.Tags.Rotate)
+	}
+	// 只处理 90° 的整数倍，归一化到 0-359
+	deg = ((deg % 360) + 360) % 360
+	if deg%90 != 0 {
+		return 0, nil
+	}
+	return deg, nil
+}
+
+// GetColorInfo 返回视频流的传输特性与色域 (如 smpte2084、bt2020)，未标注时为空
+// stream 为绝对流序号，-1 表示第一条视频流
+func GetColorInfo(filePath string, stream int) (transfer, primaries string, err error) {
+	spec := "v:0"
+	if stream >= 0 {
+		spec = strconv.Itoa(stream)
+	}
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", spec,
+		"-show_entries", "stream=color_transfer,color_primaries", "-of", "csv=p=0", filePath).Output()
+	if err != nil {
+		return "", "", err
+	}
+	// 输出格式 (按 ffprobe 的字段顺序): color_transfer,color_primaries
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	transfer, primaries, _ = strings.Cut(line, ",")
+	if transfer == "unknown" {
+		transfer = ""
+	}
+	if primaries == "unknown" {
+		primaries = ""
+	}
+	return transfer, primaries, nil
+}
+
+// GetDataStreams 返回文件中所有数据流的编码标签 (按流顺序)，如 ["tmcd", "gpmd"]
+func GetDataStreams(filePath string) ([]string, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "d",
+		"-show_entries", "stream=codec_tag_string", "-of", "csv=p=0", filePath).Output()
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			tags = append(tags, line)
+		}
+	}
+	return tags, nil
+}
+
+// MediaStream 是一条视频或音频流的编码信息，用于判断输入能否被解码
+type MediaStream struct {
+	Index     int
+	Type      string // video 或 audio
+	Codec     string // ffprobe 的 codec_name，无法识别的编码为空
+	CodecTag  string // 容器中的编码标签，如 avc1、drmi
+	Encrypted bool   // 带有 DRM 加密标记 (FairPlay 的 drmi/drms、CENC 的 encv/enca 或 encrypted 标签)
+}
+
+// drmCodecTags 是加密流在容器中使用的编码标签
+var drmCodecTags = []string{"drmi", "drms", "encv", "enca"}
+
+// GetMediaStreams 返回所有视频与音频流 (不含封面图) 的编码信息
+func GetMediaStreams(filePath string) ([]MediaStream, error) {
+	out, err := exec.Command("ffprobe", "-v", "error",
+		"-show_entries", "stream=index,codec_type,codec_name,codec_tag_string:stream_tags:stream_disposition=attached_pic",
+		"-of", "json", filePath).Output()
+	if err != nil {
+		return nil, err
+	}
+	var probe struct {
+		Streams []struct {
+			Index       int               `json:"index"`
+			CodecType   string            `json:"codec_type"`
+			CodecName   string            `json:"codec_name"`
+			CodecTag    string            `json:"codec_tag_string"`
+			Tags        map[string]string `json:"tags"`
+			Disposition struct {
+				AttachedPic int `json:"attached_pic"`
+			} `json:"disposition"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, err
+	}
+	var streams []MediaStream
+	for _, s := range probe.Streams {
+		if (s.CodecType != "video" && s.CodecType != "audio") || s.Disposition.AttachedPic == 1 {
+			continue
+		}
+		ms := MediaStream{Index: s.Index, Type: s.CodecType, Codec: s.CodecName, CodecTag: s.CodecTag}
+		if ms.Codec == "none" || ms.Codec == "unknown" {
+			ms.Codec = ""
+		}
+		ms.Encrypted = slices.Contains(drmCodecTags, strings.ToLower(s.CodecTag))
+		for k, v := range s.Tags {
+			if strings.Contains(strings.ToLower(k), "encrypt") || strings.Contains(strings.ToLower(v), "encrypted") {
+				ms.Encrypted = true
+			}
+		}
+		streams = append(streams, ms)
+	}
+	return streams, nil
+}
+
+// GetFormatTags 返回容器级元数据标签 (键名统一转为小写)
+func GetFormatTags(filePath string) (map[string]string, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "format_tags", "-of", "json", filePath).Output()
+	if err != nil {
+		return nil, err
+	}
+	var probe struct {
+		Format struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(probe.Format.Tags))
+	for k, v := range probe.Format.Tags {
+		tags[strings.ToLower(k)] = v
+	}
+	return tags, nil
 }
 
 // EnsureDir 确保目录存在
 func EnsureDir(dir string) error {
 	return exec.Command("mkdir", "-p", dir).Run()
 }
+
+// FileSHA256 以流式方式计算文件的 SHA-256 (十六进制)，避免整个文件读入内存
+func FileSHA256(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteSHA256Sidecar 计算文件的 SHA-256 并写入同目录的 <file>.sha256 (sha256sum -c 兼容格式)
+// 返回 sidecar 路径
+func WriteSHA256Sidecar(filePath string) (string, error) {
+	sum, err := FileSHA256(filePath)
+	if err != nil {
+		return "", err
+	}
+	sidecar := filePath + ".sha256"
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(filePath))
+	return sidecar, os.WriteFile(sidecar, []byte(line), 0644)
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeTool 在临时目录中放一个名为 name 的 shell 脚本并将其置于 PATH 最前 (同 internal/ffmpeg 测试中的 fakeTool)
+func fakeTool(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake " + name + " is a shell script")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// writeVideo 写入一个内容唯一的占位输入，保证缓存键互不相同
+func writeVideo(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(t.Name()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetVideoResolution(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		width, height int
+		wantErr       bool
+	}{
+		{"1080p", "1920x1080\n", 1920, 1080, false},
+		// 多个视频流 (-select_streams v:0 只取第一条) 或末尾多余的分隔符
+		{"trailing", "1280x720x\n", 0, 0, true},
+		{"empty", "", 0, 0, true},
+		{"na", "N/A\n", 0, 0, true},
+		{"na pair", "N/AxN/A\n", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTool(t, "ffprobe", "printf '"+tt.output+"'\n")
+			w, h, err := GetVideoResolution(writeVideo(t, "in.mp4"))
+			if (err != nil) != tt.wantErr || w != tt.width || h != tt.height {
+				t.Errorf("GetVideoResolution() = %d, %d, %v; want %d, %d, error %v", w, h, err, tt.width, tt.height, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 同一文件只探测一次；文件被替换 (大小或修改时间变化) 后重新探测
+func TestGetVideoResolutionCached(t *testing.T) {
+	count := filepath.Join(t.TempDir(), "count")
+	fakeTool(t, "ffprobe", "echo x >> '"+count+"'\nprintf '3840x2160\\n'\n")
+	path := writeVideo(t, "in.mp4")
+	probes := func() int {
+		data, _ := os.ReadFile(count)
+		return strings.Count(string(data), "x")
+	}
+
+	for range 3 {
+		if w, h, err := GetVideoResolution(path); err != nil || w != 3840 || h != 2160 {
+			t.Fatalf("GetVideoResolution() = %d, %d, %v", w, h, err)
+		}
+	}
+	if n := probes(); n != 1 {
+		t.Errorf("ffprobe ran %d times, want 1", n)
+	}
+
+	if err := os.WriteFile(path, []byte("replaced with a different size"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := GetVideoResolution(path); err != nil {
+		t.Fatal(err)
+	}
+	if n := probes(); n != 2 {
+		t.Errorf("ffprobe ran %d times after the file changed, want 2", n)
+	}
+}
+
+func TestProbeStreams(t *testing.T) {
+	fixture, err := filepath.Abs(filepath.Join("testdata", "streams.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeTool(t, "ffprobe", "cat '"+fixture+"'\n")
+	probe, err := ProbeStreams("in.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 声道数取第一条音频流
+	if probe.AudioChannels != 6 {
+		t.Errorf("AudioChannels = %d, want 6", probe.AudioChannels)
+	}
+	streams := probe.Video
+	want := []VideoStream{
+		{Index: 0, Codec: "hevc", Width: 5760, Height: 2880, SAR: "1:1", PixFmt: "yuv420p10le", BitRate: 60000000, Spherical: true},
+		{Index: 3, Codec: "mjpeg", Width: 600, Height: 600, SAR: "1:1", PixFmt: "yuvj420p", AttachedPic: true},
+	}
+	if !slices.Equal(streams, want) {
+		t.Errorf("streams = %+v\nwant      %+v", streams, want)
+	}
+	if p := PrimaryVideoStream(streams); p == nil || p.Index != 0 {
+		t.Errorf("PrimaryVideoStream = %+v", p)
+	}
+}
+
+func TestProbeStreamsNoAudio(t *testing.T) {
+	fakeTool(t, "ffprobe", `echo '{"streams": [{"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080}]}'`+"\n")
+	probe, err := ProbeStreams("in.mp4")
+	if err != nil || probe.AudioChannels != 0 || len(probe.Video) != 1 {
+		t.Errorf("ProbeStreams = %+v, %v", probe, err)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build darwin || linux
+
+package utils
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// XattrSupported 表示当前平台能否读写文件的扩展属性
+const XattrSupported = true
+
+// xattrName 返回平台上实际使用的属性名：Linux 上非特权进程只能写 user.* 命名空间，自动补上前缀
+func xattrName(name string) string {
+	if runtime.GOOS == "linux" && !strings.HasPrefix(name, "user.") {
+		return "user." + name
+	}
+	return name
+}
+
+// SetXattr 在文件上写入扩展属性
+func SetXattr(path, name, value string) error {
+	return unix.Setxattr(path, xattrName(name), []byte(value), 0)
+}
+
+// GetXattr 读取文件的扩展属性，不存在时 ok 为 false
+func GetXattr(path, name string) (value string, ok bool) {
+	buf := make([]byte, 1024)
+	n, err := unix.Getxattr(path, xattrName(name), buf)
+	if errors.Is(err, unix.ERANGE) {
+		return "", true // 值超过缓冲区，但属性存在
+	}
+	if err != nil {
+		return "", false
+	}
+	return string(buf[:n]), true
+}
+
+// RemoveXattr 删除文件的扩展属性，属性本不存在时不视为错误
+func RemoveXattr(path, name string) error {
+	err := unix.Removexattr(path, xattrName(name))
+	if errors.Is(err, errNoAttr) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,6 @@
+package utils
+
+import "golang.org/x/sys/unix"
+
+// errNoAttr 是属性不存在时返回的错误
+const errNoAttr = unix.ENODATA
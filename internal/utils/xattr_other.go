@@ -0,0 +1,26 @@
+//go:build !darwin && !linux
+
+package utils
+
+import "errors"
+
+// XattrSupported 表示当前平台能否读写文件的扩展属性
+const XattrSupported = false
+
+// errXattrUnsupported 是不支持扩展属性的平台上写入或删除属性时返回的错误
+var errXattrUnsupported = errors.New("extended attributes not supported on this platform")
+
+// SetXattr 在不支持的平台上返回错误
+func SetXattr(path, name, value string) error {
+	return errXattrUnsupported
+}
+
+// GetXattr 在不支持的平台上视为属性不存在
+func GetXattr(path, name string) (value string, ok bool) {
+	return "", false
+}
+
+// RemoveXattr 在不支持的平台上返回错误
+func RemoveXattr(path, name string) error {
+	return errXattrUnsupported
+}